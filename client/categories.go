@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"github.com/rnwonder/SAL/types"
+)
+
+func (client *Client) ListCategories(ctx context.Context) (*types.GetCategoriesResponse, error) {
+	response := new(types.GetCategoriesResponse)
+	return response, client.do(ctx, "GET", "/category", nil, nil, response)
+}
+
+func (client *Client) GetCategory(ctx context.Context, id string) (*types.OneCategoryResponse, error) {
+	response := new(types.OneCategoryResponse)
+	return response, client.do(ctx, "GET", "/category/"+escape(id), nil, nil, response)
+}
+
+func (client *Client) CreateCategory(ctx context.Context, payload types.CategoryCreatePayload) (*types.OneCategoryResponse, error) {
+	response := new(types.OneCategoryResponse)
+	return response, client.do(ctx, "POST", "/category", nil, payload, response)
+}
+
+func (client *Client) UpdateCategory(ctx context.Context, id string, payload types.CategoryUpdatePayload) (*types.OneCategoryResponse, error) {
+	response := new(types.OneCategoryResponse)
+	return response, client.do(ctx, "PUT", "/category/"+escape(id), nil, payload, response)
+}
+
+func (client *Client) DeleteCategory(ctx context.Context, id string) (*types.MessageResponse, error) {
+	response := new(types.MessageResponse)
+	return response, client.do(ctx, "DELETE", "/category/"+escape(id), nil, nil, response)
+}
+
+func (client *Client) AssignProductCategories(ctx context.Context, productId string, payload types.ProductCategoriesPayload) (*types.OneProductResponse, error) {
+	response := new(types.OneProductResponse)
+	return response, client.do(ctx, "POST", "/product/"+escape(productId)+"/categories", nil, payload, response)
+}
@@ -0,0 +1,246 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The headers the API reads, copies of the ones in internals/middleware
+const (
+	APIKeyHeader         = "X-API-Key"
+	MemberTokenHeader    = "X-Member-Token"
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// Client calls the SAL API. Requests that fail with a network error, 429, 502, 503 or 504 are retried,
+// requests that change something are sent with an Idempotency-Key so a retry never applies them twice.
+type Client struct {
+	baseURL     string
+	httpClient  *http.Client
+	apiKey      string
+	memberToken string
+	skuId       string
+	maxRetries  int
+	retryWait   time.Duration
+}
+
+type Option func(client *Client)
+
+// WithAPIKey authenticates every request with an API key
+func WithAPIKey(key string) Option {
+	return func(client *Client) {
+		client.apiKey = key
+	}
+}
+
+// WithMemberToken authenticates every request as a team member
+func WithMemberToken(token string) Option {
+	return func(client *Client) {
+		client.memberToken = token
+	}
+}
+
+// WithSkuId identifies the merchant with the skuId query parameter
+func WithSkuId(skuId string) Option {
+	return func(client *Client) {
+		client.skuId = skuId
+	}
+}
+
+// WithHTTPClient replaces http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a request is retried and the wait before the first retry,
+// the wait doubles with every retry unless the API sends a Retry-After header
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(client *Client) {
+		client.maxRetries = maxRetries
+		client.retryWait = wait
+	}
+}
+
+// New returns a client of the API at baseURL, such as https://localhost:4500
+func New(baseURL string, options ...Option) *Client {
+	client := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		maxRetries: 3,
+		retryWait:  200 * time.Millisecond,
+	}
+
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+// do sends a request and decodes the response into out, body and out may be nil.
+// Every attempt of the request carries the same Idempotency-Key.
+func (client *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = data
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+	if client.skuId != "" && query.Get("skuId") == "" {
+		query.Set("skuId", client.skuId)
+	}
+
+	target := client.baseURL + path
+	if encoded := query.Encode(); encoded != "" {
+		target += "?" + encoded
+	}
+
+	idempotencyKey := ""
+	if method != http.MethodGet {
+		idempotencyKey = uuid.NewString()
+	}
+
+	for attempt := 0; ; attempt++ {
+		wait, err := client.attempt(ctx, method, target, payload, idempotencyKey, out)
+
+		if err == nil || attempt >= client.maxRetries || !retryable(err) {
+			return err
+		}
+
+		if wait <= 0 {
+			wait = client.backoff(attempt)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends a request once, it returns how long the API asked to wait before retrying
+func (client *Client) attempt(ctx context.Context, method string, target string, payload []byte, idempotencyKey string, out interface{}) (time.Duration, error) {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	}
+	if client.apiKey != "" {
+		req.Header.Set(APIKeyHeader, client.apiKey)
+	}
+	if client.memberToken != "" {
+		req.Header.Set(MemberTokenHeader, client.memberToken)
+	}
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, &networkError{err}
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, &networkError{err}
+	}
+
+	if resp.StatusCode >= 400 {
+		apiError := newError(resp, data)
+		return apiError.RetryAfter, apiError
+	}
+
+	if out == nil || len(data) == 0 {
+		return 0, nil
+	}
+	return 0, json.Unmarshal(data, out)
+}
+
+// backoff is the wait before retry number attempt, with jitter so clients do not retry together
+func (client *Client) backoff(attempt int) time.Duration {
+	wait := client.retryWait << attempt
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// networkError is a request that did not get a response, it is always retried
+type networkError struct {
+	err error
+}
+
+func (err *networkError) Error() string {
+	return err.err.Error()
+}
+
+func (err *networkError) Unwrap() error {
+	return err.err
+}
+
+func retryable(err error) bool {
+	var network *networkError
+	if errors.As(err, &network) {
+		return true
+	}
+
+	var apiError *Error
+	if !errors.As(err, &apiError) {
+		return false
+	}
+
+	switch apiError.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		// The first attempt is still running
+		return apiError.Problem.Code == CodeIdempotencyKeyInProgress
+	}
+	return false
+}
+
+// retryAfter reads a Retry-After header in seconds
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func escape(segment string) string {
+	return url.PathEscape(segment)
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"errors"
+	"github.com/goccy/go-json"
+	"net/http"
+	"time"
+)
+
+// Error is an error response of the API, Problem holds the RFC 7807 problem it was sent as
+type Error struct {
+	StatusCode int
+	Problem    Problem
+	// RetryAfter is the wait the API asked for before retrying, it is only set on 429 responses
+	RetryAfter time.Duration
+}
+
+func (err *Error) Error() string {
+	return "sal: " + err.Problem.Error()
+}
+
+// HasCode reports whether err is an error response of the API with code, such as CodeProductNotFound
+func HasCode(err error, code Code) bool {
+	var apiError *Error
+	return errors.As(err, &apiError) && apiError.Problem.Code == code
+}
+
+// IsNotFound reports whether err is a 404 response of the API, whatever was not found
+func IsNotFound(err error) bool {
+	var apiError *Error
+	return errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound
+}
+
+// newError reads an error response. Bodies that are not problems, from a proxy for example, get
+// the generic problem of their status.
+func newError(resp *http.Response, body []byte) *Error {
+	apiError := &Error{
+		StatusCode: resp.StatusCode,
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	if err := json.Unmarshal(body, &apiError.Problem); err != nil || apiError.Problem.Code == "" {
+		apiError.Problem = statusProblem(resp.StatusCode)
+	}
+	return apiError
+}
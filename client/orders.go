@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/types"
+	"net/url"
+)
+
+func (client *Client) CreateCart(ctx context.Context) (*types.CartResponse, error) {
+	response := new(types.CartResponse)
+	return response, client.do(ctx, "POST", "/cart", nil, nil, response)
+}
+
+func (client *Client) GetCart(ctx context.Context, id string) (*types.CartResponse, error) {
+	response := new(types.CartResponse)
+	return response, client.do(ctx, "GET", "/cart/"+escape(id), nil, nil, response)
+}
+
+func (client *Client) AddCartItem(ctx context.Context, cartId string, payload types.CartItemPayload) (*types.CartResponse, error) {
+	response := new(types.CartResponse)
+	return response, client.do(ctx, "POST", "/cart/"+escape(cartId)+"/items", nil, payload, response)
+}
+
+func (client *Client) UpdateCartItem(ctx context.Context, cartId string, itemId string, payload types.CartItemUpdatePayload) (*types.CartResponse, error) {
+	response := new(types.CartResponse)
+	return response, client.do(ctx, "PUT", "/cart/"+escape(cartId)+"/items/"+escape(itemId), nil, payload, response)
+}
+
+func (client *Client) RemoveCartItem(ctx context.Context, cartId string, itemId string) (*types.CartResponse, error) {
+	response := new(types.CartResponse)
+	return response, client.do(ctx, "DELETE", "/cart/"+escape(cartId)+"/items/"+escape(itemId), nil, nil, response)
+}
+
+func (client *Client) AddCartCoupon(ctx context.Context, cartId string, code string) (*types.CartResponse, error) {
+	response := new(types.CartResponse)
+	return response, client.do(ctx, "POST", "/cart/"+escape(cartId)+"/coupons", nil, types.CartCouponPayload{Code: code}, response)
+}
+
+// PlaceOrder turns a cart into an order
+func (client *Client) PlaceOrder(ctx context.Context, cartId string) (*types.OrderResponse, error) {
+	response := new(types.OrderResponse)
+	return response, client.do(ctx, "POST", "/order", nil, types.OrderCreatePayload{CartId: cartId}, response)
+}
+
+func (client *Client) GetOrder(ctx context.Context, id string) (*types.OrderResponse, error) {
+	response := new(types.OrderResponse)
+	return response, client.do(ctx, "GET", "/order/"+escape(id), nil, nil, response)
+}
+
+// ListOrders returns the orders of the merchant, every order when status is empty
+func (client *Client) ListOrders(ctx context.Context, status models.OrderStatus) (*types.GetOrdersResponse, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+
+	response := new(types.GetOrdersResponse)
+	return response, client.do(ctx, "GET", "/order", query, nil, response)
+}
+
+func (client *Client) TransitionOrder(ctx context.Context, id string, payload types.OrderTransitionPayload) (*types.OrderResponse, error) {
+	response := new(types.OrderResponse)
+	return response, client.do(ctx, "POST", "/order/"+escape(id)+"/transitions", nil, payload, response)
+}
+
+// CheckoutOrder starts paying for an order, the customer completes the payment at the returned authorization URL
+func (client *Client) CheckoutOrder(ctx context.Context, id string, payload types.OrderPaymentPayload) (*types.PaymentResponse, error) {
+	response := new(types.PaymentResponse)
+	return response, client.do(ctx, "POST", "/order/"+escape(id)+"/payment", nil, payload, response)
+}
@@ -0,0 +1,145 @@
+package client
+
+import "net/http"
+
+// The client does not import the server packages, they would pull Fiber into every program using it. The types
+// and codes below are copies of the ones in internals/problem and have to be kept in step with them.
+
+// Code identifies a kind of error, codes never change once published
+type Code string
+
+// FieldError describes one field of a request body that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details error as the API sends it.
+// Code is a machine-readable identifier to switch on, Detail is meant for people.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Code     Code         `json:"code"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+func (problem *Problem) Error() string {
+	return string(problem.Code) + ": " + problem.Detail
+}
+
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeInvalidPayload       Code = "invalid_payload"
+	CodeInvalidParameter     Code = "invalid_parameter"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeUnprocessable        Code = "unprocessable_entity"
+	CodeRateLimited          Code = "rate_limited"
+	CodeInternal             Code = "internal_error"
+	CodeInvalidResponse      Code = "invalid_response"
+	CodeBadGateway           Code = "bad_gateway"
+	CodeUnavailable          Code = "service_unavailable"
+)
+
+const (
+	CodeMerchantRequired   Code = "merchant_required"
+	CodeInvalidAPIKey      Code = "invalid_api_key"
+	CodeInvalidMemberToken Code = "invalid_member_token"
+	CodeInvalidSignature   Code = "invalid_signature"
+	CodeInsufficientScope  Code = "insufficient_scope"
+	CodeInsufficientRole   Code = "insufficient_role"
+	CodeAPIKeyNotAllowed   Code = "api_key_not_allowed"
+)
+
+const (
+	CodeProductNotFound       Code = "product_not_found"
+	CodeVariantNotFound       Code = "variant_not_found"
+	CodeCategoryNotFound      Code = "category_not_found"
+	CodeImageNotFound         Code = "image_not_found"
+	CodeReservationNotFound   Code = "reservation_not_found"
+	CodePriceScheduleNotFound Code = "price_schedule_not_found"
+	CodeCartNotFound          Code = "cart_not_found"
+	CodeCartItemNotFound      Code = "cart_item_not_found"
+	CodeOrderNotFound         Code = "order_not_found"
+	CodeTransactionNotFound   Code = "transaction_not_found"
+	CodeAPIKeyNotFound        Code = "api_key_not_found"
+	CodeMemberNotFound        Code = "member_not_found"
+	CodeInviteNotFound        Code = "invite_not_found"
+	CodePromotionNotFound     Code = "promotion_not_found"
+	CodeCouponNotFound        Code = "coupon_not_found"
+)
+
+const (
+	CodeVariantExists            Code = "variant_exists"
+	CodeMemberExists             Code = "member_exists"
+	CodeCouponExists             Code = "coupon_exists"
+	CodeCategoryCycle            Code = "category_cycle"
+	CodeCategoryHasChildren      Code = "category_has_children"
+	CodeInsufficientStock        Code = "insufficient_stock"
+	CodeScheduleOverlap          Code = "schedule_overlap"
+	CodeScheduleFinished         Code = "schedule_finished"
+	CodeInvalidTransition        Code = "invalid_transition"
+	CodeCouponUnavailable        Code = "coupon_unavailable"
+	CodeAPIKeyRevoked            Code = "api_key_revoked"
+	CodeCartEmpty                Code = "cart_empty"
+	CodePaymentIncomplete        Code = "payment_incomplete"
+	CodePaymentFailed            Code = "payment_failed"
+	CodeUnknownJurisdiction      Code = "unknown_jurisdiction"
+	CodeIdempotencyKeyInProgress Code = "idempotency_key_in_progress"
+	CodeIdempotencyKeyReused     Code = "idempotency_key_reused"
+	CodeIdempotencyKeyTooLong    Code = "idempotency_key_too_long"
+)
+
+// statusProblem is the generic problem of a response that only carries a status
+func statusProblem(status int) Problem {
+	code := CodeInternal
+	switch status {
+	case http.StatusBadRequest:
+		code = CodeBadRequest
+	case http.StatusUnauthorized:
+		code = CodeUnauthorized
+	case http.StatusForbidden:
+		code = CodeForbidden
+	case http.StatusNotFound:
+		code = CodeNotFound
+	case http.StatusMethodNotAllowed:
+		code = CodeMethodNotAllowed
+	case http.StatusConflict:
+		code = CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		code = CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		code = CodeUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		code = CodeUnprocessable
+	case http.StatusTooManyRequests:
+		code = CodeRateLimited
+	case http.StatusBadGateway:
+		code = CodeBadGateway
+	case http.StatusServiceUnavailable:
+		code = CodeUnavailable
+	default:
+		if status < http.StatusInternalServerError {
+			code = CodeBadRequest
+		}
+	}
+
+	return Problem{
+		Type:   "/problems/" + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: http.StatusText(status),
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"github.com/rnwonder/SAL/types"
+	"net/url"
+	"strconv"
+)
+
+// ListProductsOptions are the query parameters of GET /product, zero values are left out
+type ListProductsOptions struct {
+	Page      int
+	Limit     int
+	Search    string
+	SortKey   string
+	SortOrder string
+	// Category lists the products of the category and of its descendants
+	Category string
+	// InStock lists the products with available stock when true and the others when false
+	InStock *bool
+}
+
+func (options ListProductsOptions) query() url.Values {
+	query := url.Values{}
+	if options.Page > 0 {
+		query.Set("page", strconv.Itoa(options.Page))
+	}
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if options.Search != "" {
+		query.Set("search", options.Search)
+	}
+	if options.SortKey != "" {
+		query.Set("sortKey", options.SortKey)
+	}
+	if options.SortOrder != "" {
+		query.Set("sortOrder", options.SortOrder)
+	}
+	if options.Category != "" {
+		query.Set("category", options.Category)
+	}
+	if options.InStock != nil {
+		query.Set("inStock", strconv.FormatBool(*options.InStock))
+	}
+	return query
+}
+
+// ListProducts returns one page of products
+func (client *Client) ListProducts(ctx context.Context, options ListProductsOptions) (*types.GetProductResponse, error) {
+	response := new(types.GetProductResponse)
+	return response, client.do(ctx, "GET", "/product", options.query(), nil, response)
+}
+
+// EachProduct calls fn with every product from options.Page on, fetching the pages as it goes.
+// It stops at the first error, including one returned by fn.
+func (client *Client) EachProduct(ctx context.Context, options ListProductsOptions, fn func(product types.ProductView) error) error {
+	options.Page = max(options.Page, 1)
+
+	for {
+		page, err := client.ListProducts(ctx, options)
+		if err != nil {
+			return err
+		}
+
+		for _, product := range page.Products {
+			if err := fn(product); err != nil {
+				return err
+			}
+		}
+
+		if options.Page >= page.Meta.TotalPages || len(page.Products) == 0 {
+			return nil
+		}
+		options.Page++
+	}
+}
+
+func (client *Client) GetProduct(ctx context.Context, id string) (*types.OneProductResponse, error) {
+	response := new(types.OneProductResponse)
+	return response, client.do(ctx, "GET", "/product/"+escape(id), nil, nil, response)
+}
+
+func (client *Client) CreateProduct(ctx context.Context, payload types.ProductCreatePayload) (*types.OneProductResponse, error) {
+	response := new(types.OneProductResponse)
+	return response, client.do(ctx, "POST", "/product", nil, payload, response)
+}
+
+// UpdateProduct changes the fields of payload that are not empty
+func (client *Client) UpdateProduct(ctx context.Context, id string, payload types.ProductUpdatePayload) (*types.OneProductResponse, error) {
+	response := new(types.OneProductResponse)
+	return response, client.do(ctx, "PUT", "/product/"+escape(id), nil, payload, response)
+}
+
+func (client *Client) DeleteProduct(ctx context.Context, id string) (*types.MessageResponse, error) {
+	response := new(types.MessageResponse)
+	return response, client.do(ctx, "DELETE", "/product/"+escape(id), nil, nil, response)
+}
+
+func (client *Client) ListVariants(ctx context.Context, productId string) (*types.GetVariantsResponse, error) {
+	response := new(types.GetVariantsResponse)
+	return response, client.do(ctx, "GET", "/product/"+escape(productId)+"/variants", nil, nil, response)
+}
+
+func (client *Client) GetVariant(ctx context.Context, productId string, variantId string) (*types.OneVariantResponse, error) {
+	response := new(types.OneVariantResponse)
+	return response, client.do(ctx, "GET", "/product/"+escape(productId)+"/variants/"+escape(variantId), nil, nil, response)
+}
+
+func (client *Client) CreateVariant(ctx context.Context, productId string, payload types.VariantCreatePayload) (*types.OneVariantResponse, error) {
+	response := new(types.OneVariantResponse)
+	return response, client.do(ctx, "POST", "/product/"+escape(productId)+"/variants", nil, payload, response)
+}
+
+func (client *Client) UpdateVariant(ctx context.Context, productId string, variantId string, payload types.VariantUpdatePayload) (*types.OneVariantResponse, error) {
+	response := new(types.OneVariantResponse)
+	return response, client.do(ctx, "PUT", "/product/"+escape(productId)+"/variants/"+escape(variantId), nil, payload, response)
+}
+
+func (client *Client) DeleteVariant(ctx context.Context, productId string, variantId string) (*types.MessageResponse, error) {
+	response := new(types.MessageResponse)
+	return response, client.do(ctx, "DELETE", "/product/"+escape(productId)+"/variants/"+escape(variantId), nil, nil, response)
+}
+
+func (client *Client) GetStock(ctx context.Context, productId string) (*types.StockResponse, error) {
+	response := new(types.StockResponse)
+	return response, client.do(ctx, "GET", "/product/"+escape(productId)+"/stock", nil, nil, response)
+}
+
+func (client *Client) IncrementStock(ctx context.Context, productId string, payload types.StockAdjustPayload) (*types.StockResponse, error) {
+	response := new(types.StockResponse)
+	return response, client.do(ctx, "POST", "/product/"+escape(productId)+"/stock/increment", nil, payload, response)
+}
+
+func (client *Client) DecrementStock(ctx context.Context, productId string, payload types.StockAdjustPayload) (*types.StockResponse, error) {
+	response := new(types.StockResponse)
+	return response, client.do(ctx, "POST", "/product/"+escape(productId)+"/stock/decrement", nil, payload, response)
+}
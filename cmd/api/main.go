@@ -6,20 +6,23 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/swagger"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/joho/godotenv"
-	_ "github.com/rnwonder/SAL/docs"
 	"github.com/rnwonder/SAL/internals/handlers"
 	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/openapi"
+	"github.com/rnwonder/SAL/internals/payment"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/internals/routes"
+	"github.com/rnwonder/SAL/internals/storage"
+	salv1 "github.com/rnwonder/SAL/proto/sal/v1"
+	"google.golang.org/grpc"
+	"net"
 	"os"
+	"time"
 )
 
-// @title           ShopAnythingLagos API
-// @version         1.0
-// @description     This is the ShopAnythingLagos API documentation
-// @host      localhost:4500
-// @BasePath  /
-
 func main() {
 	loadEnvFileError := godotenv.Load("../../.env")
 
@@ -27,44 +30,126 @@ func main() {
 		log.Error("Error loading .env file")
 	}
 
+	uploadDir := cmp.Or(os.Getenv("UPLOAD_DIR"), "uploads")
+	imageStore, err := storage.NewLocalStore(uploadDir, "/uploads")
+
+	if err != nil {
+		log.Fatal("Error creating upload directory: ", err)
+	}
+
+	handlers.ImageStore = imageStore
+
+	// Payments stay off until a provider is chosen, its webhooks are only trusted with a secret from the environment
+	paymentProvider := os.Getenv("PAYMENT_PROVIDER")
+	webhookSecret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+
+	if paymentProvider != "" && webhookSecret == "" {
+		log.Fatal("PAYMENT_WEBHOOK_SECRET is required with PAYMENT_PROVIDER")
+	}
+
+	switch paymentProvider {
+	case "":
+		log.Info("PAYMENT_PROVIDER is not set, payments are turned off")
+	case "fake":
+		handlers.PaymentProvider = payment.NewFakeProvider(webhookSecret, "/payment/fake")
+	default:
+		log.Fatal("Unknown payment provider: ", paymentProvider)
+	}
+
+	// Completing fake payments marks orders as paid without any money, it is for local development only
+	fakePayments := os.Getenv("PAYMENT_FAKE_ROUTE") == "true"
+
+	if fakePayments && paymentProvider != "fake" {
+		log.Fatal("PAYMENT_FAKE_ROUTE needs PAYMENT_PROVIDER=fake")
+	}
+
+	defaultRateLimit, err := middleware.ParseRateLimitRule(cmp.Or(os.Getenv("RATE_LIMIT"), "120/1m"))
+
+	if err != nil {
+		log.Fatal("Error reading RATE_LIMIT: ", err)
+	}
+
+	routeRateLimits, err := middleware.ParseRateLimitRoutes(os.Getenv("RATE_LIMIT_ROUTES"))
+
+	if err != nil {
+		log.Fatal("Error reading RATE_LIMIT_ROUTES: ", err)
+	}
+
+	authFailureLimit, err := middleware.ParseRateLimitRule(cmp.Or(os.Getenv("AUTH_FAILURE_LIMIT"), "10/1m"))
+
+	if err != nil {
+		log.Fatal("Error reading AUTH_FAILURE_LIMIT: ", err)
+	}
+
+	validation := cmp.Or(os.Getenv("OPENAPI_VALIDATION"), "off")
+
+	if validation != "off" && validation != "requests" && validation != "responses" {
+		log.Fatal("Unknown OPENAPI_VALIDATION, use off, requests or responses: ", validation)
+	}
+
+	taxRules, err := models.LoadTaxRules(cmp.Or(os.Getenv("TAX_RULES_FILE"), "../../config/taxRules.json"))
+
+	if err != nil {
+		log.Error("Error loading tax rules, using the default rules: ", err)
+	} else {
+		models.SetTaxRules(taxRules)
+	}
+
 	app := fiber.New(fiber.Config{
 		JSONEncoder: json.Marshal,
 		JSONDecoder: json.Unmarshal,
+		// Every error returned by a handler is written as problem+json
+		ErrorHandler: problem.Handler,
+		// Leaves room for several images in one upload
+		BodyLimit: 4 * handlers.MaxImageSize,
 	})
 
+	// Both run for the lifetime of the server
+	models.StartJanitor(time.Minute, nil)
+	models.StartPriceScheduler(15*time.Second, nil)
+
 	app.Use(cors.New())
+	app.Use(requestid.New())
 	app.Use(middleware.LogRequest)
+	app.Use(middleware.AuthFailureLimit(authFailureLimit))
+	app.Use(middleware.APIKeyAuth)
+	app.Use(middleware.MemberAuth)
+	app.Use(middleware.RateLimit(middleware.RateLimitConfig{
+		Default: defaultRateLimit,
+		Routes:  routeRateLimits,
+	}))
+	app.Use(middleware.Idempotency(24 * time.Hour))
 
-	products := app.Group("/product")
-	products.Get("/", handlers.GetAllProductsEndpoint)
-	products.Get("/:id", handlers.FindAProductEndpoint)
-	products.Post("/", handlers.CreateProductEndpoint)
-	products.Put("/:id", handlers.UpdateProductEndpoint)
-	products.Delete("/:id", handlers.DeleteProductEndpoint)
-
-	app.Get("/swagger/*", swagger.HandlerDefault)
+	if validation != "off" {
+		app.Use(openapi.Validation(openapi.ValidationConfig{Responses: validation == "responses"}))
+	}
 
-	app.Get("/", welcomeToApi)
-	app.Use(notFound)
+	routes.Register(app, routes.Config{
+		UploadDir:    uploadDir,
+		FakePayments: fakePayments,
+	})
 
 	port := cmp.Or(os.Getenv("PORT"), "8000")
 	host := cmp.Or(os.Getenv("HOST"), "")
+	grpcPort := cmp.Or(os.Getenv("GRPC_PORT"), "9000")
+
+	// The gRPC API shares the store with the REST API, so Watch sees changes made through either
+	grpcServer := grpc.NewServer()
+	salv1.RegisterProductServiceServer(grpcServer, handlers.NewProductServer())
 
-	err := app.Listen(host + ":" + port)
+	listener, err := net.Listen("tcp", host+":"+grpcPort)
 	if err != nil {
-		log.Error(err)
+		log.Fatal("Error listening for gRPC: ", err)
 	}
-}
 
-func welcomeToApi(ctx *fiber.Ctx) error {
-	return ctx.Status(200).JSON(fiber.Map{
-		"message": "Welcome to the ShopAnythingLagos",
-		"live":    true,
-	})
-}
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Error("gRPC: ", err)
+		}
+	}()
 
-func notFound(ctx *fiber.Ctx) error {
-	return ctx.Status(404).JSON(fiber.Map{
-		"message": "Route not found",
-	})
+	err = app.Listen(host + ":" + port)
+	if err != nil {
+		log.Error(err)
+	}
 }
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"flag"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/rnwonder/SAL/internals/openapi"
+	"os"
+)
+
+// Writes the OpenAPI document of the API, run it from the root of the repo after changing a route or a type
+func main() {
+	output := flag.String("o", "docs/openapi.json", "the file to write the document to")
+	flag.Parse()
+
+	if err := os.WriteFile(*output, append(openapi.JSON(), '\n'), 0644); err != nil {
+		log.Fatal("Error writing the OpenAPI document: ", err)
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+)
+
+// GetAPIKeysEndpoint Get the API keys of a merchant
+func GetAPIKeysEndpoint(ctx *fiber.Ctx) error {
+	skuId, err := authorize(ctx, models.PermissionAPIKeysManage)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(types.GetAPIKeysResponse{
+		Message: "API keys fetched successfully",
+		APIKeys: models.MerchantAPIKeys(skuId),
+	})
+}
+
+// CreateAPIKeyEndpoint Create an API key
+func CreateAPIKeyEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.APIKeyCreatePayload)
+
+	skuId, err := authorize(ctx, models.PermissionAPIKeysManage)
+	if err != nil {
+		return err
+	}
+
+	// A key acts for the merchant without anyone signing in, so only a member can create one
+	if _, ok := middleware.RequestMember(ctx); !ok {
+		return problem.New(401, problem.CodeMerchantRequired, "Send the X-Member-Token of a manager to create API keys")
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	scopes := make([]string, 0, len(body.Scopes))
+	for _, scope := range body.Scopes {
+		scopes = append(scopes, utils.CopyString(scope))
+	}
+
+	key, secret, err := models.CreateAPIKey(utils.CopyString(skuId), utils.CopyString(body.Name), scopes)
+
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	return ctx.Status(201).JSON(types.APIKeyResponse{
+		Message: "API key created successfully",
+		APIKey:  key,
+		Secret:  secret,
+	})
+}
+
+// RevokeAPIKeyEndpoint Revoke an API key
+func RevokeAPIKeyEndpoint(ctx *fiber.Ctx) error {
+	key, err := ownedAPIKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err = models.RevokeAPIKey(key.Id)
+
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	return ctx.Status(200).JSON(types.APIKeyResponse{
+		Message: "API key revoked successfully",
+		APIKey:  key,
+	})
+}
+
+// RotateAPIKeyEndpoint Rotate an API key
+func RotateAPIKeyEndpoint(ctx *fiber.Ctx) error {
+	key, err := ownedAPIKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, secret, err := models.RotateAPIKey(key.Id)
+
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	return ctx.Status(200).JSON(types.APIKeyResponse{
+		Message: "API key rotated successfully",
+		APIKey:  key,
+		Secret:  secret,
+	})
+}
+
+// ownedAPIKey finds the API key in the id parameter and checks it belongs to the merchant
+func ownedAPIKey(ctx *fiber.Ctx) (models.APIKey, error) {
+	skuId, err := authorize(ctx, models.PermissionAPIKeysManage)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	key, ok := models.FindAPIKeyById(ctx.Params("id"))
+
+	if !ok {
+		return models.APIKey{}, apiKeyError(models.ErrAPIKeyNotFound)
+	}
+
+	if key.SkuId != skuId {
+		return models.APIKey{}, problem.New(403, problem.CodeForbidden, "You do not have permission to update this API key")
+	}
+
+	return key, nil
+}
+
+func apiKeyError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrAPIKeyNotFound):
+		return problem.New(404, problem.CodeAPIKeyNotFound, "API key not found")
+	case errors.Is(err, models.ErrAPIKeyRevoked):
+		return problem.New(409, problem.CodeAPIKeyRevoked, "API key has been revoked")
+	}
+	log.Error("Error managing API key: ", err)
+	return errInternal
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"cmp"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/util"
+	"time"
+)
+
+const (
+	AuditProductCreate  = "product.create"
+	AuditProductUpdate  = "product.update"
+	AuditProductDelete  = "product.delete"
+	AuditVariantCreate  = "variant.create"
+	AuditVariantUpdate  = "variant.update"
+	AuditVariantDelete  = "variant.delete"
+	AuditStockAdjust    = "stock.adjust"
+	AuditCategoryAssign = "product.categories.assign"
+	AuditCategoryRemove = "product.categories.remove"
+)
+
+// GetProductHistoryEndpoint Get the history of a product
+func GetProductHistoryEndpoint(ctx *fiber.Ctx) error {
+	skuId, err := authorize(ctx, models.PermissionAuditRead)
+	if err != nil {
+		return err
+	}
+
+	id := ctx.Params("id")
+	product, exists := models.FindProductById(id)
+
+	if exists && product.SkuId != skuId {
+		return problem.New(403, problem.CodeForbidden, "You do not have permission to view this product")
+	}
+
+	// Deleted products keep their history
+	entries := models.AuditEntries(models.AuditFilter{SkuId: skuId, ProductId: id})
+
+	if !exists && len(entries) == 0 {
+		return errProductNotFound
+	}
+
+	return auditPage(ctx, entries, "Product history fetched successfully")
+}
+
+// GetAuditLogEndpoint Get the audit log
+func GetAuditLogEndpoint(ctx *fiber.Ctx) error {
+	skuId, err := authorize(ctx, models.PermissionAuditRead)
+	if err != nil {
+		return err
+	}
+
+	filter := models.AuditFilter{
+		SkuId:        skuId,
+		ProductId:    ctx.Query("productId"),
+		ResourceType: ctx.Query("resourceType"),
+		Action:       ctx.Query("action"),
+		ActorId:      ctx.Query("actorId"),
+	}
+
+	for name, field := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := ctx.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+
+			if err != nil {
+				return problem.New(400, problem.CodeInvalidParameter, "Invalid "+name+" query parameter, use an RFC 3339 time")
+			}
+
+			*field = parsed
+		}
+	}
+
+	return auditPage(ctx, models.AuditEntries(filter), "Audit log fetched successfully")
+}
+
+func auditPage(ctx *fiber.Ctx, entries []models.AuditEntry, message string) error {
+	startIndex, endIndex, totalPages, limit, page := util.CalculatePageInfo(ctx.Query("page"), ctx.Query("limit"), len(entries))
+
+	return ctx.Status(200).JSON(types.GetAuditResponse{
+		Message: message,
+		Entries: entries[startIndex:endIndex],
+		Meta: types.AuditMeta{
+			CurrentPage: page,
+			Limit:       limit,
+			TotalPages:  totalPages,
+			Total:       len(entries),
+		},
+	})
+}
+
+// auditOrigin is who made a change and in which request, Fiber requests and gRPC calls both record it
+type auditOrigin struct {
+	actor     models.AuditActor
+	requestId string
+}
+
+func requestOrigin(ctx *fiber.Ctx) auditOrigin {
+	return auditOrigin{
+		actor:     auditActor(ctx),
+		requestId: utils.CopyString(cmp.Or(ctx.GetRespHeader(fiber.HeaderXRequestID), ctx.Get(fiber.HeaderXRequestID))),
+	}
+}
+
+// recordAudit fills in who made the change, the request id and the diff of before and after.
+// Pass nil as before for creations and as after for deletions.
+func recordAudit(ctx *fiber.Ctx, entry models.AuditEntry, before interface{}, after interface{}) {
+	requestOrigin(ctx).record(entry, before, after)
+}
+
+func (origin auditOrigin) record(entry models.AuditEntry, before interface{}, after interface{}) {
+	entry.Actor = origin.actor
+	entry.RequestId = origin.requestId
+	entry.Changes = models.DiffFields(before, after)
+	models.RecordAudit(entry)
+}
+
+func auditActor(ctx *fiber.Ctx) models.AuditActor {
+	if key, ok := middleware.RequestAPIKey(ctx); ok {
+		return models.AuditActor{Type: "apiKey", Id: key.Id}
+	}
+	if member, ok := middleware.RequestMember(ctx); ok {
+		return models.AuditActor{Type: "member", Id: member.Id, Email: member.Email}
+	}
+	return models.AuditActor{Type: "merchant", Id: utils.CopyString(middleware.MerchantId(ctx))}
+}
+
+func productAuditEntry(action string, product models.Product) models.AuditEntry {
+	return models.AuditEntry{
+		Action:       action,
+		SkuId:        product.SkuId,
+		ProductId:    product.Id,
+		ResourceType: "product",
+		ResourceId:   product.Id,
+	}
+}
+
+func variantAuditEntry(action string, product models.Product, variant models.Variant) models.AuditEntry {
+	return models.AuditEntry{
+		Action:       action,
+		SkuId:        product.SkuId,
+		ProductId:    product.Id,
+		ResourceType: "variant",
+		ResourceId:   variant.Id,
+	}
+}
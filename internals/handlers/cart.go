@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+	"slices"
+	"time"
+)
+
+// CreateCartEndpoint Create a cart
+func CreateCartEndpoint(ctx *fiber.Ctx) error {
+	cart := models.CreateCart()
+
+	return ctx.Status(201).JSON(types.CartResponse{
+		Message: "Cart created successfully",
+		Cart:    priceCart(cart),
+	})
+}
+
+// GetCartEndpoint Get a cart
+func GetCartEndpoint(ctx *fiber.Ctx) error {
+	cart, ok := models.FindCartById(ctx.Params("id"))
+
+	if !ok {
+		return cartError(models.ErrCartNotFound)
+	}
+
+	return ctx.Status(200).JSON(types.CartResponse{
+		Message: "Cart fetched successfully",
+		Cart:    priceCart(cart),
+	})
+}
+
+// DeleteCartEndpoint Delete a cart
+func DeleteCartEndpoint(ctx *fiber.Ctx) error {
+	if !models.DeleteCart(ctx.Params("id")) {
+		return cartError(models.ErrCartNotFound)
+	}
+
+	return ctx.Status(200).JSON(types.MessageResponse{
+		Message: "Cart deleted successfully",
+	})
+}
+
+// AddCartItemEndpoint Add an item to a cart
+func AddCartItemEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.CartItemPayload)
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	// Deleted products and variants cannot be added
+	if _, _, err := models.UnitPrice(body.ProductId, body.VariantId); err != nil {
+		return cartError(err)
+	}
+
+	productId, variantId := utils.CopyString(body.ProductId), utils.CopyString(body.VariantId)
+	cart, err := models.UpdateCart(ctx.Params("id"), func(cart *models.Cart) error {
+		cart.AddItem(productId, variantId, body.Quantity)
+		return nil
+	})
+
+	if err != nil {
+		return cartError(err)
+	}
+
+	return ctx.Status(200).JSON(types.CartResponse{
+		Message: "Item added to cart successfully",
+		Cart:    priceCart(cart),
+	})
+}
+
+// UpdateCartItemEndpoint Update an item in a cart
+func UpdateCartItemEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.CartItemUpdatePayload)
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	itemId := ctx.Params("itemId")
+	cart, err := models.UpdateCart(ctx.Params("id"), func(cart *models.Cart) error {
+		return cart.SetItemQuantity(itemId, body.Quantity)
+	})
+
+	if err != nil {
+		return cartError(err)
+	}
+
+	return ctx.Status(200).JSON(types.CartResponse{
+		Message: "Cart item updated successfully",
+		Cart:    priceCart(cart),
+	})
+}
+
+// RemoveCartItemEndpoint Remove an item from a cart
+func RemoveCartItemEndpoint(ctx *fiber.Ctx) error {
+	itemId := ctx.Params("itemId")
+	cart, err := models.UpdateCart(ctx.Params("id"), func(cart *models.Cart) error {
+		return cart.RemoveItem(itemId)
+	})
+
+	if err != nil {
+		return cartError(err)
+	}
+
+	return ctx.Status(200).JSON(types.CartResponse{
+		Message: "Cart item removed successfully",
+		Cart:    priceCart(cart),
+	})
+}
+
+// AddCartCouponEndpoint Add a coupon to a cart
+func AddCartCouponEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.CartCouponPayload)
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	cart, ok := models.FindCartById(ctx.Params("id"))
+
+	if !ok {
+		return cartError(models.ErrCartNotFound)
+	}
+
+	merchants := cartMerchants(cart)
+	if body.SkuId != "" {
+		merchants = []string{body.SkuId}
+	}
+
+	coupon, err := findCoupon(merchants, body.Code, time.Now())
+
+	if err != nil {
+		return cartError(err)
+	}
+
+	cart, err = models.UpdateCart(ctx.Params("id"), func(cart *models.Cart) error {
+		cart.AddCoupon(coupon.Code)
+		return nil
+	})
+
+	if err != nil {
+		return cartError(err)
+	}
+
+	return ctx.Status(200).JSON(types.CartResponse{
+		Message: "Coupon added to cart successfully",
+		Cart:    priceCart(cart),
+	})
+}
+
+// findCoupon returns the first coupon with the code that one of the merchants can still redeem
+func findCoupon(merchants []string, code string, now time.Time) (models.Promotion, error) {
+	err := models.ErrCouponNotFound
+	for _, skuId := range merchants {
+		coupon, findErr := models.FindCoupon(skuId, code, now)
+		if findErr == nil {
+			return coupon, nil
+		}
+		if errors.Is(findErr, models.ErrCouponUnavailable) {
+			err = findErr
+		}
+	}
+	return models.Promotion{}, err
+}
+
+// cartMerchants returns the merchants selling the items of a cart
+func cartMerchants(cart models.Cart) []string {
+	merchants := make([]string, 0)
+	for _, item := range cart.Items {
+		if product, ok := models.FindProductById(item.ProductId); ok && !slices.Contains(merchants, product.SkuId) {
+			merchants = append(merchants, product.SkuId)
+		}
+	}
+	return merchants
+}
+
+// RemoveCartCouponEndpoint Remove a coupon from a cart
+func RemoveCartCouponEndpoint(ctx *fiber.Ctx) error {
+	code := ctx.Params("code")
+	cart, err := models.UpdateCart(ctx.Params("id"), func(cart *models.Cart) error {
+		return cart.RemoveCoupon(code)
+	})
+
+	if err != nil {
+		return cartError(err)
+	}
+
+	return ctx.Status(200).JSON(types.CartResponse{
+		Message: "Coupon removed from cart successfully",
+		Cart:    priceCart(cart),
+	})
+}
+
+// priceCart prices every line with the current catalog, the best promotion for it and its tax. Lines whose
+// product or variant has been deleted are dropped from the stored cart and reported in RemovedItems.
+func priceCart(cart models.Cart) types.CartView {
+	now := time.Now()
+	view := types.CartView{
+		Id:           cart.Id,
+		Items:        make([]types.CartLine, 0, len(cart.Items)),
+		RemovedItems: make([]models.CartItem, 0),
+		Coupons:      cart.Coupons,
+		CreatedAt:    cart.CreatedAt,
+		ExpiresAt:    cart.ExpiresAt(),
+	}
+
+	for _, item := range cart.Items {
+		unitPrice, product, err := models.UnitPrice(item.ProductId, item.VariantId)
+
+		if err != nil {
+			view.RemovedItems = append(view.RemovedItems, item)
+			continue
+		}
+
+		line := types.CartLine{
+			Id:        item.Id,
+			ProductId: item.ProductId,
+			VariantId: item.VariantId,
+			SkuId:     product.SkuId,
+			Name:      product.Name,
+			UnitPrice: unitPrice,
+			Quantity:  item.Quantity,
+		}
+
+		if promotion, ok := models.BestPromotion(product, unitPrice, cart.Coupons, now); ok {
+			line.Promotion = &promotion
+			line.Discount = promotion.Discount * float32(item.Quantity)
+		}
+
+		line.LineTotal = unitPrice*float32(item.Quantity) - line.Discount
+		line.Tax = models.ProductTax(product, line.LineTotal)
+
+		view.Items = append(view.Items, line)
+		view.ItemCount += line.Quantity
+		view.Subtotal += unitPrice * float32(item.Quantity)
+		view.Discount += line.Discount
+		view.Tax += line.Tax.Tax
+		view.Total += line.Tax.Gross
+	}
+
+	if len(view.RemovedItems) > 0 {
+		_, _ = models.UpdateCart(cart.Id, func(cart *models.Cart) error {
+			for _, item := range view.RemovedItems {
+				_ = cart.RemoveItem(item.Id)
+			}
+			return nil
+		})
+	}
+
+	return view
+}
+
+func cartError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrCartNotFound):
+		return problem.New(404, problem.CodeCartNotFound, "Cart not found or expired")
+	case errors.Is(err, models.ErrCartItemNotFound):
+		return problem.New(404, problem.CodeCartItemNotFound, "Cart item not found")
+	case errors.Is(err, models.ErrProductNotFound):
+		return errProductNotFound
+	case errors.Is(err, models.ErrVariantNotFound):
+		return errVariantNotFound
+	case errors.Is(err, models.ErrCouponNotFound):
+		return problem.New(404, problem.CodeCouponNotFound, "Coupon not found")
+	case errors.Is(err, models.ErrCouponUnavailable):
+		return problem.New(409, problem.CodeCouponUnavailable, "Coupon has expired or has been used up")
+	}
+	return errInternal
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+	"time"
+)
+
+// GetAllCategoriesEndpoint Get all categories
+func GetAllCategoriesEndpoint(ctx *fiber.Ctx) error {
+	var categories []models.Category
+
+	if parentId, ok := ctx.Queries()["parentId"]; ok {
+		categories = models.CategoryChildren(parentId)
+	} else {
+		categories = models.GetAllCategories()
+	}
+
+	return ctx.Status(200).JSON(types.GetCategoriesResponse{
+		Message:    "Categories fetched successfully",
+		Categories: categories,
+	})
+}
+
+// FindACategoryEndpoint Get a category
+func FindACategoryEndpoint(ctx *fiber.Ctx) error {
+	category, ok := models.FindCategoryById(ctx.Params("id"))
+
+	if !ok {
+		return errCategoryNotFound
+	}
+
+	return ctx.Status(200).JSON(types.OneCategoryResponse{
+		Message:  "Category fetched successfully",
+		Category: category,
+		Children: models.CategoryChildren(category.Id),
+	})
+}
+
+// CreateCategoryEndpoint Create a category
+func CreateCategoryEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.CategoryCreatePayload)
+	skuId, err := authorize(ctx, models.PermissionProductsCreate)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	if body.ParentId != "" {
+		if _, ok := models.FindCategoryById(body.ParentId); !ok {
+			return errParentNotFound
+		}
+	}
+
+	newCategory := models.Category{
+		Id:          uuid.Must(uuid.NewRandom()).String(),
+		SkuId:       utils.CopyString(skuId),
+		Name:        body.Name,
+		Description: body.Description,
+		ParentId:    body.ParentId,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	models.SaveCategory(newCategory)
+
+	return ctx.Status(201).JSON(types.OneCategoryResponse{
+		Message:  "Category created successfully",
+		Category: newCategory,
+		Children: []models.Category{},
+	})
+}
+
+// UpdateCategoryEndpoint Update a category
+func UpdateCategoryEndpoint(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	body := new(types.CategoryUpdatePayload)
+	skuId, err := authorize(ctx, models.PermissionProductsUpdate)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	category, ok := models.FindCategoryById(id)
+
+	if !ok {
+		return errCategoryNotFound
+	}
+
+	if category.SkuId != skuId {
+		return errCategoryForbidden
+	}
+
+	if body.ParentId != nil && *body.ParentId != "" {
+		if _, ok := models.FindCategoryById(*body.ParentId); !ok {
+			return errParentNotFound
+		}
+
+		if models.IsCategoryDescendant(category.Id, *body.ParentId) {
+			return problem.New(409, problem.CodeCategoryCycle, "A category cannot be moved below itself or one of its children")
+		}
+	}
+
+	if body.Name != "" {
+		category.Name = body.Name
+	}
+
+	if body.Description != "" {
+		category.Description = body.Description
+	}
+
+	if body.ParentId != nil {
+		category.ParentId = *body.ParentId
+	}
+
+	category.UpdatedAt = time.Now()
+	models.SaveCategory(category)
+
+	return ctx.Status(200).JSON(types.OneCategoryResponse{
+		Message:  "Category updated successfully",
+		Category: category,
+		Children: models.CategoryChildren(category.Id),
+	})
+}
+
+// DeleteCategoryEndpoint Delete a category
+func DeleteCategoryEndpoint(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	skuId, err := authorize(ctx, models.PermissionProductsDelete)
+	if err != nil {
+		return err
+	}
+
+	category, ok := models.FindCategoryById(id)
+
+	if !ok {
+		return errCategoryNotFound
+	}
+
+	if category.SkuId != skuId {
+		return problem.New(403, problem.CodeForbidden, "You do not have permission to delete this category")
+	}
+
+	if len(models.CategoryChildren(category.Id)) > 0 {
+		return problem.New(409, problem.CodeCategoryHasChildren, "Category has children, delete or move them first")
+	}
+
+	models.DeleteCategory(category.Id)
+
+	return ctx.Status(200).JSON(types.MessageResponse{
+		Message: "Category deleted successfully",
+	})
+}
+
+// AssignProductCategoriesEndpoint Assign categories to a product
+func AssignProductCategoriesEndpoint(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	body := new(types.ProductCategoriesPayload)
+	skuId, err := authorize(ctx, models.PermissionProductsUpdate)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	product, ok := models.FindProductById(id)
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	if product.SkuId != skuId {
+		return errProductForbidden
+	}
+
+	for _, categoryId := range body.CategoryIds {
+		if _, ok := models.FindCategoryById(categoryId); !ok {
+			return problem.New(404, problem.CodeCategoryNotFound, "Category not found: "+categoryId)
+		}
+	}
+
+	var before models.Product
+	product, ok = models.UpdateProduct(product.Id, func(product *models.Product) {
+		before = *product
+
+		for _, categoryId := range body.CategoryIds {
+			if !product.HasCategory(categoryId) {
+				product.CategoryIds = append(product.CategoryIds, categoryId)
+			}
+		}
+	})
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	recordAudit(ctx, productAuditEntry(AuditCategoryAssign, product), before, product)
+
+	return ctx.Status(200).JSON(types.OneProductResponse{
+		Message: "Product categories updated successfully",
+		Product: productView(product),
+	})
+}
+
+// RemoveProductCategoryEndpoint Remove a category from a product
+func RemoveProductCategoryEndpoint(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	categoryId := ctx.Params("categoryId")
+	skuId, err := authorize(ctx, models.PermissionProductsUpdate)
+	if err != nil {
+		return err
+	}
+
+	product, ok := models.FindProductById(id)
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	if product.SkuId != skuId {
+		return errProductForbidden
+	}
+
+	if !product.HasCategory(categoryId) {
+		return problem.New(404, problem.CodeCategoryNotFound, "Product is not in this category")
+	}
+
+	var before models.Product
+	product, ok = models.UpdateProduct(product.Id, func(product *models.Product) {
+		before = *product
+		product.RemoveCategory(categoryId)
+	})
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	recordAudit(ctx, productAuditEntry(AuditCategoryRemove, product), before, product)
+
+	return ctx.Status(200).JSON(types.OneProductResponse{
+		Message: "Product categories updated successfully",
+		Product: productView(product),
+	})
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import "github.com/rnwonder/SAL/internals/problem"
+
+// Problems returned by several handlers, handlers with a single use build theirs in place
+var (
+	errInvalidPayload   = problem.New(400, problem.CodeInvalidPayload, "Invalid request payload")
+	errMerchantRequired = problem.New(401, problem.CodeMerchantRequired, "Invalid request please provide skuId query parameter")
+	errRoleForbidden    = problem.New(403, problem.CodeInsufficientRole, "Your role does not allow you to do this")
+	errProductForbidden = problem.New(403, problem.CodeForbidden, "You do not have permission to update this product")
+	// Updating the product of another merchant has always answered 409, clients rely on it
+	errProductConflict   = problem.New(409, problem.CodeForbidden, "You do not have permission to update this product")
+	errCategoryForbidden = problem.New(403, problem.CodeForbidden, "You do not have permission to update this category")
+	errProductNotFound   = problem.New(404, problem.CodeProductNotFound, "Product not found")
+	errVariantNotFound   = problem.New(404, problem.CodeVariantNotFound, "Variant not found")
+	errCategoryNotFound  = problem.New(404, problem.CodeCategoryNotFound, "Category not found")
+	errParentNotFound    = problem.New(404, problem.CodeCategoryNotFound, "Parent category not found")
+	errVariantSkuExists  = problem.New(409, problem.CodeVariantExists, "A variant with this skuId already exists")
+	errVariantOptsExists = problem.New(409, problem.CodeVariantExists, "A variant with these options already exists")
+	errPaymentsDisabled  = problem.New(500, problem.CodeInternal, "Payments are not configured")
+	errInternal          = problem.New(500, problem.CodeInternal, "Something went wrong")
+)
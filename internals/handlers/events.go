@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProductEventsHeartbeat is how often a comment is sent on a quiet stream, it keeps proxies from closing the
+// connection and notices clients that left
+var ProductEventsHeartbeat = 15 * time.Second
+
+// ProductEventsBuffer is how many changes a stream may fall behind before it is closed, the client then
+// reconnects and resumes with Last-Event-ID
+var ProductEventsBuffer = 256
+
+// ProductEventsEndpoint Stream product changes
+func ProductEventsEndpoint(ctx *fiber.Ctx) error {
+	skuId := ctx.Query("skuId")
+
+	var lastId uint64
+	var epoch string
+	resume := ctx.Get("Last-Event-ID") != ""
+	if resume {
+		var err error
+		epoch, lastId, err = parseProductEventId(ctx.Get("Last-Event-ID"))
+		if err != nil {
+			return problem.New(400, problem.CodeInvalidParameter, "Invalid Last-Event-ID please use the id of the last message received")
+		}
+	}
+
+	// Subscribing before reading the history means no change falls in between, the ones in both are skipped
+	events, unsubscribe := models.SubscribeProductEvents(ProductEventsBuffer)
+	missed, complete := []models.ProductEvent(nil), true
+	if resume && epoch == models.ProductEventEpoch {
+		// An id ahead of every change kept follows the oldest kept change so new changes are not taken for ones
+		// already sent
+		missed, lastId, complete = models.ProductEventsSince(lastId)
+	} else if resume {
+		// The id was sent by an earlier run of the server, its changes cannot be told apart from the ones of this run
+		missed, lastId, _ = models.ProductEventsSince(0)
+		complete = false
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set("X-Accel-Buffering", "no")
+	ctx.Status(200)
+
+	done := ctx.Context().Done()
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		// Nothing reaches the client, not even the headers, until the first write
+		_, _ = w.WriteString(": connected\n\n")
+
+		// The changes since Last-Event-ID are no longer all kept, the client has to fetch the products again
+		if !complete {
+			data, _ := json.Marshal(types.MessageResponse{Message: "Some changes were missed, fetch the products again"})
+			fmt.Fprintf(w, "event: reset\ndata: %s\n\n", data)
+		}
+
+		for _, event := range missed {
+			writeProductEvent(w, skuId, event)
+			lastId = event.Id
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(ProductEventsHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-events:
+				// The stream fell behind and was unsubscribed
+				if !ok {
+					return
+				}
+				if event.Id <= lastId {
+					continue
+				}
+				lastId = event.Id
+
+				if writeProductEvent(w, skuId, event) {
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			case <-heartbeat.C:
+				_, _ = w.WriteString(": heartbeat\n\n")
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeProductEvent writes event as a message unless it is filtered out by skuId and reports whether it did
+func writeProductEvent(w *bufio.Writer, skuId string, event models.ProductEvent) bool {
+	if skuId != "" && event.Product.SkuId != skuId {
+		return false
+	}
+
+	data, _ := json.Marshal(types.ProductEventData{Type: event.Type, Product: productView(event.Product), At: event.At})
+	fmt.Fprintf(w, "id: %s-%d\nevent: %s\ndata: %s\n\n", models.ProductEventEpoch, event.Id, event.Type, data)
+	return true
+}
+
+// parseProductEventId reads an id written as epoch-id, ids of servers from before epochs are only a number
+func parseProductEventId(value string) (string, uint64, error) {
+	epoch, id, ok := strings.Cut(value, "-")
+	if !ok {
+		epoch, id = "", value
+	}
+
+	lastId, err := strconv.ParseUint(id, 10, 64)
+	return epoch, lastId, err
+}
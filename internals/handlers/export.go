@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"strconv"
+	"time"
+)
+
+// Number of products written between flushes of the response stream
+const exportFlushEvery = 100
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"json":   fiber.MIMEApplicationJSONCharsetUTF8,
+}
+
+var exportCsvHeader = []string{"id", "skuId", "name", "description", "price", "createdAt", "updatedAt"}
+
+// ExportProductsEndpoint Export products
+func ExportProductsEndpoint(ctx *fiber.Ctx) error {
+	format := cmp.Or(ctx.Query("format"), "csv")
+	contentType, ok := exportContentTypes[format]
+
+	if !ok {
+		return problem.New(400, problem.CodeInvalidParameter, "Invalid format please use one of csv, ndjson or json")
+	}
+
+	filter := productFilterFromQuery(ctx)
+	filename := fmt.Sprintf("products-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(200)
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var err error
+		switch format {
+		case "csv":
+			err = writeProductsCsv(w, filter)
+		case "ndjson":
+			err = writeProductsNdjson(w, filter)
+		case "json":
+			err = writeProductsJson(w, filter)
+		}
+
+		if err != nil {
+			log.Error("Error exporting products: ", err)
+		}
+	})
+
+	return nil
+}
+
+func writeProductsCsv(w *bufio.Writer, filter models.ProductFilter) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportCsvHeader); err != nil {
+		return err
+	}
+
+	count := 0
+	err := models.EachProduct(filter, func(product models.Product) error {
+		if err := writer.Write([]string{
+			product.Id,
+			product.SkuId,
+			product.Name,
+			product.Description,
+			strconv.FormatFloat(float64(product.Price), 'f', -1, 32),
+			product.CreatedAt.Format(time.RFC3339),
+			product.UpdatedAt.Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushEvery == 0 {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return err
+			}
+			return w.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func writeProductsNdjson(w *bufio.Writer, filter models.ProductFilter) error {
+	encoder := json.NewEncoder(w)
+
+	count := 0
+	err := models.EachProduct(filter, func(product models.Product) error {
+		// Encode terminates every value with a newline
+		if err := encoder.Encode(product); err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushEvery == 0 {
+			return w.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func writeProductsJson(w *bufio.Writer, filter models.ProductFilter) error {
+	if _, err := w.WriteString("["); err != nil {
+		return err
+	}
+
+	count := 0
+	err := models.EachProduct(filter, func(product models.Product) error {
+		if count > 0 {
+			if _, err := w.WriteString(","); err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(product)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushEvery == 0 {
+			return w.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.WriteString("]"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,573 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+	"strconv"
+	"sync"
+)
+
+// GraphQLEndpoint Run a GraphQL query
+func GraphQLEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.GraphQLRequest)
+
+	if err := middleware.CheckScope(ctx, models.ScopeProductsRead); err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         *graphqlSchema(),
+		RequestString:  body.Query,
+		VariableValues: body.Variables,
+		OperationName:  body.OperationName,
+		Context:        context.WithValue(ctx.UserContext(), graphqlCtxKey{}, ctx),
+	})
+
+	// Errors are part of a GraphQL response, the status stays 200 like other GraphQL servers
+	return ctx.Status(200).JSON(graphqlResponse(result))
+}
+
+type graphqlCtxKey struct{}
+
+// graphqlRequest returns the request a resolver runs for, resolvers run one at a time while the handler waits
+func graphqlRequest(params graphql.ResolveParams) *fiber.Ctx {
+	return params.Context.Value(graphqlCtxKey{}).(*fiber.Ctx)
+}
+
+func graphqlResponse(result *graphql.Result) types.GraphQLResponse {
+	response := types.GraphQLResponse{}
+	response.Data, _ = result.Data.(map[string]interface{})
+
+	for _, err := range result.Errors {
+		graphqlError := types.GraphQLError{
+			Message:    err.Message,
+			Path:       err.Path,
+			Extensions: err.Extensions,
+		}
+		for _, location := range err.Locations {
+			graphqlError.Locations = append(graphqlError.Locations, types.GraphQLLocation{Line: location.Line, Column: location.Column})
+		}
+		response.Errors = append(response.Errors, graphqlError)
+	}
+	return response
+}
+
+// graphqlProblem carries the problem a REST request would get into the extensions of a GraphQL error
+type graphqlProblem struct {
+	*problem.Problem
+}
+
+var _ gqlerrors.ExtendedError = graphqlProblem{}
+
+func (err graphqlProblem) Error() string {
+	return err.Detail
+}
+
+func (err graphqlProblem) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{
+		"code":   err.Code,
+		"status": err.Status,
+	}
+	if len(err.Errors) > 0 {
+		extensions["errors"] = err.Errors
+	}
+	return extensions
+}
+
+// graphqlResolver turns the errors of a resolver into problems
+func graphqlResolver(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		value, err := resolve(params)
+		if err != nil {
+			return nil, graphqlProblem{problem.From(err)}
+		}
+		return value, nil
+	}
+}
+
+// graphqlInput decodes an input object argument into the payload of the matching REST request
+func graphqlInput(params graphql.ResolveParams, payload interface{}) error {
+	data, err := json.Marshal(params.Args["input"])
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return errInvalidPayload
+	}
+	return validators.Validator(payload, graphqlRequest(params).Get(fiber.HeaderAcceptLanguage))
+}
+
+// graphqlProduct is the source of the Product type, the prices are only worked out when a query asks for them
+type graphqlProduct struct {
+	models.Product
+	view func() types.ProductView
+}
+
+func newGraphQLProduct(product models.Product) *graphqlProduct {
+	return &graphqlProduct{
+		Product: product,
+		view: sync.OnceValue(func() types.ProductView {
+			return productView(product)
+		}),
+	}
+}
+
+func newGraphQLProducts(products []models.Product) []*graphqlProduct {
+	nodes := make([]*graphqlProduct, 0, len(products))
+	for _, product := range products {
+		nodes = append(nodes, newGraphQLProduct(product))
+	}
+	return nodes
+}
+
+// graphqlMerchant is the source of the Merchant type, merchants only exist through the skuId of what they own
+type graphqlMerchant struct {
+	SkuId string
+}
+
+type graphqlProductPage struct {
+	products []models.Product
+	meta     types.Meta
+}
+
+// graphqlFloat widens a price without the noise float64(price) adds, 19.99 stays 19.99
+func graphqlFloat(value float32) float64 {
+	widened, _ := strconv.ParseFloat(strconv.FormatFloat(float64(value), 'g', -1, 32), 64)
+	return widened
+}
+
+func graphqlProductField(fieldType graphql.Output, description string, resolve func(product *graphqlProduct) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type:        fieldType,
+		Description: description,
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return resolve(params.Source.(*graphqlProduct)), nil
+		},
+	}
+}
+
+// graphqlProductArgs are the arguments of every product listing, they mirror the query parameters of GET /product
+func graphqlProductArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
+		"search":    {Type: graphql.String, Description: "Only products whose name contains the text"},
+		"sortKey":   {Type: graphqlSortKeyEnum, DefaultValue: "createdAt"},
+		"sortOrder": {Type: graphqlSortOrderEnum, DefaultValue: "desc"},
+		"category":  {Type: graphql.ID, Description: "Only products in the category or one of its descendants"},
+		"inStock":   {Type: graphql.Boolean, Description: "Only products that are in stock, or out of stock when false"},
+		"page":      {Type: graphql.Int, DefaultValue: 1, Description: "The page to return, starting at 1"},
+		"limit":     {Type: graphql.Int, DefaultValue: 10, Description: "The number of products per page"},
+	}
+	for name, arg := range extra {
+		args[name] = arg
+	}
+	return args
+}
+
+// graphqlProducts lists the products like GET /product, base holds the filters of the parent field
+func graphqlProducts(params graphql.ResolveParams, base models.ProductFilter) (interface{}, error) {
+	arguments := productListArguments{}
+	arguments.Page, _ = params.Args["page"].(int)
+	arguments.Limit, _ = params.Args["limit"].(int)
+	sortKey, _ := params.Args["sortKey"].(string)
+	sortOrder, _ := params.Args["sortOrder"].(string)
+	arguments.SortKey = cmp.Or(sortKey, "createdAt")
+	arguments.SortOrder = cmp.Or(sortOrder, "desc")
+
+	if err := validators.Validator(&arguments, graphqlRequest(params).Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return nil, err
+	}
+
+	filter := base
+	filter.SortKey = arguments.SortKey
+	filter.SortOrder = arguments.SortOrder
+
+	if search, ok := params.Args["search"].(string); ok {
+		filter.Search = search
+	}
+	if category, ok := params.Args["category"].(string); ok {
+		filter.Categories = models.CategoryDescendantIds(category)
+	}
+	if inStock, ok := params.Args["inStock"].(bool); ok {
+		filter.InStock = &inStock
+	}
+	if merchant, ok := params.Args["merchant"].(string); ok {
+		filter.SkuId = merchant
+	}
+
+	products, meta := listProducts(filter, strconv.Itoa(arguments.Page), strconv.Itoa(arguments.Limit))
+	return graphqlProductPage{products: products, meta: meta}, nil
+}
+
+var (
+	graphqlSortKeyEnum = graphql.NewEnum(graphql.EnumConfig{
+		Name: "ProductSortKey",
+		Values: graphql.EnumValueConfigMap{
+			"name":      {Value: "name"},
+			"price":     {Value: "price"},
+			"createdAt": {Value: "createdAt"},
+		},
+	})
+	graphqlSortOrderEnum = graphql.NewEnum(graphql.EnumConfig{
+		Name: "SortOrder",
+		Values: graphql.EnumValueConfigMap{
+			"asc":  {Value: "asc"},
+			"desc": {Value: "desc"},
+		},
+	})
+	graphqlTaxClassEnum = graphql.NewEnum(graphql.EnumConfig{
+		Name: "TaxClass",
+		Values: graphql.EnumValueConfigMap{
+			"standard": {Value: models.TaxStandard},
+			"reduced":  {Value: models.TaxReduced},
+			"zero":     {Value: models.TaxZero},
+			"exempt":   {Value: models.TaxExempt},
+		},
+	})
+)
+
+// graphqlSchema is built on first use, building it checks every type so a mistake panics in the tests
+var graphqlSchema = sync.OnceValue(func() *graphql.Schema {
+	schema, err := graphql.NewSchema(newGraphQLSchemaConfig())
+	if err != nil {
+		panic(err)
+	}
+	return &schema
+})
+
+func newGraphQLSchemaConfig() graphql.SchemaConfig {
+	var productType, merchantType, categoryType, productPageType *graphql.Object
+
+	pageMetaType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageMeta",
+		Fields: graphql.Fields{
+			"currentPage":   {Type: graphql.NewNonNull(graphql.Int)},
+			"limit":         {Type: graphql.NewNonNull(graphql.Int)},
+			"totalPages":    {Type: graphql.NewNonNull(graphql.Int)},
+			"totalProducts": {Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	productType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": graphqlProductField(graphql.NewNonNull(graphql.ID), "", func(product *graphqlProduct) interface{} {
+					return product.Id
+				}),
+				"skuId": graphqlProductField(graphql.NewNonNull(graphql.String), "The merchant of the product", func(product *graphqlProduct) interface{} {
+					return product.SkuId
+				}),
+				"name": graphqlProductField(graphql.NewNonNull(graphql.String), "", func(product *graphqlProduct) interface{} {
+					return product.Name
+				}),
+				"description": graphqlProductField(graphql.NewNonNull(graphql.String), "", func(product *graphqlProduct) interface{} {
+					return product.Description
+				}),
+				"price": graphqlProductField(graphql.NewNonNull(graphql.Float), "The price set by the merchant", func(product *graphqlProduct) interface{} {
+					return graphqlFloat(product.Price)
+				}),
+				"effectivePrice": graphqlProductField(graphql.NewNonNull(graphql.Float), "The price including any active price schedule", func(product *graphqlProduct) interface{} {
+					return graphqlFloat(product.view().EffectivePrice)
+				}),
+				"discountedPrice": graphqlProductField(graphql.NewNonNull(graphql.Float), "The effective price after the best automatic promotion", func(product *graphqlProduct) interface{} {
+					return graphqlFloat(product.view().DiscountedPrice)
+				}),
+				"taxClass": graphqlProductField(graphqlTaxClassEnum, "Empty for products created before tax classes, they are taxed as standard", func(product *graphqlProduct) interface{} {
+					return product.TaxClass
+				}),
+				"stock": graphqlProductField(graphql.NewNonNull(graphql.Int), "", func(product *graphqlProduct) interface{} {
+					return product.Stock
+				}),
+				"lowStockThreshold": graphqlProductField(graphql.NewNonNull(graphql.Int), "", func(product *graphqlProduct) interface{} {
+					return product.LowStockThreshold
+				}),
+				"variantCount": graphqlProductField(graphql.NewNonNull(graphql.Int), "", func(product *graphqlProduct) interface{} {
+					return product.view().VariantCount
+				}),
+				"createdAt": graphqlProductField(graphql.NewNonNull(graphql.DateTime), "", func(product *graphqlProduct) interface{} {
+					return product.CreatedAt
+				}),
+				"updatedAt": graphqlProductField(graphql.NewNonNull(graphql.DateTime), "", func(product *graphqlProduct) interface{} {
+					return product.UpdatedAt
+				}),
+				"merchant": graphqlProductField(graphql.NewNonNull(merchantType), "", func(product *graphqlProduct) interface{} {
+					return graphqlMerchant{SkuId: product.SkuId}
+				}),
+				"categories": graphqlProductField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))), "", func(product *graphqlProduct) interface{} {
+					categories := make([]models.Category, 0, len(product.CategoryIds))
+					for _, id := range product.CategoryIds {
+						if category, ok := models.FindCategoryById(id); ok {
+							categories = append(categories, category)
+						}
+					}
+					return categories
+				}),
+			}
+		}),
+	})
+
+	productPageType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "ProductPage",
+		Fields: graphql.Fields{
+			"products": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))),
+				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					return newGraphQLProducts(params.Source.(graphqlProductPage).products), nil
+				},
+			},
+			"meta": {
+				Type: graphql.NewNonNull(pageMetaType),
+				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					return params.Source.(graphqlProductPage).meta, nil
+				},
+			},
+		},
+	})
+
+	merchantType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Merchant",
+		Description: "A merchant, identified by the skuId of the products it sells",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"skuId": {Type: graphql.NewNonNull(graphql.String)},
+				"products": {
+					Type: graphql.NewNonNull(productPageType),
+					Args: graphqlProductArgs(nil),
+					Resolve: graphqlResolver(func(params graphql.ResolveParams) (interface{}, error) {
+						return graphqlProducts(params, models.ProductFilter{SkuId: params.Source.(graphqlMerchant).SkuId})
+					}),
+				},
+				"categories": {
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))),
+					Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+						skuId := params.Source.(graphqlMerchant).SkuId
+
+						categories := make([]models.Category, 0)
+						for _, category := range models.GetAllCategories() {
+							if category.SkuId == skuId {
+								categories = append(categories, category)
+							}
+						}
+						return categories, nil
+					},
+				},
+			}
+		}),
+	})
+
+	categoryType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Category",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          {Type: graphql.NewNonNull(graphql.ID)},
+				"name":        {Type: graphql.NewNonNull(graphql.String)},
+				"description": {Type: graphql.NewNonNull(graphql.String)},
+				"createdAt":   {Type: graphql.NewNonNull(graphql.DateTime)},
+				"updatedAt":   {Type: graphql.NewNonNull(graphql.DateTime)},
+				"merchant": {
+					Type: graphql.NewNonNull(merchantType),
+					Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+						return graphqlMerchant{SkuId: params.Source.(models.Category).SkuId}, nil
+					},
+				},
+				"parent": {
+					Type: categoryType,
+					Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+						if parent, ok := models.FindCategoryById(params.Source.(models.Category).ParentId); ok {
+							return parent, nil
+						}
+						return nil, nil
+					},
+				},
+				"children": {
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))),
+					Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+						return models.CategoryChildren(params.Source.(models.Category).Id), nil
+					},
+				},
+				"products": {
+					Type:        graphql.NewNonNull(productPageType),
+					Description: "The products in the category or one of its descendants",
+					Args:        graphqlProductArgs(nil),
+					Resolve: graphqlResolver(func(params graphql.ResolveParams) (interface{}, error) {
+						filter := models.ProductFilter{Categories: models.CategoryDescendantIds(params.Source.(models.Category).Id)}
+						return graphqlProducts(params, filter)
+					}),
+				},
+			}
+		}),
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"products": {
+				Type:        graphql.NewNonNull(productPageType),
+				Description: "Search, sort and page the products like GET /product",
+				Args: graphqlProductArgs(graphql.FieldConfigArgument{
+					"merchant": {Type: graphql.String, Description: "Only products of the merchant with this skuId"},
+				}),
+				Resolve: graphqlResolver(func(params graphql.ResolveParams) (interface{}, error) {
+					return graphqlProducts(params, models.ProductFilter{})
+				}),
+			},
+			"product": {
+				Type:        productType,
+				Description: "Null when there is no product with the id",
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					if product, ok := models.FindProductById(params.Args["id"].(string)); ok {
+						return newGraphQLProduct(product), nil
+					}
+					return nil, nil
+				},
+			},
+			"merchant": {
+				Type: graphql.NewNonNull(merchantType),
+				Args: graphql.FieldConfigArgument{
+					"skuId": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					return graphqlMerchant{SkuId: params.Args["skuId"].(string)}, nil
+				},
+			},
+			"category": {
+				Type:        categoryType,
+				Description: "Null when there is no category with the id",
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+					if category, ok := models.FindCategoryById(params.Args["id"].(string)); ok {
+						return category, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	productCreateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ProductCreateInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":        {Type: graphql.NewNonNull(graphql.String)},
+			"description": {Type: graphql.NewNonNull(graphql.String)},
+			"price":       {Type: graphql.NewNonNull(graphql.Float)},
+			"taxClass":    {Type: graphqlTaxClassEnum, Description: "Defaults to standard"},
+		},
+	})
+
+	productUpdateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "ProductUpdateInput",
+		Description: "Fields left out are not changed",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":        {Type: graphql.String},
+			"description": {Type: graphql.String},
+			"price":       {Type: graphql.Float},
+			"taxClass":    {Type: graphqlTaxClassEnum},
+		},
+	})
+
+	// Mutations check the API key scope, role and ownership the matching REST requests check
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createProduct": {
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{
+					"input": {Type: graphql.NewNonNull(productCreateInput)},
+				},
+				Resolve: graphqlResolver(func(params graphql.ResolveParams) (interface{}, error) {
+					ctx := graphqlRequest(params)
+					skuId, err := graphqlAuthorize(ctx, models.PermissionProductsCreate)
+					if err != nil {
+						return nil, err
+					}
+
+					body := types.ProductCreatePayload{}
+					if err := graphqlInput(params, &body); err != nil {
+						return nil, err
+					}
+					return newGraphQLProduct(createProduct(requestOrigin(ctx), skuId, body)), nil
+				}),
+			},
+			"updateProduct": {
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.ID)},
+					"input": {Type: graphql.NewNonNull(productUpdateInput)},
+				},
+				Resolve: graphqlResolver(func(params graphql.ResolveParams) (interface{}, error) {
+					ctx := graphqlRequest(params)
+					skuId, err := graphqlAuthorize(ctx, models.PermissionProductsUpdate)
+					if err != nil {
+						return nil, err
+					}
+
+					body := types.ProductUpdatePayload{}
+					if err := graphqlInput(params, &body); err != nil {
+						return nil, err
+					}
+
+					product, err := updateProduct(requestOrigin(ctx), skuId, params.Args["id"].(string), body)
+					if err != nil {
+						return nil, err
+					}
+					return newGraphQLProduct(product), nil
+				}),
+			},
+			"deleteProduct": {
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Returns the id of the deleted product",
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: graphqlResolver(func(params graphql.ResolveParams) (interface{}, error) {
+					ctx := graphqlRequest(params)
+					skuId, err := graphqlAuthorize(ctx, models.PermissionProductsDelete)
+					if err != nil {
+						return nil, err
+					}
+
+					id := params.Args["id"].(string)
+					if err := deleteProduct(requestOrigin(ctx), skuId, id); err != nil {
+						return nil, err
+					}
+					return id, nil
+				}),
+			},
+		},
+	})
+
+	return graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	}
+}
+
+// graphqlAuthorize checks the write scope of API keys on top of authorize, the route only requires the read scope
+func graphqlAuthorize(ctx *fiber.Ctx, permission models.Permission) (string, error) {
+	if err := middleware.CheckScope(ctx, models.ScopeProductsWrite); err != nil {
+		return "", err
+	}
+	return authorize(ctx, permission)
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	salv1 "github.com/rnwonder/SAL/proto/sal/v1"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Metadata read from gRPC calls, the headers of the REST API in lower case and the skuId query parameter
+var (
+	grpcAPIKeyMetadata      = strings.ToLower(middleware.APIKeyHeader)
+	grpcMemberTokenMetadata = strings.ToLower(middleware.MemberTokenHeader)
+	grpcLanguageMetadata    = "accept-language"
+	grpcRequestIdMetadata   = "x-request-id"
+	grpcSkuIdMetadata       = "sku-id"
+)
+
+// WatchBuffer is how many product changes a Watch stream may fall behind before it is ended
+var WatchBuffer = 256
+
+// ProductServer serves the ProductService of proto/sal/v1 with the store and the rules of the REST routes
+type ProductServer struct {
+	salv1.UnimplementedProductServiceServer
+}
+
+func NewProductServer() *ProductServer {
+	return &ProductServer{}
+}
+
+// grpcCaller is who a call acts for, read from its metadata the way the middleware reads the headers of a request
+type grpcCaller struct {
+	skuId    string
+	role     models.Role
+	apiKey   *models.APIKey
+	language string
+	origin   auditOrigin
+}
+
+func newGRPCCaller(ctx context.Context) (grpcCaller, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	caller := grpcCaller{
+		language: get(grpcLanguageMetadata),
+		origin:   auditOrigin{requestId: cmp.Or(get(grpcRequestIdMetadata), uuid.NewString())},
+	}
+
+	if secret := get(grpcAPIKeyMetadata); secret != "" {
+		key, err := models.AuthenticateAPIKey(secret)
+		if err != nil {
+			return grpcCaller{}, problem.New(401, problem.CodeInvalidAPIKey, "Invalid or revoked API key")
+		}
+
+		caller.skuId, caller.role, caller.apiKey = key.SkuId, models.RoleOwner, &key
+		caller.origin.actor = models.AuditActor{Type: "apiKey", Id: key.Id}
+		return caller, nil
+	}
+
+	if token := get(grpcMemberTokenMetadata); token != "" {
+		member, err := models.AuthenticateMember(token)
+		if err != nil {
+			return grpcCaller{}, problem.New(401, problem.CodeInvalidMemberToken, "Invalid member token")
+		}
+
+		caller.skuId, caller.role = member.SkuId, member.Role
+		caller.origin.actor = models.AuditActor{Type: "member", Id: member.Id, Email: member.Email}
+		return caller, nil
+	}
+
+	caller.skuId = get(grpcSkuIdMetadata)
+	if caller.skuId != "" {
+		caller.role = models.SkuIdRole(caller.skuId)
+	}
+	caller.origin.actor = models.AuditActor{Type: "merchant", Id: caller.skuId}
+	return caller, nil
+}
+
+// checkScope checks the API key of the call holds scope, calls without an API key are not affected
+func (caller grpcCaller) checkScope(scope string) error {
+	if caller.apiKey != nil && !caller.apiKey.HasScope(scope) {
+		return problem.New(403, problem.CodeInsufficientScope, "API key is missing the "+scope+" scope")
+	}
+	return nil
+}
+
+// authorize checks the call may change products with permission and returns the merchant
+func (caller grpcCaller) authorize(permission models.Permission) (string, error) {
+	if err := caller.checkScope(models.ScopeProductsWrite); err != nil {
+		return "", err
+	}
+	if err := checkRole(caller.role, caller.skuId != "", permission); err != nil {
+		return "", err
+	}
+	return caller.skuId, nil
+}
+
+// grpcError turns the problem a REST request would get into a status. The problem code is the reason
+// of an ErrorInfo detail and field errors become a BadRequest detail.
+func grpcError(err error) error {
+	problem := problem.From(err)
+
+	if problem.Status >= http.StatusInternalServerError {
+		log.Error("gRPC: ", err)
+	}
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: string(problem.Code), Domain: "sal"}}
+	if len(problem.Errors) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, fieldError := range problem.Errors {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldError.Field,
+				Description: fieldError.Message,
+			})
+		}
+		details = append(details, badRequest)
+	}
+
+	withDetails, detailsErr := status.New(grpcCode(problem.Status), problem.Detail).WithDetails(details...)
+	if detailsErr != nil {
+		return status.Error(grpcCode(problem.Status), problem.Detail)
+	}
+	return withDetails.Err()
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	}
+	return codes.Internal
+}
+
+func grpcProduct(product models.Product) *salv1.Product {
+	view := productView(product)
+	return &salv1.Product{
+		Id:                product.Id,
+		SkuId:             product.SkuId,
+		Name:              product.Name,
+		Description:       product.Description,
+		Price:             product.Price,
+		TaxClass:          string(product.TaxClass),
+		CategoryIds:       product.CategoryIds,
+		Stock:             int32(product.Stock),
+		LowStockThreshold: int32(product.LowStockThreshold),
+		CreatedAt:         timestamppb.New(product.CreatedAt),
+		UpdatedAt:         timestamppb.New(product.UpdatedAt),
+		EffectivePrice:    view.EffectivePrice,
+		DiscountedPrice:   view.DiscountedPrice,
+		VariantCount:      int32(view.VariantCount),
+	}
+}
+
+var grpcEventTypes = map[models.ProductEventType]salv1.ProductEvent_Type{
+	models.ProductCreated: salv1.ProductEvent_TYPE_CREATED,
+	models.ProductUpdated: salv1.ProductEvent_TYPE_UPDATED,
+	models.ProductDeleted: salv1.ProductEvent_TYPE_DELETED,
+}
+
+func (server *ProductServer) List(ctx context.Context, req *salv1.ListProductsRequest) (*salv1.ListProductsResponse, error) {
+	caller, err := newGRPCCaller(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if err := caller.checkScope(models.ScopeProductsRead); err != nil {
+		return nil, grpcError(err)
+	}
+
+	arguments := productListArguments{
+		Page:      cmp.Or(int(req.Page), 1),
+		Limit:     cmp.Or(int(req.Limit), 10),
+		SortKey:   cmp.Or(req.SortKey, "createdAt"),
+		SortOrder: cmp.Or(req.SortOrder, "desc"),
+	}
+	if err := validators.Validator(&arguments, caller.language); err != nil {
+		return nil, grpcError(err)
+	}
+
+	filter := models.ProductFilter{
+		Search:    req.Search,
+		SortKey:   arguments.SortKey,
+		SortOrder: arguments.SortOrder,
+		InStock:   req.InStock,
+		SkuId:     req.SkuId,
+	}
+	if req.Category != "" {
+		filter.Categories = models.CategoryDescendantIds(req.Category)
+	}
+
+	products, meta := listProducts(filter, strconv.Itoa(arguments.Page), strconv.Itoa(arguments.Limit))
+
+	response := &salv1.ListProductsResponse{
+		Products: make([]*salv1.Product, 0, len(products)),
+		Meta: &salv1.PageMeta{
+			CurrentPage:   int32(meta.CurrentPage),
+			Limit:         int32(meta.Limit),
+			TotalPages:    int32(meta.TotalPages),
+			TotalProducts: int32(meta.TotalProducts),
+		},
+	}
+	for _, product := range products {
+		response.Products = append(response.Products, grpcProduct(product))
+	}
+	return response, nil
+}
+
+func (server *ProductServer) Get(ctx context.Context, req *salv1.GetProductRequest) (*salv1.Product, error) {
+	caller, err := newGRPCCaller(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if err := caller.checkScope(models.ScopeProductsRead); err != nil {
+		return nil, grpcError(err)
+	}
+
+	product, ok := models.FindProductById(req.Id)
+	if !ok {
+		return nil, grpcError(errProductNotFound)
+	}
+	return grpcProduct(product), nil
+}
+
+func (server *ProductServer) Create(ctx context.Context, req *salv1.CreateProductRequest) (*salv1.Product, error) {
+	caller, err := newGRPCCaller(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	skuId, err := caller.authorize(models.PermissionProductsCreate)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	body := types.ProductCreatePayload{
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       req.Price,
+		TaxClass:    models.TaxClass(req.TaxClass),
+	}
+	if err := validators.Validator(&body, caller.language); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return grpcProduct(createProduct(caller.origin, skuId, body)), nil
+}
+
+func (server *ProductServer) Update(ctx context.Context, req *salv1.UpdateProductRequest) (*salv1.Product, error) {
+	caller, err := newGRPCCaller(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	skuId, err := caller.authorize(models.PermissionProductsUpdate)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	body := types.ProductUpdatePayload{
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       req.Price,
+		TaxClass:    models.TaxClass(req.TaxClass),
+	}
+	if err := validators.Validator(&body, caller.language); err != nil {
+		return nil, grpcError(err)
+	}
+
+	product, err := updateProduct(caller.origin, skuId, req.Id, body)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return grpcProduct(product), nil
+}
+
+func (server *ProductServer) Delete(ctx context.Context, req *salv1.DeleteProductRequest) (*salv1.DeleteProductResponse, error) {
+	caller, err := newGRPCCaller(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	skuId, err := caller.authorize(models.PermissionProductsDelete)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	if err := deleteProduct(caller.origin, skuId, req.Id); err != nil {
+		return nil, grpcError(err)
+	}
+	return &salv1.DeleteProductResponse{Id: req.Id}, nil
+}
+
+func (server *ProductServer) Watch(req *salv1.WatchProductsRequest, stream salv1.ProductService_WatchServer) error {
+	caller, err := newGRPCCaller(stream.Context())
+	if err != nil {
+		return grpcError(err)
+	}
+	if err := caller.checkScope(models.ScopeProductsRead); err != nil {
+		return grpcError(err)
+	}
+
+	events, unsubscribe := models.SubscribeProductEvents(WatchBuffer)
+	defer unsubscribe()
+
+	// Lets the caller know the subscription is in place before any change is sent
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "Too many changes were missed, watch again and list the products to catch up")
+			}
+
+			if req.SkuId != "" && event.Product.SkuId != req.SkuId {
+				continue
+			}
+
+			err := stream.Send(&salv1.ProductEvent{
+				Type:    grpcEventTypes[event.Type],
+				Product: grpcProduct(event.Product),
+				At:      timestamppb.New(event.At),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/internals/storage"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/util"
+	"github.com/rnwonder/SAL/validators"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+const (
+	MaxImageSize = 5 * 1024 * 1024
+	// MaxImagePixels bounds the decoded size, a small compressed file can decode to gigabytes
+	MaxImagePixels = 40 * 1000 * 1000
+	ThumbnailSize  = 256
+)
+
+// ImageStore keeps the uploaded product images, it is configured when the server starts
+var ImageStore storage.BlobStore
+
+// Image types that can be uploaded mapped to the extension they are stored with
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// GetProductImagesEndpoint Get the images of a product
+func GetProductImagesEndpoint(ctx *fiber.Ctx) error {
+	product, ok := models.FindProductById(ctx.Params("id"))
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	return ctx.Status(200).JSON(types.GetImagesResponse{
+		Message: "Images fetched successfully",
+		Images:  models.GetProductImages(product.Id),
+	})
+}
+
+// UploadProductImagesEndpoint Upload images of a product
+func UploadProductImagesEndpoint(ctx *fiber.Ctx) error {
+	product, err := ownedProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ImageStore == nil {
+		return problem.New(500, problem.CodeInternal, "Image storage is not configured")
+	}
+
+	form, err := ctx.MultipartForm()
+
+	if err != nil {
+		return problem.New(400, problem.CodeBadRequest, "Invalid request please upload the images as multipart/form-data")
+	}
+
+	files := form.File["image"]
+
+	if len(files) == 0 {
+		return problem.New(400, problem.CodeBadRequest, "Invalid request please provide at least one image")
+	}
+
+	for _, file := range files {
+		if file.Size > MaxImageSize {
+			return problem.New(413, problem.CodePayloadTooLarge, fmt.Sprintf("Image %s is larger than %d bytes", file.Filename, MaxImageSize))
+		}
+	}
+
+	images := make([]models.Image, 0, len(files))
+	for _, file := range files {
+		uploaded, err := storeProductImage(product.Id, file)
+
+		// The upload is all or nothing, the images stored before this file are removed again
+		if err != nil {
+			for _, image := range images {
+				models.DeleteImage(image.Id)
+			}
+			deleteImageBlobs(images)
+		}
+
+		// Files the client got wrong are reported as they are, anything else is a storage failure
+		var uploadProblem *problem.Problem
+		if errors.As(err, &uploadProblem) {
+			return uploadProblem
+		}
+
+		if err != nil {
+			log.Error("Error storing image: ", err)
+			return problem.New(500, problem.CodeInternal, "Image could not be stored")
+		}
+
+		images = append(images, uploaded)
+	}
+
+	return ctx.Status(201).JSON(types.GetImagesResponse{
+		Message: "Images uploaded successfully",
+		Images:  images,
+	})
+}
+
+// ReorderProductImagesEndpoint Reorder the images of a product
+func ReorderProductImagesEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.ImageOrderPayload)
+
+	product, err := ownedProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	images, err := models.ReorderImages(product.Id, body.ImageIds)
+
+	if err != nil {
+		return problem.New(400, problem.CodeBadRequest, "Invalid request the image order must list every image of the product once")
+	}
+
+	return ctx.Status(200).JSON(types.GetImagesResponse{
+		Message: "Images reordered successfully",
+		Images:  images,
+	})
+}
+
+// DeleteProductImageEndpoint Delete an image of a product
+func DeleteProductImageEndpoint(ctx *fiber.Ctx) error {
+	product, err := ownedProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	image, ok := models.FindImageById(product.Id, ctx.Params("imageId"))
+
+	if !ok {
+		return problem.New(404, problem.CodeImageNotFound, "Image not found")
+	}
+
+	models.DeleteImage(image.Id)
+	deleteImageBlobs([]models.Image{image})
+
+	return ctx.Status(200).JSON(types.MessageResponse{
+		Message: "Image deleted successfully",
+	})
+}
+
+func storeProductImage(productId string, file *multipart.FileHeader) (models.Image, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return models.Image{}, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, MaxImageSize+1))
+	if err != nil {
+		return models.Image{}, err
+	}
+
+	if len(data) > MaxImageSize {
+		return models.Image{}, problem.New(413, problem.CodePayloadTooLarge, fmt.Sprintf("Image %s is larger than %d bytes", file.Filename, MaxImageSize))
+	}
+
+	// Trust the bytes rather than the Content-Type sent by the client
+	contentType := http.DetectContentType(data)
+	extension, ok := imageExtensions[contentType]
+
+	if !ok {
+		return models.Image{}, problem.New(415, problem.CodeUnsupportedMediaType, fmt.Sprintf("Image %s is not a jpeg, png or gif", file.Filename))
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+
+	if err != nil {
+		return models.Image{}, problem.New(415, problem.CodeUnsupportedMediaType, fmt.Sprintf("Image %s could not be decoded", file.Filename))
+	}
+
+	if config.Width*config.Height > MaxImagePixels {
+		return models.Image{}, problem.New(413, problem.CodePayloadTooLarge, fmt.Sprintf("Image %s is larger than %d pixels", file.Filename, MaxImagePixels))
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+
+	if err != nil {
+		return models.Image{}, problem.New(415, problem.CodeUnsupportedMediaType, fmt.Sprintf("Image %s could not be decoded", file.Filename))
+	}
+
+	thumbnail, thumbnailType, err := encodeThumbnail(decoded, contentType)
+	if err != nil {
+		return models.Image{}, err
+	}
+
+	id := uuid.Must(uuid.NewRandom()).String()
+	key := fmt.Sprintf("products/%s/%s.%s", productId, id, extension)
+	thumbnailKey := fmt.Sprintf("products/%s/%s_thumb.%s", productId, id, imageExtensions[thumbnailType])
+
+	if err := ImageStore.Put(key, bytes.NewReader(data), contentType); err != nil {
+		return models.Image{}, err
+	}
+
+	if err := ImageStore.Put(thumbnailKey, bytes.NewReader(thumbnail), thumbnailType); err != nil {
+		_ = ImageStore.Delete(key)
+		return models.Image{}, err
+	}
+
+	bounds := decoded.Bounds()
+	return models.AddImage(models.Image{
+		Id:           id,
+		ProductId:    productId,
+		Key:          key,
+		ThumbnailKey: thumbnailKey,
+		Url:          ImageStore.URL(key),
+		ThumbnailUrl: ImageStore.URL(thumbnailKey),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        bounds.Dx(),
+		Height:       bounds.Dy(),
+		CreatedAt:    time.Now(),
+	}), nil
+}
+
+// encodeThumbnail keeps jpeg thumbnails as jpeg and turns everything else into png to keep transparency
+func encodeThumbnail(img image.Image, contentType string) ([]byte, string, error) {
+	var buffer bytes.Buffer
+	thumbnail := util.Thumbnail(img, ThumbnailSize)
+
+	if contentType == "image/jpeg" {
+		err := jpeg.Encode(&buffer, thumbnail, &jpeg.Options{Quality: 85})
+		return buffer.Bytes(), "image/jpeg", err
+	}
+
+	err := png.Encode(&buffer, thumbnail)
+	return buffer.Bytes(), "image/png", err
+}
+
+func deleteImageBlobs(images []models.Image) {
+	if ImageStore == nil {
+		return
+	}
+
+	for _, image := range images {
+		for _, key := range []string{image.Key, image.ThumbnailKey} {
+			if err := ImageStore.Delete(key); err != nil && !errors.Is(err, storage.ErrBlobNotFound) {
+				log.Error("Error deleting image ", key, ": ", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+	"time"
+)
+
+const defaultReservationTtl = 15 * time.Minute
+
+// GetProductStockEndpoint Get the stock of a product
+func GetProductStockEndpoint(ctx *fiber.Ctx) error {
+	level, variantLevels, err := models.ProductStockLevels(ctx.Params("id"))
+
+	if err != nil {
+		return stockError(err)
+	}
+
+	return ctx.Status(200).JSON(types.StockResponse{
+		Message:  "Stock fetched successfully",
+		Stock:    level,
+		Variants: variantLevels,
+	})
+}
+
+// GetLowStockEndpoint Get low stock products
+func GetLowStockEndpoint(ctx *fiber.Ctx) error {
+	skuId := middleware.MerchantId(ctx)
+
+	if skuId == "" {
+		return errMerchantRequired
+	}
+
+	return ctx.Status(200).JSON(types.StockLevelsResponse{
+		Message: "Low stock fetched successfully",
+		Levels:  models.LowStockLevels(skuId),
+	})
+}
+
+// IncrementStockEndpoint Increment the stock of a product
+func IncrementStockEndpoint(ctx *fiber.Ctx) error {
+	return adjustStock(ctx, 1)
+}
+
+// DecrementStockEndpoint Decrement the stock of a product
+func DecrementStockEndpoint(ctx *fiber.Ctx) error {
+	return adjustStock(ctx, -1)
+}
+
+func adjustStock(ctx *fiber.Ctx, sign int) error {
+	body := new(types.StockAdjustPayload)
+
+	product, err := ownedProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	delta := sign * body.Quantity
+	level, err := models.AdjustStock(product.Id, body.VariantId, delta)
+
+	if err != nil {
+		return stockError(err)
+	}
+
+	entry := productAuditEntry(AuditStockAdjust, product)
+	if body.VariantId != "" {
+		entry = variantAuditEntry(AuditStockAdjust, product, models.Variant{Id: body.VariantId})
+	}
+	recordAudit(ctx, entry, fiber.Map{"stock": level.Stock - delta}, fiber.Map{"stock": level.Stock})
+
+	return ctx.Status(200).JSON(types.StockResponse{
+		Message:  "Stock updated successfully",
+		Stock:    level,
+		Variants: []models.StockLevel{},
+	})
+}
+
+// UpdateStockSettingsEndpoint Update the stock settings of a product
+func UpdateStockSettingsEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.StockSettingsPayload)
+
+	product, err := ownedProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	var before models.Product
+	updated, ok := models.UpdateProduct(product.Id, func(product *models.Product) {
+		before = *product
+		product.LowStockThreshold = *body.LowStockThreshold
+	})
+
+	if !ok {
+		return stockError(models.ErrProductNotFound)
+	}
+
+	recordAudit(ctx, productAuditEntry(AuditProductUpdate, updated), before, updated)
+
+	level, variantLevels, err := models.ProductStockLevels(product.Id)
+
+	if err != nil {
+		return stockError(err)
+	}
+
+	return ctx.Status(200).JSON(types.StockResponse{
+		Message:  "Stock settings updated successfully",
+		Stock:    level,
+		Variants: variantLevels,
+	})
+}
+
+// CreateReservationEndpoint Reserve stock
+func CreateReservationEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.ReservationCreatePayload)
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	ttl := defaultReservationTtl
+	if body.TtlSeconds > 0 {
+		ttl = time.Duration(body.TtlSeconds) * time.Second
+	}
+
+	// Params are only valid during the request, the reservation outlives it
+	reservation, err := models.ReserveStock(utils.CopyString(ctx.Params("id")), body.VariantId, body.Quantity, ttl)
+
+	if err != nil {
+		return stockError(err)
+	}
+
+	return ctx.Status(201).JSON(types.ReservationResponse{
+		Message:     "Stock reserved successfully",
+		Reservation: reservation,
+	})
+}
+
+// ReleaseReservationEndpoint Release a reservation
+func ReleaseReservationEndpoint(ctx *fiber.Ctx) error {
+	reservation, ok := models.FindReservationById(ctx.Params("reservationId"))
+
+	if !ok || reservation.ProductId != ctx.Params("id") {
+		return stockError(models.ErrReservationNotFound)
+	}
+
+	reservation, err := models.ReleaseReservation(reservation.Id)
+
+	if err != nil {
+		return stockError(err)
+	}
+
+	return ctx.Status(200).JSON(types.ReservationResponse{
+		Message:     "Reservation released successfully",
+		Reservation: reservation,
+	})
+}
+
+// CommitReservationEndpoint Commit a reservation
+func CommitReservationEndpoint(ctx *fiber.Ctx) error {
+	product, err := ownedProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	reservation, ok := models.FindReservationById(ctx.Params("reservationId"))
+
+	if !ok || reservation.ProductId != product.Id {
+		return stockError(models.ErrReservationNotFound)
+	}
+
+	reservation, err = models.CommitReservation(reservation.Id)
+
+	if err != nil {
+		return stockError(err)
+	}
+
+	return ctx.Status(200).JSON(types.ReservationResponse{
+		Message:     "Reservation committed successfully",
+		Reservation: reservation,
+	})
+}
+
+// ownedProduct loads the product in the id param and checks the request may update it for the merchant
+func ownedProduct(ctx *fiber.Ctx) (models.Product, error) {
+	skuId, err := authorize(ctx, models.PermissionProductsUpdate)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	product, ok := models.FindProductById(ctx.Params("id"))
+
+	if !ok {
+		return models.Product{}, errProductNotFound
+	}
+
+	if product.SkuId != skuId {
+		return models.Product{}, errProductForbidden
+	}
+
+	return product, nil
+}
+
+func stockError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrProductNotFound):
+		return errProductNotFound
+	case errors.Is(err, models.ErrVariantNotFound):
+		return errVariantNotFound
+	case errors.Is(err, models.ErrReservationNotFound):
+		return problem.New(404, problem.CodeReservationNotFound, "Reservation not found or expired")
+	case errors.Is(err, models.ErrInsufficientStock):
+		return problem.New(409, problem.CodeInsufficientStock, "Not enough stock available")
+	}
+	return errInternal
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+	"slices"
+	"time"
+)
+
+// PlaceOrderEndpoint Place an order
+func PlaceOrderEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.OrderCreatePayload)
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	cart, ok := models.FindCartById(body.CartId)
+
+	if !ok {
+		return cartError(models.ErrCartNotFound)
+	}
+
+	pricedCart := priceCart(cart)
+
+	if len(pricedCart.Items) == 0 {
+		return problem.New(400, problem.CodeCartEmpty, "Cart is empty")
+	}
+
+	items := make([]models.OrderItem, 0, len(pricedCart.Items))
+	var coupons []string
+	for _, line := range pricedCart.Items {
+		item := models.OrderItem{
+			Id:        uuid.Must(uuid.NewRandom()).String(),
+			ProductId: line.ProductId,
+			VariantId: line.VariantId,
+			SkuId:     line.SkuId,
+			Name:      line.Name,
+			UnitPrice: line.UnitPrice,
+			Quantity:  line.Quantity,
+			Discount:  line.Discount,
+			LineTotal: line.LineTotal,
+			Tax:       line.Tax,
+		}
+
+		if line.Promotion != nil {
+			item.PromotionId = line.Promotion.PromotionId
+			if line.Promotion.Code != "" && !slices.Contains(coupons, line.Promotion.PromotionId) {
+				coupons = append(coupons, line.Promotion.PromotionId)
+			}
+		}
+
+		if product, ok := models.FindProductById(line.ProductId); ok {
+			item.Description = product.Description
+		}
+
+		if variant, ok := models.FindVariantById(line.ProductId, line.VariantId); ok {
+			item.Options = variant.Options
+		}
+
+		items = append(items, item)
+	}
+
+	// The stock is taken when the order is placed, cancelling the items of a merchant puts it back
+	stock := orderStock(items)
+	if err := models.TakeStock(stock); err != nil {
+		return stockError(err)
+	}
+
+	if err := models.RedeemCoupons(coupons, time.Now()); err != nil {
+		models.ReturnStock(stock)
+		return cartError(err)
+	}
+
+	order := models.CreateOrder(cart.Id, items)
+	models.DeleteCart(cart.Id)
+
+	return ctx.Status(201).JSON(types.OrderResponse{
+		Message: "Order placed successfully",
+		Order:   order,
+	})
+}
+
+// GetOrderEndpoint Get an order
+func GetOrderEndpoint(ctx *fiber.Ctx) error {
+	order, ok := models.FindOrderById(ctx.Params("id"))
+
+	if !ok {
+		return orderError(models.ErrOrderNotFound)
+	}
+
+	return ctx.Status(200).JSON(types.OrderResponse{
+		Message: "Order fetched successfully",
+		Order:   order,
+	})
+}
+
+// GetMerchantOrdersEndpoint Get the orders of a merchant
+func GetMerchantOrdersEndpoint(ctx *fiber.Ctx) error {
+	skuId := middleware.MerchantId(ctx)
+	status := models.OrderStatus(ctx.Query("status"))
+
+	if skuId == "" {
+		return errMerchantRequired
+	}
+
+	if status != "" && !models.IsOrderStatus(status) {
+		return problem.New(400, problem.CodeInvalidParameter, "Invalid order status "+string(status))
+	}
+
+	return ctx.Status(200).JSON(types.GetOrdersResponse{
+		Message: "Orders fetched successfully",
+		Orders:  models.MerchantOrders(skuId, status),
+	})
+}
+
+// TransitionOrderEndpoint Change the status of an order
+func TransitionOrderEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.OrderTransitionPayload)
+	skuId, err := authorize(ctx, models.PermissionOrdersManage)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	order, ok := models.FindOrderById(ctx.Params("id"))
+
+	if !ok {
+		return orderError(models.ErrOrderNotFound)
+	}
+
+	if !order.HasMerchant(skuId) {
+		return problem.New(403, problem.CodeForbidden, "You do not have permission to update this order")
+	}
+
+	// The items change status first, a second refund of the same items then fails the transition instead of
+	// refunding the payment twice
+	order, from, err := models.TransitionMerchantItems(order.Id, skuId, body.Status, utils.CopyString(body.Note))
+
+	if err != nil {
+		return orderError(err)
+	}
+
+	if body.Status == models.OrderRefunded {
+		if err := refundOrderPayment(order, skuId); err != nil {
+			log.Error("Error refunding order ", order.Id, ": ", err)
+			if _, err := models.RevertMerchantItems(order.Id, skuId, from, body.Status); err != nil {
+				log.Error("Error reverting refund of order ", order.Id, ": ", err)
+			}
+			return problem.New(502, problem.CodePaymentFailed, "Refund could not be processed")
+		}
+	}
+
+	if body.Status == models.OrderCancelled {
+		models.ReturnStock(orderStock(order.MerchantItems(skuId)))
+	}
+
+	return ctx.Status(200).JSON(types.OrderResponse{
+		Message: "Order status updated successfully",
+		Order:   order,
+	})
+}
+
+// orderStock is the stock the items of an order take
+func orderStock(items []models.OrderItem) []models.StockLine {
+	lines := make([]models.StockLine, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, models.StockLine{ProductId: item.ProductId, VariantId: item.VariantId, Quantity: item.Quantity})
+	}
+	return lines
+}
+
+func orderError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrOrderNotFound):
+		return problem.New(404, problem.CodeOrderNotFound, "Order not found")
+	case errors.Is(err, models.ErrInvalidTransition):
+		return problem.New(409, problem.CodeInvalidTransition, "This status change is not allowed for the order")
+	}
+	return errInternal
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/payment"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+)
+
+// PaymentSignatureHeader carries the signature of the webhook payload
+const PaymentSignatureHeader = "X-Payment-Signature"
+
+// PaymentProvider takes the payments of orders, it is configured when the server starts
+var PaymentProvider payment.PaymentProvider
+
+var PaymentCurrency = "NGN"
+
+// CheckoutOrderEndpoint Start paying for an order
+func CheckoutOrderEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.OrderPaymentPayload)
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	if PaymentProvider == nil {
+		return errPaymentsDisabled
+	}
+
+	order, ok := models.FindOrderById(ctx.Params("id"))
+
+	if !ok {
+		return orderError(models.ErrOrderNotFound)
+	}
+
+	if order.Status != models.OrderPending {
+		return orderError(models.ErrInvalidTransition)
+	}
+
+	transaction, err := PaymentProvider.Initialize(payment.InitializeRequest{
+		Reference:   uuid.Must(uuid.NewRandom()).String(),
+		Amount:      order.Total,
+		Currency:    PaymentCurrency,
+		Email:       body.Email,
+		CallbackUrl: body.CallbackUrl,
+	})
+
+	if err != nil {
+		log.Error("Error initializing payment: ", err)
+		return problem.New(502, problem.CodePaymentFailed, "Payment could not be started")
+	}
+
+	if _, err := models.SetOrderPayment(order.Id, PaymentProvider.Name(), transaction.Reference); err != nil {
+		return orderError(err)
+	}
+
+	return ctx.Status(201).JSON(types.PaymentResponse{
+		Message: "Payment started successfully",
+		Payment: transaction,
+	})
+}
+
+// PaymentWebhookEndpoint Receive payment notifications
+func PaymentWebhookEndpoint(ctx *fiber.Ctx) error {
+	if PaymentProvider == nil {
+		return errPaymentsDisabled
+	}
+
+	event, err := PaymentProvider.ParseWebhook(ctx.Body(), ctx.Get(PaymentSignatureHeader))
+
+	if errors.Is(err, payment.ErrInvalidSignature) {
+		return problem.New(401, problem.CodeInvalidSignature, "Invalid webhook signature")
+	}
+
+	if err != nil {
+		return problem.New(400, problem.CodeInvalidPayload, "Invalid webhook payload")
+	}
+
+	return applyPaymentEvent(ctx, event)
+}
+
+// FakePaymentEndpoint Complete a fake payment
+func FakePaymentEndpoint(ctx *fiber.Ctx) error {
+	provider, ok := PaymentProvider.(*payment.FakeProvider)
+
+	if !ok {
+		return problem.New(404, problem.CodeNotFound, "The fake payment provider is not enabled")
+	}
+
+	payload, signature, err := provider.Pay(ctx.Params("reference"))
+
+	if err != nil {
+		return problem.New(404, problem.CodeTransactionNotFound, "Transaction not found")
+	}
+
+	event, err := provider.ParseWebhook(payload, signature)
+
+	if err != nil {
+		return errInternal
+	}
+
+	return applyPaymentEvent(ctx, event)
+}
+
+// applyPaymentEvent marks the order as paid once the provider confirms the full amount was paid.
+// Providers retry webhooks so events for orders that already moved on are acknowledged.
+func applyPaymentEvent(ctx *fiber.Ctx, event payment.Event) error {
+	if event.Type != payment.EventChargeSuccess {
+		return ctx.Status(200).JSON(types.MessageResponse{
+			Message: "Event ignored",
+		})
+	}
+
+	order, ok := models.FindOrderByPaymentReference(event.Reference)
+
+	if !ok {
+		return orderError(models.ErrOrderNotFound)
+	}
+
+	// Never trust the webhook alone, ask the provider for the transaction
+	transaction, err := PaymentProvider.Verify(event.Reference)
+
+	if err != nil {
+		log.Error("Error verifying payment: ", err)
+		return problem.New(502, problem.CodePaymentFailed, "Payment could not be verified")
+	}
+
+	if transaction.Status != payment.TransactionSuccess || transaction.Amount < order.Total {
+		return problem.New(400, problem.CodePaymentIncomplete, "Payment is not complete")
+	}
+
+	note := fmt.Sprintf("Paid with %s reference %s", order.PaymentProvider, transaction.Reference)
+	_, err = models.PayOrder(order.Id, transaction.Reference, note)
+
+	// A payment for an order that was already paid with another transaction or cancelled has to be refunded by hand
+	if errors.Is(err, models.ErrInvalidTransition) && order.PaymentReference != transaction.Reference {
+		log.Warn("Payment ", transaction.Reference, " arrived for order ", order.Id, " which is no longer pending")
+	} else if err != nil && !errors.Is(err, models.ErrInvalidTransition) {
+		return orderError(err)
+	}
+
+	return ctx.Status(200).JSON(types.MessageResponse{
+		Message: "Payment processed successfully",
+	})
+}
+
+// refundOrderPayment gives the customer their money back for the items of the merchant when the order was paid
+// through a provider
+func refundOrderPayment(order models.Order, skuId string) error {
+	if order.PaymentReference == "" {
+		return nil
+	}
+
+	if PaymentProvider == nil || PaymentProvider.Name() != order.PaymentProvider {
+		return fmt.Errorf("payment provider %s is not configured", order.PaymentProvider)
+	}
+
+	_, err := PaymentProvider.Refund(order.PaymentReference, order.MerchantTotal(skuId))
+	return err
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+	"time"
+)
+
+// GetPriceHistoryEndpoint Get the price history of a product
+func GetPriceHistoryEndpoint(ctx *fiber.Ctx) error {
+	product, ok := models.FindProductById(ctx.Params("id"))
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	return ctx.Status(200).JSON(types.PriceHistoryResponse{
+		Message: "Price history fetched successfully",
+		History: models.GetPriceHistory(product.Id),
+	})
+}
+
+// GetPriceSchedulesEndpoint Get the price schedules of a product
+func GetPriceSchedulesEndpoint(ctx *fiber.Ctx) error {
+	product, err := ownedProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(types.PriceSchedulesResponse{
+		Message:   "Price schedules fetched successfully",
+		Schedules: models.GetPriceSchedules(product.Id),
+	})
+}
+
+// CreatePriceScheduleEndpoint Schedule a price change
+func CreatePriceScheduleEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.PriceSchedulePayload)
+
+	product, err := ownedProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	if body.EndsAt != nil && !body.EndsAt.After(body.StartsAt) {
+		return problem.New(400, problem.CodeBadRequest, "Invalid request endsAt must be after startsAt")
+	}
+
+	if body.EndsAt != nil && !body.EndsAt.After(time.Now()) {
+		return problem.New(400, problem.CodeBadRequest, "Invalid request endsAt must be in the future")
+	}
+
+	if body.VariantId != "" {
+		if _, ok := models.FindVariantById(product.Id, body.VariantId); !ok {
+			return errVariantNotFound
+		}
+	}
+
+	schedule, err := models.SchedulePrice(models.PriceSchedule{
+		ProductId: product.Id,
+		VariantId: utils.CopyString(body.VariantId),
+		Price:     body.Price,
+		StartsAt:  body.StartsAt,
+		EndsAt:    body.EndsAt,
+	})
+
+	if err != nil {
+		return priceScheduleError(err)
+	}
+
+	// Schedules starting now show up in the history straight away
+	models.ApplyPriceSchedules(time.Now())
+	schedule, _ = models.FindPriceScheduleById(product.Id, schedule.Id)
+
+	return ctx.Status(201).JSON(types.PriceScheduleResponse{
+		Message:  "Price change scheduled successfully",
+		Schedule: schedule,
+	})
+}
+
+// CancelPriceScheduleEndpoint Cancel a price schedule
+func CancelPriceScheduleEndpoint(ctx *fiber.Ctx) error {
+	product, err := ownedProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	schedule, ok := models.FindPriceScheduleById(product.Id, ctx.Params("scheduleId"))
+
+	if !ok {
+		return priceScheduleError(models.ErrScheduleNotFound)
+	}
+
+	basePrice := product.Price
+	if schedule.VariantId != "" {
+		variant, _ := models.FindVariantById(product.Id, schedule.VariantId)
+		basePrice = variant.Price
+	}
+
+	schedule, err = models.CancelPriceSchedule(schedule.Id, basePrice)
+
+	if err != nil {
+		return priceScheduleError(err)
+	}
+
+	return ctx.Status(200).JSON(types.PriceScheduleResponse{
+		Message:  "Price schedule cancelled successfully",
+		Schedule: schedule,
+	})
+}
+
+func priceScheduleError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrScheduleNotFound):
+		return problem.New(404, problem.CodePriceScheduleNotFound, "Price schedule not found")
+	case errors.Is(err, models.ErrScheduleOverlap):
+		return problem.New(409, problem.CodeScheduleOverlap, "Another price schedule is set for this time")
+	case errors.Is(err, models.ErrScheduleFinished):
+		return problem.New(409, problem.CodeScheduleFinished, "Price schedule has already finished")
+	}
+	return errInternal
+}
@@ -3,32 +3,32 @@ package handlers
 import (
 	"cmp"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/google/uuid"
 	"github.com/rnwonder/SAL/internals/models"
 	"github.com/rnwonder/SAL/types"
 	"github.com/rnwonder/SAL/util"
 	"github.com/rnwonder/SAL/validators"
+	"strconv"
 	"sync"
 	"time"
 )
 
 // GetAllProductsEndpoint Get all products
-// @Summary Get all products
-// @Description Get all products in the store
-// @Tags Product
-// @Success 200 {object} GetProductResponse
-// @Router /product [get]
-
 func GetAllProductsEndpoint(ctx *fiber.Ctx) error {
-	key := cmp.Or(ctx.Query("sortKey"), "createdAt")
-	order := cmp.Or(ctx.Query("sortOrder"), "desc")
-	searchQuery := ctx.Query("search")
+	products, meta := listProducts(productFilterFromQuery(ctx), ctx.Query("page"), ctx.Query("limit"))
 
-	products := models.GetAllProducts()
+	return ctx.Status(200).JSON(types.GetProductResponse{
+		Message:  "Products fetched successfully",
+		Products: productViews(products),
+		Meta:     meta,
+	})
+}
 
-	if searchQuery != "" {
-		products = models.FilterProductsByName(products, searchQuery)
-	}
+// listProducts filters, sorts and pages the products, page and limit are read like the query parameters of GET /product
+func listProducts(filter models.ProductFilter, page string, limit string) ([]models.Product, types.Meta) {
+	products := models.GetAllProducts()
+	products = models.FilterProducts(products, filter)
 
 	resultCh := make(chan models.Product)
 
@@ -48,192 +48,249 @@ func GetAllProductsEndpoint(ctx *fiber.Ctx) error {
 		resultProducts = append(resultProducts, product)
 	}
 
-	if key != "" {
-		models.SortProducts(resultProducts, key, order)
+	if filter.SortKey != "" {
+		models.SortProducts(resultProducts, filter.SortKey, filter.SortOrder)
 	}
 
-	startIndex, endIndex, totalPages, limit, page := util.CalculatePageInfo(ctx.Query("page"), ctx.Query("limit"), len(resultProducts))
+	startIndex, endIndex, totalPages, limitInt, pageInt := util.CalculatePageInfo(page, limit, len(resultProducts))
 
-	resultProducts = resultProducts[startIndex:endIndex]
+	return resultProducts[startIndex:endIndex], types.Meta{
+		CurrentPage:   pageInt,
+		Limit:         limitInt,
+		TotalPages:    totalPages,
+		NextPage:      "/products?page=" + util.NextPage(pageInt, totalPages),
+		PrevPage:      "/products?page=" + util.PrevPage(pageInt),
+		TotalProducts: len(products),
+	}
+}
 
-	return ctx.Status(200).JSON(types.GetProductResponse{
-		Message:  "Products fetched successfully",
-		Products: resultProducts,
-		Meta: types.Meta{
-			CurrentPage:   page,
-			Limit:         limit,
-			TotalPages:    totalPages,
-			NextPage:      "/products?page=" + util.NextPage(page, totalPages),
-			PrevPage:      "/products?page=" + util.PrevPage(page),
-			TotalProducts: len(products),
-		},
-	})
+// productListArguments are the paging and sorting arguments of the GraphQL and gRPC product listings,
+// they follow the rules of the query parameters of GET /product
+type productListArguments struct {
+	Page      int    `json:"page" validate:"min=1"`
+	Limit     int    `json:"limit" validate:"min=1"`
+	SortKey   string `json:"sortKey" validate:"oneof=name price createdAt"`
+	SortOrder string `json:"sortOrder" validate:"oneof=asc desc"`
 }
 
-// FindAProductEndpoint Get a product
-// @Summary Get a product
-// @Description Get a product in the store
-// @Tags Product
-// @Success 200 {object} OneProductResponse
-// @Router /product/:id [get]
+func productFilterFromQuery(ctx *fiber.Ctx) models.ProductFilter {
+	filter := models.ProductFilter{
+		Search:    ctx.Query("search"),
+		SortKey:   cmp.Or(ctx.Query("sortKey"), "createdAt"),
+		SortOrder: cmp.Or(ctx.Query("sortOrder"), "desc"),
+	}
+
+	if category := ctx.Query("category"); category != "" {
+		filter.Categories = models.CategoryDescendantIds(category)
+	}
 
+	if inStock, err := strconv.ParseBool(ctx.Query("inStock")); err == nil {
+		filter.InStock = &inStock
+	}
+	return filter
+}
+
+func productView(product models.Product) types.ProductView {
+	now := time.Now()
+	view := types.ProductView{
+		Product:        product,
+		EffectivePrice: models.EffectivePrice(product.Id, "", product.Price, now),
+		PriceRange:     models.ProductPriceRange(product),
+		VariantCount:   len(models.GetProductVariants(product.Id)),
+		Images:         models.GetProductImages(product.Id),
+	}
+
+	view.DiscountedPrice = view.EffectivePrice
+	if promotion, ok := models.BestPromotion(product, view.EffectivePrice, nil, now); ok {
+		view.DiscountedPrice = view.EffectivePrice - promotion.Discount
+		view.Promotion = &promotion
+	}
+	view.Tax = models.ProductTax(product, view.DiscountedPrice)
+	return view
+}
+
+func productViews(products []models.Product) []types.ProductView {
+	if products == nil {
+		return nil
+	}
+
+	views := make([]types.ProductView, 0, len(products))
+	for _, product := range products {
+		views = append(views, productView(product))
+	}
+	return views
+}
+
+// FindAProductEndpoint Get a product
 func FindAProductEndpoint(ctx *fiber.Ctx) error {
 	id := ctx.Params("id")
 	product, ok := models.FindProductById(id)
 
 	if !ok {
-		return ctx.Status(404).JSON(fiber.Map{
-			"message": "Product not found",
-		})
+		return errProductNotFound
 	}
 
 	return ctx.Status(200).JSON(types.OneProductResponse{
 		Message: "Product fetched successfully",
-		Product: product,
+		Product: productView(product),
 	})
 }
 
 // CreateProductEndpoint Create a product
-// @Summary Create a product
-// @Description Create a product in the store
-// @Tags Product
-// @Success 200 {object} OneProductResponse
-// @Router /product [post]
-
 func CreateProductEndpoint(ctx *fiber.Ctx) error {
 	body := new(types.ProductCreatePayload)
-	skuId := ctx.Query("skuId")
 
-	if skuId == "" {
-		return ctx.Status(401).JSON(fiber.Map{
-			"message": "Invalid request please provide skuId query parameter",
-		})
+	skuId, err := authorize(ctx, models.PermissionProductsCreate)
+	if err != nil {
+		return err
 	}
 
 	if err := ctx.BodyParser(body); err != nil {
-		return ctx.Status(400).JSON(fiber.Map{
-			"message": "Invalid request payload",
-		})
+		return errInvalidPayload
 	}
 
-	if err := validators.Validator(body); err != nil {
-		return ctx.Status(400).JSON(err)
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
 	}
 
+	return ctx.Status(201).JSON(types.OneProductResponse{
+		Message: "Product created successfully",
+		Product: productView(createProduct(requestOrigin(ctx), skuId, *body)),
+	})
+}
+
+// createProduct saves a product of the merchant skuId from a validated payload
+func createProduct(origin auditOrigin, skuId string, body types.ProductCreatePayload) models.Product {
 	newProduct := models.Product{
 		Name:        body.Name,
 		Description: body.Description,
 		Price:       body.Price,
-		SkuId:       skuId,
+		TaxClass:    cmp.Or(body.TaxClass, models.TaxStandard),
+		SkuId:       utils.CopyString(skuId),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 		Id:          uuid.Must(uuid.NewRandom()).String(),
 	}
 
-	models.ProductData[newProduct.Id] = newProduct
-
-	return ctx.Status(201).JSON(types.OneProductResponse{
-		Message: "Product created successfully",
-		Product: newProduct,
+	models.SaveProduct(newProduct)
+	origin.record(productAuditEntry(AuditProductCreate, newProduct), nil, newProduct)
+	models.RecordPriceChange(models.PriceChange{
+		ProductId: newProduct.Id,
+		Price:     newProduct.Price,
+		Source:    models.PriceSourceManual,
 	})
+	return newProduct
 }
 
 // UpdateProductEndpoint Update a product
-// @Summary Update a product
-// @Description Update a product in the store
-// @Tags Product
-// @Success 200 {object} OneProductResponse
-// @Router /product/:id [put]
-
 func UpdateProductEndpoint(ctx *fiber.Ctx) error {
 	id := ctx.Params("id")
 	body := new(types.ProductUpdatePayload)
-	skuId := ctx.Query("skuId")
 
-	if skuId == "" {
-		return ctx.Status(401).JSON(fiber.Map{
-			"message": "Invalid request please provide skuId query parameter",
-		})
+	skuId, err := authorize(ctx, models.PermissionProductsUpdate)
+	if err != nil {
+		return err
 	}
 
 	if err := ctx.BodyParser(body); err != nil {
-		return ctx.Status(400).JSON(fiber.Map{
-			"message": "Invalid request payload",
-		})
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
 	}
 
-	if err := validators.Validator(body); err != nil {
-		return ctx.Status(400).JSON(err)
+	product, err := updateProduct(requestOrigin(ctx), skuId, id, *body)
+	if err != nil {
+		return err
 	}
 
+	return ctx.Status(200).JSON(types.OneProductResponse{
+		Message: "Product updated successfully",
+		Product: productView(product),
+	})
+}
+
+// updateProduct changes the fields of a validated payload that are not empty on a product of the merchant skuId
+func updateProduct(origin auditOrigin, skuId string, id string, body types.ProductUpdatePayload) (models.Product, error) {
 	product, ok := models.FindProductById(id)
 
 	if !ok {
-		return ctx.Status(404).JSON(fiber.Map{
-			"message": "Product not found",
-		})
+		return models.Product{}, errProductNotFound
 	}
 
 	if product.SkuId != skuId {
-		return ctx.Status(409).JSON(fiber.Map{
-			"message": "You do not have permission to update this product",
-		})
+		return models.Product{}, errProductConflict
 	}
 
-	if body.Name != "" {
-		product.Name = body.Name
-	}
+	var before models.Product
+	product, ok = models.UpdateProduct(product.Id, func(product *models.Product) {
+		before = *product
 
-	if body.Description != "" {
-		product.Description = body.Description
-	}
+		if body.Name != "" {
+			product.Name = body.Name
+		}
 
-	if body.Price != 0 {
-		product.Price = body.Price
-	}
+		if body.Description != "" {
+			product.Description = body.Description
+		}
 
-	models.ProductData[product.SkuId] = product
+		if body.Price != 0 {
+			product.Price = body.Price
+		}
 
-	return ctx.Status(200).JSON(types.OneProductResponse{
-		Message: "Product updated successfully",
-		Product: product,
+		if body.TaxClass != "" {
+			product.TaxClass = body.TaxClass
+		}
 	})
+
+	if !ok {
+		return models.Product{}, errProductNotFound
+	}
+
+	origin.record(productAuditEntry(AuditProductUpdate, product), before, product)
+	models.RecordPriceChange(models.PriceChange{
+		ProductId:     product.Id,
+		PreviousPrice: before.Price,
+		Price:         product.Price,
+		Source:        models.PriceSourceManual,
+	})
+	return product, nil
 }
 
 // DeleteProductEndpoint Delete a product
-// @Summary Delete a product
-// @Description Delete a product in the store
-// @Tags Product
-// @Success 200 {object} MessageResponse
-// @Router /product/:id [delete]
-
 func DeleteProductEndpoint(ctx *fiber.Ctx) error {
 	id := ctx.Params("id")
-	skuId := ctx.Query("skuId")
 
-	if skuId == "" {
-		return ctx.Status(401).JSON(fiber.Map{
-			"message": "Invalid request please provide skuId query parameter",
-		})
+	skuId, err := authorize(ctx, models.PermissionProductsDelete)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteProduct(requestOrigin(ctx), skuId, id); err != nil {
+		return err
 	}
 
+	return ctx.Status(200).JSON(types.MessageResponse{
+		Message: "Product deleted successfully",
+	})
+}
+
+// deleteProduct deletes a product of the merchant skuId with its images
+func deleteProduct(origin auditOrigin, skuId string, id string) error {
 	product, ok := models.FindProductById(id)
 
 	if !ok {
-		return ctx.Status(404).JSON(fiber.Map{
-			"message": "Product not found",
-		})
+		return errProductNotFound
 	}
 
 	if product.SkuId != skuId {
-		return ctx.Status(403).JSON(fiber.Map{
-			"message": "You do not have permission to update this product",
-		})
+		return errProductForbidden
 	}
 
-	delete(models.ProductData, product.Id)
-
-	return ctx.Status(200).JSON(types.MessageResponse{
-		Message: "Product deleted successfully",
-	})
+	images := models.GetProductImages(product.Id)
+	models.DeleteProduct(product.Id)
+	deleteImageBlobs(images)
+	origin.record(productAuditEntry(AuditProductDelete, product), product, nil)
+	return nil
 }
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+)
+
+// GetPromotionsEndpoint Get all promotions
+func GetPromotionsEndpoint(ctx *fiber.Ctx) error {
+	skuId, err := authorize(ctx, models.PermissionPromotionsManage)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(types.GetPromotionsResponse{
+		Message:    "Promotions fetched successfully",
+		Promotions: models.MerchantPromotions(skuId),
+	})
+}
+
+// FindAPromotionEndpoint Get a promotion
+func FindAPromotionEndpoint(ctx *fiber.Ctx) error {
+	promotion, err := ownedPromotion(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(types.PromotionResponse{
+		Message:   "Promotion fetched successfully",
+		Promotion: promotion,
+	})
+}
+
+// CreatePromotionEndpoint Create a promotion
+func CreatePromotionEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.PromotionCreatePayload)
+
+	skuId, err := authorize(ctx, models.PermissionPromotionsManage)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	if body.Type == models.DiscountPercentage && body.Value > 100 {
+		return problem.New(400, problem.CodeBadRequest, "Invalid request a percentage discount cannot be more than 100")
+	}
+
+	if body.StartsAt != nil && body.ExpiresAt != nil && !body.ExpiresAt.After(*body.StartsAt) {
+		return problem.New(400, problem.CodeBadRequest, "Invalid request expiresAt must be after startsAt")
+	}
+
+	for _, id := range body.ProductIds {
+		if product, ok := models.FindProductById(id); !ok || product.SkuId != skuId {
+			return errProductNotFound
+		}
+	}
+
+	for _, id := range body.CategoryIds {
+		if _, ok := models.FindCategoryById(id); !ok {
+			return errCategoryNotFound
+		}
+	}
+
+	promotion, err := models.CreatePromotion(models.Promotion{
+		SkuId:       utils.CopyString(skuId),
+		Name:        body.Name,
+		Type:        body.Type,
+		Value:       body.Value,
+		Code:        body.Code,
+		ProductIds:  body.ProductIds,
+		CategoryIds: body.CategoryIds,
+		UsageLimit:  body.UsageLimit,
+		StartsAt:    body.StartsAt,
+		ExpiresAt:   body.ExpiresAt,
+	})
+
+	if err != nil {
+		return promotionError(err)
+	}
+
+	return ctx.Status(201).JSON(types.PromotionResponse{
+		Message:   "Promotion created successfully",
+		Promotion: promotion,
+	})
+}
+
+// DeletePromotionEndpoint Delete a promotion
+func DeletePromotionEndpoint(ctx *fiber.Ctx) error {
+	promotion, err := ownedPromotion(ctx)
+	if err != nil {
+		return err
+	}
+
+	models.DeletePromotion(promotion.Id)
+
+	return ctx.Status(200).JSON(types.MessageResponse{
+		Message: "Promotion deleted successfully",
+	})
+}
+
+// ownedPromotion loads the promotion in the id param and checks the request may manage the promotions of its merchant
+func ownedPromotion(ctx *fiber.Ctx) (models.Promotion, error) {
+	skuId, err := authorize(ctx, models.PermissionPromotionsManage)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+
+	promotion, ok := models.FindPromotionById(ctx.Params("id"))
+
+	if !ok {
+		return models.Promotion{}, promotionError(models.ErrPromotionNotFound)
+	}
+
+	if promotion.SkuId != skuId {
+		return models.Promotion{}, problem.New(403, problem.CodeForbidden, "You do not have permission to update this promotion")
+	}
+
+	return promotion, nil
+}
+
+func promotionError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrPromotionNotFound):
+		return problem.New(404, problem.CodePromotionNotFound, "Promotion not found")
+	case errors.Is(err, models.ErrCouponExists):
+		return problem.New(409, problem.CodeCouponExists, "A promotion with this code already exists")
+	}
+	return errInternal
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+)
+
+// GetTaxRulesEndpoint Get the tax rules
+func GetTaxRulesEndpoint(ctx *fiber.Ctx) error {
+	return ctx.Status(200).JSON(types.TaxRulesResponse{
+		Message: "Tax rules fetched successfully",
+		Rules:   models.TaxRules(),
+	})
+}
+
+// GetTaxSettingsEndpoint Get the tax settings
+func GetTaxSettingsEndpoint(ctx *fiber.Ctx) error {
+	skuId, err := authorize(ctx, models.PermissionSettingsManage)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(types.TaxSettingsResponse{
+		Message:  "Tax settings fetched successfully",
+		Settings: models.MerchantTaxSettings(skuId),
+	})
+}
+
+// UpdateTaxSettingsEndpoint Update the tax settings
+func UpdateTaxSettingsEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.TaxSettingsPayload)
+
+	skuId, err := authorize(ctx, models.PermissionSettingsManage)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	settings, err := models.SaveTaxSettings(models.TaxSettings{
+		SkuId:            utils.CopyString(skuId),
+		Jurisdiction:     body.Jurisdiction,
+		PricesIncludeTax: *body.PricesIncludeTax,
+	})
+
+	if errors.Is(err, models.ErrUnknownJurisdiction) {
+		return problem.New(400, problem.CodeUnknownJurisdiction, "Invalid request there are no tax rules for this jurisdiction")
+	}
+
+	return ctx.Status(200).JSON(types.TaxSettingsResponse{
+		Message:  "Tax settings updated successfully",
+		Settings: settings,
+	})
+}
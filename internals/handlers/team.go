@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+	"strings"
+)
+
+// GetTeamMembersEndpoint Get the team of a merchant
+func GetTeamMembersEndpoint(ctx *fiber.Ctx) error {
+	skuId, err := authorize(ctx, models.PermissionMembersManage)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(types.GetMembersResponse{
+		Message: "Members fetched successfully",
+		Members: models.MerchantMembers(skuId),
+	})
+}
+
+// InviteMemberEndpoint Invite a member
+func InviteMemberEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.MemberInvitePayload)
+
+	skuId, err := authorize(ctx, models.PermissionMembersManage)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	if role, _ := middleware.RequestRole(ctx); !role.CanManageRole(body.Role) {
+		return errRoleForbidden
+	}
+
+	invitedBy := string(models.RoleOwner)
+	if member, ok := middleware.RequestMember(ctx); ok {
+		invitedBy = member.Id
+	}
+
+	email := strings.ToLower(utils.CopyString(body.Email))
+	member, token, err := models.InviteMember(utils.CopyString(skuId), email, models.Role(utils.CopyString(string(body.Role))), invitedBy)
+
+	if err != nil {
+		return memberError(err)
+	}
+
+	return ctx.Status(201).JSON(types.MemberResponse{
+		Message: "Member invited successfully",
+		Member:  member,
+		Token:   token,
+	})
+}
+
+// AcceptInviteEndpoint Accept an invite
+func AcceptInviteEndpoint(ctx *fiber.Ctx) error {
+	// The invite token is the credential here, whoever accepts it is not a member yet
+	body := new(types.InviteAcceptPayload)
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	member, token, err := models.AcceptInvite(body.Token)
+
+	if err != nil {
+		return memberError(err)
+	}
+
+	return ctx.Status(200).JSON(types.MemberResponse{
+		Message: "Invite accepted successfully",
+		Member:  member,
+		Token:   token,
+	})
+}
+
+// UpdateMemberEndpoint Change the role of a member
+func UpdateMemberEndpoint(ctx *fiber.Ctx) error {
+	body := new(types.MemberUpdatePayload)
+
+	member, err := managedMember(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	if role, _ := middleware.RequestRole(ctx); !role.CanManageRole(body.Role) {
+		return errRoleForbidden
+	}
+
+	member, err = models.UpdateMemberRole(member.Id, models.Role(utils.CopyString(string(body.Role))))
+
+	if err != nil {
+		return memberError(err)
+	}
+
+	return ctx.Status(200).JSON(types.MemberResponse{
+		Message: "Member updated successfully",
+		Member:  member,
+	})
+}
+
+// RemoveMemberEndpoint Remove a member
+func RemoveMemberEndpoint(ctx *fiber.Ctx) error {
+	member, err := managedMember(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !models.RemoveMember(member.Id) {
+		return memberError(models.ErrMemberNotFound)
+	}
+
+	return ctx.Status(200).JSON(types.MessageResponse{
+		Message: "Member removed successfully",
+	})
+}
+
+// authorize checks the role of the request allows the permission and returns the merchant
+func authorize(ctx *fiber.Ctx, permission models.Permission) (string, error) {
+	role, ok := middleware.RequestRole(ctx)
+
+	if err := checkRole(role, ok, permission); err != nil {
+		return "", err
+	}
+
+	return middleware.MerchantId(ctx), nil
+}
+
+// checkRole checks a role, ok is false when the merchant could not be identified
+func checkRole(role models.Role, ok bool, permission models.Permission) error {
+	if !ok {
+		return errMerchantRequired
+	}
+
+	if !role.Can(permission) {
+		return errRoleForbidden
+	}
+	return nil
+}
+
+// managedMember finds the member in the id parameter and checks the request may manage them
+func managedMember(ctx *fiber.Ctx) (models.Member, error) {
+	skuId, err := authorize(ctx, models.PermissionMembersManage)
+	if err != nil {
+		return models.Member{}, err
+	}
+
+	member, ok := models.FindMemberById(ctx.Params("id"))
+
+	if !ok || member.SkuId != skuId {
+		return models.Member{}, memberError(models.ErrMemberNotFound)
+	}
+
+	if role, _ := middleware.RequestRole(ctx); !role.CanManageRole(member.Role) {
+		return models.Member{}, errRoleForbidden
+	}
+
+	return member, nil
+}
+
+func memberError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrMemberNotFound):
+		return problem.New(404, problem.CodeMemberNotFound, "Member not found")
+	case errors.Is(err, models.ErrMemberExists):
+		return problem.New(409, problem.CodeMemberExists, "A member with this email is already in the team")
+	case errors.Is(err, models.ErrInviteNotFound):
+		return problem.New(404, problem.CodeInviteNotFound, "Invite not found or already accepted")
+	}
+	log.Error("Error managing team: ", err)
+	return errInternal
+}
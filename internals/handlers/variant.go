@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/rnwonder/SAL/validators"
+	"time"
+)
+
+// GetProductVariantsEndpoint Get all variants of a product
+func GetProductVariantsEndpoint(ctx *fiber.Ctx) error {
+	product, ok := models.FindProductById(ctx.Params("id"))
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	return ctx.Status(200).JSON(types.GetVariantsResponse{
+		Message:    "Variants fetched successfully",
+		Variants:   models.GetProductVariants(product.Id),
+		PriceRange: models.ProductPriceRange(product),
+	})
+}
+
+// FindAVariantEndpoint Get a variant
+func FindAVariantEndpoint(ctx *fiber.Ctx) error {
+	variant, ok := models.FindVariantById(ctx.Params("id"), ctx.Params("variantId"))
+
+	if !ok {
+		return errVariantNotFound
+	}
+
+	return ctx.Status(200).JSON(types.OneVariantResponse{
+		Message: "Variant fetched successfully",
+		Variant: variant,
+	})
+}
+
+// CreateVariantEndpoint Create a variant
+func CreateVariantEndpoint(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	body := new(types.VariantCreatePayload)
+	skuId, err := authorize(ctx, models.PermissionProductsCreate)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	product, ok := models.FindProductById(id)
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	if product.SkuId != skuId {
+		return errProductForbidden
+	}
+
+	newVariant := models.Variant{
+		Id:        uuid.Must(uuid.NewRandom()).String(),
+		ProductId: product.Id,
+		SkuId:     body.SkuId,
+		Options:   body.Options,
+		Price:     body.Price,
+		Stock:     body.Stock,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := models.CreateVariant(newVariant); err != nil {
+		return variantError(err)
+	}
+
+	recordAudit(ctx, variantAuditEntry(AuditVariantCreate, product, newVariant), nil, newVariant)
+	models.RecordPriceChange(models.PriceChange{
+		ProductId: product.Id,
+		VariantId: newVariant.Id,
+		Price:     newVariant.Price,
+		Source:    models.PriceSourceManual,
+	})
+
+	return ctx.Status(201).JSON(types.OneVariantResponse{
+		Message: "Variant created successfully",
+		Variant: newVariant,
+	})
+}
+
+// UpdateVariantEndpoint Update a variant
+func UpdateVariantEndpoint(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	body := new(types.VariantUpdatePayload)
+	skuId, err := authorize(ctx, models.PermissionProductsUpdate)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.BodyParser(body); err != nil {
+		return errInvalidPayload
+	}
+
+	if err := validators.Validator(body, ctx.Get(fiber.HeaderAcceptLanguage)); err != nil {
+		return err
+	}
+
+	product, ok := models.FindProductById(id)
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	if product.SkuId != skuId {
+		return errProductForbidden
+	}
+
+	variant, ok := models.FindVariantById(product.Id, ctx.Params("variantId"))
+
+	if !ok {
+		return errVariantNotFound
+	}
+
+	var before models.Variant
+	variant, err = models.UpdateVariant(variant.Id, func(variant *models.Variant) {
+		before = *variant
+
+		if body.SkuId != "" {
+			variant.SkuId = body.SkuId
+		}
+
+		if len(body.Options) > 0 {
+			variant.Options = body.Options
+		}
+
+		if body.Price != 0 {
+			variant.Price = body.Price
+		}
+
+		if body.Stock != nil {
+			variant.Stock = *body.Stock
+		}
+	})
+
+	if err != nil {
+		return variantError(err)
+	}
+
+	recordAudit(ctx, variantAuditEntry(AuditVariantUpdate, product, variant), before, variant)
+	models.RecordPriceChange(models.PriceChange{
+		ProductId:     product.Id,
+		VariantId:     variant.Id,
+		PreviousPrice: before.Price,
+		Price:         variant.Price,
+		Source:        models.PriceSourceManual,
+	})
+
+	return ctx.Status(200).JSON(types.OneVariantResponse{
+		Message: "Variant updated successfully",
+		Variant: variant,
+	})
+}
+
+// DeleteVariantEndpoint Delete a variant
+func DeleteVariantEndpoint(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	skuId, err := authorize(ctx, models.PermissionProductsDelete)
+	if err != nil {
+		return err
+	}
+
+	product, ok := models.FindProductById(id)
+
+	if !ok {
+		return errProductNotFound
+	}
+
+	if product.SkuId != skuId {
+		return errProductForbidden
+	}
+
+	variant, ok := models.FindVariantById(product.Id, ctx.Params("variantId"))
+
+	if !ok {
+		return errVariantNotFound
+	}
+
+	models.DeleteVariant(variant.Id)
+	recordAudit(ctx, variantAuditEntry(AuditVariantDelete, product, variant), variant, nil)
+
+	return ctx.Status(200).JSON(types.MessageResponse{
+		Message: "Variant deleted successfully",
+	})
+}
+
+func variantError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrVariantNotFound):
+		return errVariantNotFound
+	case errors.Is(err, models.ErrVariantSkuExists):
+		return errVariantSkuExists
+	case errors.Is(err, models.ErrVariantOptionsExist):
+		return errVariantOptsExists
+	case errors.Is(err, models.ErrVariantStockReserved):
+		return problem.New(409, problem.CodeInsufficientStock, "Stock cannot be set below the units reserved")
+	}
+	return errInternal
+}
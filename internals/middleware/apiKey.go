@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+)
+
+// APIKeyHeader carries the API key of server to server integrations
+const APIKeyHeader = "X-API-Key"
+
+const apiKeyLocal = "apiKey"
+
+// APIKeyAuth authenticates requests sent with an X-API-Key header, requests without one
+// carry on with the skuId query parameter
+func APIKeyAuth(ctx *fiber.Ctx) error {
+	secret := ctx.Get(APIKeyHeader)
+
+	if secret == "" {
+		return ctx.Next()
+	}
+
+	key, err := models.AuthenticateAPIKey(secret)
+
+	if err != nil {
+		return problem.New(401, problem.CodeInvalidAPIKey, "Invalid or revoked API key")
+	}
+
+	ctx.Locals(apiKeyLocal, key)
+	return ctx.Next()
+}
+
+// RequestAPIKey returns the key the request was authenticated with
+func RequestAPIKey(ctx *fiber.Ctx) (models.APIKey, bool) {
+	key, ok := ctx.Locals(apiKeyLocal).(models.APIKey)
+	return key, ok
+}
+
+// RequireScope checks API keys hold readScope for GET and HEAD requests and writeScope for the others.
+// Requests without an API key are checked like CheckScope does.
+func RequireScope(readScope string, writeScope string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		scope := writeScope
+		if ctx.Method() == fiber.MethodGet || ctx.Method() == fiber.MethodHead {
+			scope = readScope
+		}
+
+		if err := CheckScope(ctx, scope); err != nil {
+			return err
+		}
+
+		return ctx.Next()
+	}
+}
+
+// CheckScope checks the API key of the request holds scope, for handlers whose scope does not follow the method.
+// Members are limited by their role instead. A bare skuId of a merchant with members or API keys only gets the read
+// scopes, leaving out the key does not get around its scopes.
+func CheckScope(ctx *fiber.Ctx, scope string) error {
+	if key, ok := RequestAPIKey(ctx); ok {
+		if !key.HasScope(scope) {
+			return problem.New(403, problem.CodeInsufficientScope, "API key is missing the "+scope+" scope")
+		}
+		return nil
+	}
+
+	if _, ok := RequestMember(ctx); ok || models.IsReadScope(scope) {
+		return nil
+	}
+
+	if skuId := ctx.Query("skuId"); skuId != "" && models.SkuIdRole(skuId) != models.RoleOwner {
+		return problem.New(401, problem.CodeMerchantRequired, "Send an API key or member token to make changes for this merchant")
+	}
+	return nil
+}
+
+// DenyAPIKeys keeps API keys away from routes only the merchant should use, such as managing the keys
+func DenyAPIKeys(ctx *fiber.Ctx) error {
+	if _, ok := RequestAPIKey(ctx); ok {
+		return problem.New(403, problem.CodeAPIKeyNotAllowed, "API keys cannot be used for this request")
+	}
+	return ctx.Next()
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// Idempotency replays the first response of a mutating request when it is retried with the same
+// Idempotency-Key header within ttl. Keys are scoped to the API key or the member, or to the IP for anonymous clients.
+func Idempotency(ttl time.Duration) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(IdempotencyKeyHeader)
+
+		if key == "" || !isMutatingMethod(ctx.Method()) {
+			return ctx.Next()
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			return problem.New(400, problem.CodeIdempotencyKeyTooLong, "Idempotency-Key must be at most 255 characters")
+		}
+
+		key = utils.CopyString(key)
+		owner := idempotencyOwner(ctx)
+		stored, replay, err := models.BeginIdempotentRequest(owner, key, requestFingerprint(ctx), ttl)
+
+		if errors.Is(err, models.ErrIdempotencyKeyReused) {
+			return problem.New(422, problem.CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+		}
+
+		if errors.Is(err, models.ErrIdempotencyKeyInProgress) {
+			return problem.New(409, problem.CodeIdempotencyKeyInProgress, "A request with this Idempotency-Key is still being processed")
+		}
+
+		if replay {
+			ctx.Set(IdempotencyReplayedHeader, "true")
+			ctx.Set(fiber.HeaderContentType, stored.ContentType)
+			return ctx.Status(stored.Status).Send(stored.Body)
+		}
+
+		// Errors are written here rather than by the app so they are stored and replayed like any other response
+		if err := ctx.Next(); err != nil {
+			if err := ctx.App().ErrorHandler(ctx, err); err != nil {
+				models.AbortIdempotentRequest(owner, key)
+				return err
+			}
+		}
+
+		// Server errors are not stored so the client can retry them
+		status := ctx.Response().StatusCode()
+		if status >= 500 {
+			models.AbortIdempotentRequest(owner, key)
+			return nil
+		}
+
+		body := append([]byte(nil), ctx.Response().Body()...)
+		models.CompleteIdempotentRequest(owner, key, status, string(ctx.Response().Header.ContentType()), body)
+		return nil
+	}
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// idempotencyOwner scopes stored responses to the API key or the member that APIKeyAuth and MemberAuth checked,
+// and then to the client IP. skuId is left out, anyone can send it and get the responses stored for another client.
+func idempotencyOwner(ctx *fiber.Ctx) string {
+	if key, ok := RequestAPIKey(ctx); ok {
+		return "key:" + key.Id
+	}
+
+	if member, ok := RequestMember(ctx); ok {
+		return "member:" + member.Id
+	}
+
+	return "ip:" + ctx.IP()
+}
+
+// requestFingerprint identifies the request a key was first used for
+func requestFingerprint(ctx *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(ctx.Method()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(ctx.OriginalURL()))
+	hash.Write([]byte{0})
+	hash.Write(ctx.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
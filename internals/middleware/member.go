@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+)
+
+// MemberTokenHeader carries the access token of a team member
+const MemberTokenHeader = "X-Member-Token"
+
+const memberLocal = "member"
+
+// MemberAuth authenticates team members sending an X-Member-Token header
+func MemberAuth(ctx *fiber.Ctx) error {
+	token := ctx.Get(MemberTokenHeader)
+
+	if token == "" {
+		return ctx.Next()
+	}
+
+	member, err := models.AuthenticateMember(token)
+
+	if err != nil {
+		return problem.New(401, problem.CodeInvalidMemberToken, "Invalid member token")
+	}
+
+	ctx.Locals(memberLocal, member)
+	return ctx.Next()
+}
+
+// RequestMember returns the team member making the request
+func RequestMember(ctx *fiber.Ctx) (models.Member, bool) {
+	member, ok := ctx.Locals(memberLocal).(models.Member)
+	return member, ok
+}
+
+// MerchantId returns the merchant making the request, from its API key or member token
+// or else the skuId query parameter
+func MerchantId(ctx *fiber.Ctx) string {
+	if key, ok := RequestAPIKey(ctx); ok {
+		return key.SkuId
+	}
+	if member, ok := RequestMember(ctx); ok {
+		return member.SkuId
+	}
+	return ctx.Query("skuId")
+}
+
+// RequestRole returns the role the request acts with. Members have their own role and API keys act as the
+// owner, still limited by their scopes. A bare skuId gets the role models.SkuIdRole gives it.
+func RequestRole(ctx *fiber.Ctx) (models.Role, bool) {
+	if member, ok := RequestMember(ctx); ok {
+		return member.Role, true
+	}
+	if _, ok := RequestAPIKey(ctx); ok {
+		return models.RoleOwner, true
+	}
+	if skuId := ctx.Query("skuId"); skuId != "" {
+		return models.SkuIdRole(skuId), true
+	}
+	return "", false
+}
@@ -0,0 +1,276 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/problem"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitRule allows Requests requests every Per, unused requests build up to a burst of Requests
+type RateLimitRule struct {
+	Requests int
+	Per      time.Duration
+}
+
+type RateLimitConfig struct {
+	Default RateLimitRule
+	// Routes overrides the default for requests matching "METHOD /path/prefix", the longest match wins
+	Routes map[string]RateLimitRule
+	// Keys names the buckets a request is counted against, it is throttled once any of them is empty.
+	// RateLimitKeys is used when it is nil.
+	Keys func(ctx *fiber.Ctx) []string
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type rateLimiter struct {
+	config     RateLimitConfig
+	lock       sync.Mutex
+	buckets    map[string]*tokenBucket
+	sweptAt    time.Time
+	longestPer time.Duration
+}
+
+// RateLimit throttles clients with a token bucket per client and route rule. Every response carries the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, throttled requests get a 429 with Retry-After.
+func RateLimit(config RateLimitConfig) fiber.Handler {
+	limiter := &rateLimiter{
+		config:     config,
+		buckets:    map[string]*tokenBucket{},
+		sweptAt:    time.Now(),
+		longestPer: config.Default.Per,
+	}
+
+	if limiter.config.Keys == nil {
+		limiter.config.Keys = RateLimitKeys
+	}
+
+	for _, rule := range config.Routes {
+		limiter.longestPer = max(limiter.longestPer, rule.Per)
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		name, rule := limiter.rule(ctx.Method(), ctx.Path())
+
+		if rule.Requests <= 0 || rule.Per <= 0 {
+			return ctx.Next()
+		}
+
+		keys := limiter.config.Keys(ctx)
+		now := time.Now()
+
+		// Every bucket is checked before any is spent, a request refused by one bucket costs nothing in the others
+		remaining, retryAfter, reset := rule.Requests, time.Duration(0), time.Duration(0)
+		for _, key := range keys {
+			left, wait, full := limiter.take(name+"|"+key, rule, now, false)
+			remaining, retryAfter, reset = min(remaining, left), max(retryAfter, wait), max(reset, full)
+		}
+
+		if retryAfter == 0 {
+			remaining, reset = rule.Requests, 0
+			for _, key := range keys {
+				left, _, full := limiter.take(name+"|"+key, rule, now, true)
+				remaining, reset = min(remaining, left), max(reset, full)
+			}
+		}
+
+		ctx.Set("RateLimit-Limit", strconv.Itoa(rule.Requests))
+		ctx.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		ctx.Set("RateLimit-Reset", strconv.Itoa(seconds(reset)))
+
+		if retryAfter > 0 {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds(retryAfter)))
+			return problem.New(429, problem.CodeRateLimited, "Too many requests, please retry later")
+		}
+
+		return ctx.Next()
+	}
+}
+
+// AuthFailureLimit throttles client IPs whose API keys or member tokens keep being rejected. It runs before APIKeyAuth
+// and MemberAuth, which answer bad credentials before RateLimit counts anything. Only the failures are counted, once
+// rule is used up the IP gets a 429 before its credentials are checked.
+func AuthFailureLimit(rule RateLimitRule) fiber.Handler {
+	limiter := &rateLimiter{
+		config:     RateLimitConfig{Default: rule},
+		buckets:    map[string]*tokenBucket{},
+		sweptAt:    time.Now(),
+		longestPer: rule.Per,
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		if rule.Requests <= 0 || rule.Per <= 0 {
+			return ctx.Next()
+		}
+
+		key := "ip:" + ctx.IP()
+
+		if _, retryAfter, _ := limiter.take(key, rule, time.Now(), false); retryAfter > 0 {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds(retryAfter)))
+			return problem.New(429, problem.CodeRateLimited, "Too many failed authentications, please retry later")
+		}
+
+		err := ctx.Next()
+
+		if err != nil {
+			if code := problem.From(err).Code; code == problem.CodeInvalidAPIKey || code == problem.CodeInvalidMemberToken {
+				limiter.take(key, rule, time.Now(), true)
+			}
+		}
+		return err
+	}
+}
+
+// RateLimitKeys counts requests against the API key or the member, which APIKeyAuth and MemberAuth have checked
+// before, then against the merchant in skuId and then the client IP. Anyone can send a skuId, so requests naming a
+// merchant are counted against their IP as well and a made up skuId never gets a client a fresh bucket.
+func RateLimitKeys(ctx *fiber.Ctx) []string {
+	if key, ok := RequestAPIKey(ctx); ok {
+		return []string{"key:" + key.Id}
+	}
+
+	if member, ok := RequestMember(ctx); ok {
+		return []string{"member:" + member.Id}
+	}
+
+	if skuId := MerchantId(ctx); skuId != "" {
+		return []string{"merchant:" + skuId, "ip:" + ctx.IP()}
+	}
+
+	return []string{"ip:" + ctx.IP()}
+}
+
+func (limiter *rateLimiter) rule(method string, path string) (string, RateLimitRule) {
+	name, rule := "", limiter.config.Default
+	request := method + " " + path
+
+	for route, routeRule := range limiter.config.Routes {
+		if len(route) > len(name) && matchesRoute(request, route) {
+			name, rule = route, routeRule
+		}
+	}
+	return name, rule
+}
+
+func matchesRoute(request string, route string) bool {
+	if !strings.HasPrefix(request, route) {
+		return false
+	}
+	// "GET /product" matches "/product" and "/product/1" but not "/products"
+	rest := request[len(route):]
+	return rest == "" || rest[0] == '/' || strings.HasSuffix(route, "/")
+}
+
+// take spends a token when spend is set and returns the tokens left, how long to wait when none was left and
+// how long until the bucket is full again
+func (limiter *rateLimiter) take(key string, rule RateLimitRule, now time.Time, spend bool) (int, time.Duration, time.Duration) {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+
+	bucket := limiter.refill(key, rule, now)
+	capacity := float64(rule.Requests)
+	perToken := rule.Per / time.Duration(rule.Requests)
+
+	var retryAfter time.Duration
+	if bucket.tokens < 1 {
+		retryAfter = time.Duration((1 - bucket.tokens) * float64(perToken))
+	} else if spend {
+		bucket.tokens--
+	}
+
+	reset := time.Duration((capacity - bucket.tokens) * float64(perToken))
+	return int(bucket.tokens), retryAfter, reset
+}
+
+// refill adds the tokens earned since the bucket was last used, the caller holds the lock
+func (limiter *rateLimiter) refill(key string, rule RateLimitRule, now time.Time) *tokenBucket {
+	limiter.sweep(now)
+
+	capacity := float64(rule.Requests)
+	perToken := rule.Per / time.Duration(rule.Requests)
+
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updatedAt: now}
+		limiter.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updatedAt)
+	bucket.tokens = math.Min(capacity, bucket.tokens+float64(elapsed)/float64(perToken))
+	bucket.updatedAt = now
+	return bucket
+}
+
+// sweep forgets the buckets that have had time to refill, they are the same as new buckets
+func (limiter *rateLimiter) sweep(now time.Time) {
+	if now.Sub(limiter.sweptAt) < limiter.longestPer {
+		return
+	}
+
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.updatedAt) >= limiter.longestPer {
+			delete(limiter.buckets, key)
+		}
+	}
+	limiter.sweptAt = now
+}
+
+func seconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
+
+// ParseRateLimitRule reads a rule written as requests/duration, for example 120/1m
+func ParseRateLimitRule(value string) (RateLimitRule, error) {
+	requests, per, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return RateLimitRule{}, fmt.Errorf("invalid rate limit %q, expected requests/duration", value)
+	}
+
+	count, err := strconv.Atoi(requests)
+	if err != nil || count <= 0 {
+		return RateLimitRule{}, fmt.Errorf("invalid rate limit %q, requests must be a positive number", value)
+	}
+
+	duration, err := time.ParseDuration(per)
+	if err != nil || duration <= 0 {
+		return RateLimitRule{}, fmt.Errorf("invalid rate limit %q, duration must be positive", value)
+	}
+
+	return RateLimitRule{Requests: count, Per: duration}, nil
+}
+
+// ParseRateLimitRoutes reads route rules separated by semicolons, for example "POST /product=30/1m;GET /product/export=5/1m"
+func ParseRateLimitRoutes(value string) (map[string]RateLimitRule, error) {
+	routes := map[string]RateLimitRule{}
+
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		route, limit, ok := strings.Cut(entry, "=")
+		route = strings.Join(strings.Fields(route), " ")
+
+		if !ok || len(strings.Fields(route)) != 2 {
+			return nil, fmt.Errorf("invalid route rate limit %q, expected METHOD /path=requests/duration", entry)
+		}
+
+		rule, err := ParseRateLimitRule(limit)
+		if err != nil {
+			return nil, err
+		}
+
+		method, path, _ := strings.Cut(route, " ")
+		routes[strings.ToUpper(method)+" "+path] = rule
+	}
+
+	return routes, nil
+}
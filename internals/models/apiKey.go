@@ -0,0 +1,218 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeOrdersRead    = "orders:read"
+	ScopeOrdersWrite   = "orders:write"
+)
+
+// apiKeyPrefix starts every key so leaked keys are easy to spot
+const apiKeyPrefix = "sal_"
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key revoked")
+)
+
+// APIKey lets a merchant call the API from their own systems. Only the sha256 hash of the
+// secret is kept, the secret itself is returned once when the key is created or rotated.
+type APIKey struct {
+	Id     string   `json:"id"`
+	SkuId  string   `json:"skuId"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Prefix is the start of the secret, it helps merchants tell their keys apart
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	RotatedAt  *time.Time `json:"rotatedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+var apiKeyLock sync.RWMutex
+
+var APIKeyData = map[string]APIKey{}
+
+// apiKeyHashes finds the id of a key from the hash of its secret
+var apiKeyHashes = map[string]string{}
+
+// IsReadScope reports whether scope only lets a key read
+func IsReadScope(scope string) bool {
+	return scope == ScopeProductsRead || scope == ScopeOrdersRead
+}
+
+func IsScope(scope string) bool {
+	switch scope {
+	case ScopeProductsRead, ScopeProductsWrite, ScopeOrdersRead, ScopeOrdersWrite:
+		return true
+	}
+	return false
+}
+
+func (key APIKey) HasScope(scope string) bool {
+	for _, granted := range key.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HashSecret is how API keys and member tokens are stored, they are long random values so sha256 is enough
+func HashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func newSecret(prefix string) (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(random), nil
+}
+
+// CreateAPIKey stores a new key for the merchant and returns it with its secret
+func CreateAPIKey(skuId string, name string, scopes []string) (APIKey, string, error) {
+	secret, err := newSecret(apiKeyPrefix)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	key := APIKey{
+		Id:        uuid.Must(uuid.NewRandom()).String(),
+		SkuId:     skuId,
+		Name:      name,
+		Scopes:    scopes,
+		Prefix:    secret[:len(apiKeyPrefix)+8],
+		Hash:      HashSecret(secret),
+		CreatedAt: time.Now(),
+	}
+
+	apiKeyLock.Lock()
+	defer apiKeyLock.Unlock()
+
+	APIKeyData[key.Id] = key
+	apiKeyHashes[key.Hash] = key.Id
+	return key, secret, nil
+}
+
+// MerchantAPIKeys returns the keys of a merchant, revoked keys included, oldest first
+func MerchantAPIKeys(skuId string) []APIKey {
+	apiKeyLock.RLock()
+	defer apiKeyLock.RUnlock()
+
+	keys := make([]APIKey, 0)
+	for _, key := range APIKeyData {
+		if key.SkuId == skuId {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// MerchantHasAPIKeys reports whether the merchant has a key that is not revoked
+func MerchantHasAPIKeys(skuId string) bool {
+	apiKeyLock.RLock()
+	defer apiKeyLock.RUnlock()
+
+	for _, key := range APIKeyData {
+		if key.SkuId == skuId && key.RevokedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func FindAPIKeyById(id string) (APIKey, bool) {
+	apiKeyLock.RLock()
+	defer apiKeyLock.RUnlock()
+
+	key, ok := APIKeyData[id]
+	return key, ok
+}
+
+// AuthenticateAPIKey finds the active key with the secret and records that it was used
+func AuthenticateAPIKey(secret string) (APIKey, error) {
+	hash := HashSecret(secret)
+
+	apiKeyLock.Lock()
+	defer apiKeyLock.Unlock()
+
+	key, ok := APIKeyData[apiKeyHashes[hash]]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+
+	if key.RevokedAt != nil {
+		return APIKey{}, ErrAPIKeyRevoked
+	}
+
+	now := time.Now()
+	key.LastUsedAt = &now
+	APIKeyData[key.Id] = key
+	return key, nil
+}
+
+func RevokeAPIKey(id string) (APIKey, error) {
+	apiKeyLock.Lock()
+	defer apiKeyLock.Unlock()
+
+	key, ok := APIKeyData[id]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		delete(apiKeyHashes, key.Hash)
+		APIKeyData[id] = key
+	}
+	return key, nil
+}
+
+// RotateAPIKey replaces the secret of a key, the previous secret stops working straight away
+func RotateAPIKey(id string) (APIKey, string, error) {
+	secret, err := newSecret(apiKeyPrefix)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	apiKeyLock.Lock()
+	defer apiKeyLock.Unlock()
+
+	key, ok := APIKeyData[id]
+	if !ok {
+		return APIKey{}, "", ErrAPIKeyNotFound
+	}
+
+	if key.RevokedAt != nil {
+		return APIKey{}, "", ErrAPIKeyRevoked
+	}
+
+	now := time.Now()
+	delete(apiKeyHashes, key.Hash)
+	key.Hash = HashSecret(secret)
+	key.Prefix = secret[:len(apiKeyPrefix)+8]
+	key.RotatedAt = &now
+	apiKeyHashes[key.Hash] = key.Id
+	APIKeyData[id] = key
+	return key, secret, nil
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+type AuditActor struct {
+	// Type is merchant, member or apiKey
+	Type  string `json:"type"`
+	Id    string `json:"id"`
+	Email string `json:"email,omitempty"`
+}
+
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry records one change to a product or something belonging to it, entries are never changed or removed
+type AuditEntry struct {
+	Id           string        `json:"id"`
+	SkuId        string        `json:"skuId"`
+	ProductId    string        `json:"productId"`
+	ResourceType string        `json:"resourceType"`
+	ResourceId   string        `json:"resourceId"`
+	Action       string        `json:"action"`
+	Actor        AuditActor    `json:"actor"`
+	RequestId    string        `json:"requestId"`
+	Changes      []FieldChange `json:"changes"`
+	At           time.Time     `json:"at"`
+}
+
+type AuditFilter struct {
+	SkuId        string
+	ProductId    string
+	ResourceType string
+	Action       string
+	ActorId      string
+	From         time.Time
+	To           time.Time
+}
+
+var auditLock sync.RWMutex
+
+// AuditData is append only, entries are kept in the order they were recorded
+var AuditData = make([]AuditEntry, 0)
+
+// fields that change on every write and would only add noise to a diff
+var ignoredAuditFields = map[string]bool{
+	"updatedAt": true,
+}
+
+func RecordAudit(entry AuditEntry) AuditEntry {
+	auditLock.Lock()
+	defer auditLock.Unlock()
+
+	entry.Id = uuid.Must(uuid.NewRandom()).String()
+	entry.At = time.Now()
+	if entry.Changes == nil {
+		entry.Changes = []FieldChange{}
+	}
+
+	AuditData = append(AuditData, entry)
+	return entry
+}
+
+func (filter AuditFilter) Matches(entry AuditEntry) bool {
+	switch {
+	case filter.SkuId != "" && entry.SkuId != filter.SkuId,
+		filter.ProductId != "" && entry.ProductId != filter.ProductId,
+		filter.ResourceType != "" && entry.ResourceType != filter.ResourceType,
+		filter.Action != "" && entry.Action != filter.Action,
+		filter.ActorId != "" && entry.Actor.Id != filter.ActorId,
+		!filter.From.IsZero() && entry.At.Before(filter.From),
+		!filter.To.IsZero() && entry.At.After(filter.To):
+		return false
+	}
+	return true
+}
+
+// AuditEntries returns the entries matching the filter, newest first
+func AuditEntries(filter AuditFilter) []AuditEntry {
+	auditLock.RLock()
+	defer auditLock.RUnlock()
+
+	entries := make([]AuditEntry, 0)
+	for i := len(AuditData) - 1; i >= 0; i-- {
+		if filter.Matches(AuditData[i]) {
+			entries = append(entries, AuditData[i])
+		}
+	}
+	return entries
+}
+
+// DiffFields compares the JSON fields of two values, a nil value stands for a resource that
+// did not exist so every field of the other value is reported
+func DiffFields(before interface{}, after interface{}) []FieldChange {
+	beforeFields, afterFields := jsonFields(before), jsonFields(after)
+
+	names := map[string]bool{}
+	for name := range beforeFields {
+		names[name] = true
+	}
+	for name := range afterFields {
+		names[name] = true
+	}
+
+	changes := make([]FieldChange, 0)
+	for name := range names {
+		if ignoredAuditFields[name] || reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, Before: beforeFields[name], After: afterFields[name]})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+func jsonFields(value interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return fields
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
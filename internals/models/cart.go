@@ -0,0 +1,196 @@
+package models
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// CartInactivityTimeout is how long a cart is kept after it was last used
+const CartInactivityTimeout = 72 * time.Hour
+
+var (
+	ErrCartNotFound     = errors.New("cart not found")
+	ErrCartItemNotFound = errors.New("cart item not found")
+)
+
+type CartItem struct {
+	Id        string `json:"id"`
+	ProductId string `json:"productId"`
+	VariantId string `json:"variantId"`
+	Quantity  int    `json:"quantity"`
+}
+
+type Cart struct {
+	Id             string     `json:"id"`
+	Items          []CartItem `json:"items"`
+	Coupons        []string   `json:"coupons"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	LastActivityAt time.Time  `json:"lastActivityAt"`
+}
+
+// cartLock guards CartData, carts never touch the catalog while holding it
+var cartLock sync.Mutex
+
+var CartData = map[string]Cart{}
+
+func (cart Cart) ExpiresAt() time.Time {
+	return cart.LastActivityAt.Add(CartInactivityTimeout)
+}
+
+func (cart Cart) Expired(now time.Time) bool {
+	return !now.Before(cart.ExpiresAt())
+}
+
+func CreateCart() Cart {
+	cartLock.Lock()
+	defer cartLock.Unlock()
+
+	now := time.Now()
+	cart := Cart{
+		Id:             uuid.Must(uuid.NewRandom()).String(),
+		Items:          []CartItem{},
+		Coupons:        []string{},
+		CreatedAt:      now,
+		LastActivityAt: now,
+	}
+	CartData[cart.Id] = cart
+	return cart
+}
+
+// FindCartById returns a cart that has not expired and marks it as used
+func FindCartById(id string) (Cart, bool) {
+	cart, err := UpdateCart(id, func(cart *Cart) error {
+		return nil
+	})
+	return cart, err == nil
+}
+
+// UpdateCart applies update to a cart that has not expired while holding the cart lock.
+// The cart is only saved, and its inactivity timer reset, when update succeeds.
+func UpdateCart(id string, update func(cart *Cart) error) (Cart, error) {
+	cartLock.Lock()
+	defer cartLock.Unlock()
+
+	cart, ok := CartData[id]
+	if !ok || cart.Expired(time.Now()) {
+		return Cart{}, ErrCartNotFound
+	}
+
+	cart.Items = append([]CartItem{}, cart.Items...)
+	cart.Coupons = append([]string{}, cart.Coupons...)
+	if err := update(&cart); err != nil {
+		return Cart{}, err
+	}
+
+	cart.LastActivityAt = time.Now()
+	CartData[id] = cart
+	return cart, nil
+}
+
+func DeleteCart(id string) bool {
+	cartLock.Lock()
+	defer cartLock.Unlock()
+
+	cart, ok := CartData[id]
+	delete(CartData, id)
+	return ok && !cart.Expired(time.Now())
+}
+
+// ExpireCarts drops every cart that has been inactive for longer than CartInactivityTimeout
+func ExpireCarts(now time.Time) int {
+	cartLock.Lock()
+	defer cartLock.Unlock()
+
+	expired := 0
+	for id, cart := range CartData {
+		if cart.Expired(now) {
+			delete(CartData, id)
+			expired++
+		}
+	}
+	return expired
+}
+
+// AddItem adds quantity units of a product or variant, merging them into an existing line
+func (cart *Cart) AddItem(productId string, variantId string, quantity int) CartItem {
+	for i, item := range cart.Items {
+		if item.ProductId == productId && item.VariantId == variantId {
+			cart.Items[i].Quantity += quantity
+			return cart.Items[i]
+		}
+	}
+
+	item := CartItem{
+		Id:        uuid.Must(uuid.NewRandom()).String(),
+		ProductId: productId,
+		VariantId: variantId,
+		Quantity:  quantity,
+	}
+	cart.Items = append(cart.Items, item)
+	return item
+}
+
+func (cart *Cart) SetItemQuantity(itemId string, quantity int) error {
+	for i, item := range cart.Items {
+		if item.Id == itemId {
+			cart.Items[i].Quantity = quantity
+			return nil
+		}
+	}
+	return ErrCartItemNotFound
+}
+
+func (cart *Cart) RemoveItem(itemId string) error {
+	for i, item := range cart.Items {
+		if item.Id == itemId {
+			cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+			return nil
+		}
+	}
+	return ErrCartItemNotFound
+}
+
+// AddCoupon adds a coupon code to the cart once
+func (cart *Cart) AddCoupon(code string) {
+	code = NormalizeCouponCode(code)
+	for _, existing := range cart.Coupons {
+		if existing == code {
+			return
+		}
+	}
+	cart.Coupons = append(cart.Coupons, code)
+}
+
+func (cart *Cart) RemoveCoupon(code string) error {
+	code = NormalizeCouponCode(code)
+	for i, existing := range cart.Coupons {
+		if existing == code {
+			cart.Coupons = append(cart.Coupons[:i], cart.Coupons[i+1:]...)
+			return nil
+		}
+	}
+	return ErrCouponNotFound
+}
+
+// UnitPrice returns the current price of a product, or of one of its variants when variantId is set
+func UnitPrice(productId string, variantId string) (float32, Product, error) {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	product, ok := ProductData[productId]
+	if !ok {
+		return 0, Product{}, ErrProductNotFound
+	}
+
+	if variantId == "" {
+		return EffectivePrice(productId, "", product.Price, time.Now()), product, nil
+	}
+
+	variant, ok := VariantData[variantId]
+	if !ok || variant.ProductId != productId {
+		return 0, Product{}, ErrVariantNotFound
+	}
+	return EffectivePrice(productId, variantId, variant.Price, time.Now()), product, nil
+}
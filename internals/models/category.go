@@ -0,0 +1,133 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+type Category struct {
+	Id          string    `json:"id"`
+	SkuId       string    `json:"skuId"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ParentId    string    `json:"parentId"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+var CategoryData = map[string]Category{}
+
+func GetAllCategories() []Category {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	categories := make([]Category, 0, len(CategoryData))
+	for _, category := range CategoryData {
+		categories = append(categories, category)
+	}
+	sortCategories(categories)
+	return categories
+}
+
+func FindCategoryById(id string) (Category, bool) {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	category, ok := CategoryData[id]
+	return category, ok
+}
+
+func CategoryChildren(id string) []Category {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	return categoryChildren(id)
+}
+
+func categoryChildren(id string) []Category {
+	children := make([]Category, 0)
+	for _, category := range CategoryData {
+		if category.ParentId == id {
+			children = append(children, category)
+		}
+	}
+	sortCategories(children)
+	return children
+}
+
+// CategoryDescendantIds returns the id of the category and of every category below it
+func CategoryDescendantIds(id string) map[string]bool {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	ids := map[string]bool{}
+	if _, ok := CategoryData[id]; !ok {
+		return ids
+	}
+
+	queue := []string{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if ids[current] {
+			continue
+		}
+		ids[current] = true
+		for _, child := range categoryChildren(current) {
+			queue = append(queue, child.Id)
+		}
+	}
+	return ids
+}
+
+// IsCategoryDescendant reports whether id is ancestorId itself or sits anywhere below it
+func IsCategoryDescendant(ancestorId string, id string) bool {
+	return CategoryDescendantIds(ancestorId)[id]
+}
+
+func SaveCategory(category Category) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	CategoryData[category.Id] = category
+}
+
+// DeleteCategory removes a category and unassigns it from every product
+func DeleteCategory(categoryId string) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	delete(CategoryData, categoryId)
+	for id, product := range ProductData {
+		if product.HasCategory(categoryId) {
+			product.RemoveCategory(categoryId)
+			ProductData[id] = product
+			publishProductEvent(ProductUpdated, product)
+		}
+	}
+}
+
+func (product Product) HasCategory(categoryId string) bool {
+	for _, id := range product.CategoryIds {
+		if id == categoryId {
+			return true
+		}
+	}
+	return false
+}
+
+func (product *Product) RemoveCategory(categoryId string) {
+	categoryIds := make([]string, 0, len(product.CategoryIds))
+	for _, id := range product.CategoryIds {
+		if id != categoryId {
+			categoryIds = append(categoryIds, id)
+		}
+	}
+	product.CategoryIds = categoryIds
+}
+
+func sortCategories(categories []Category) {
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type ProductEventType string
+
+const (
+	ProductCreated ProductEventType = "product.created"
+	ProductUpdated ProductEventType = "product.updated"
+	ProductDeleted ProductEventType = "product.deleted"
+)
+
+// ProductEvent is a change to a product, Product is the product after the change or as it was before it was deleted.
+// Ids increase by one with every change and start over when the server restarts, ProductEventEpoch tells them apart.
+type ProductEvent struct {
+	Id      uint64           `json:"id"`
+	Type    ProductEventType `json:"type"`
+	Product Product          `json:"product"`
+	At      time.Time        `json:"at"`
+}
+
+// ProductEventEpoch names this run of the server, ids of changes are only comparable within the same epoch
+var ProductEventEpoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// ProductEventHistory is how many of the latest product changes are kept for clients resuming a stream
+var ProductEventHistory = 1000
+
+var (
+	eventsLock         sync.Mutex
+	productSubscribers = map[chan ProductEvent]struct{}{}
+	// productEvents is a ring of the latest changes, the oldest one is at productEventsStart
+	productEvents      []ProductEvent
+	productEventsStart int
+	lastProductEventId uint64
+)
+
+// SubscribeProductEvents returns a channel receiving every product change from now on and a function ending
+// the subscription. A subscriber that falls more than buffer events behind has its channel closed, it missed
+// changes and has to start over.
+func SubscribeProductEvents(buffer int) (<-chan ProductEvent, func()) {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+
+	events := make(chan ProductEvent, buffer)
+	productSubscribers[events] = struct{}{}
+
+	return events, func() {
+		eventsLock.Lock()
+		defer eventsLock.Unlock()
+
+		if _, ok := productSubscribers[events]; ok {
+			delete(productSubscribers, events)
+			close(events)
+		}
+	}
+}
+
+// publishProductEvent expects storeLock to be held so subscribers get the changes in the order they were made
+func publishProductEvent(eventType ProductEventType, product Product) {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+
+	// The store may reuse the backing array of the category ids
+	product.CategoryIds = slices.Clone(product.CategoryIds)
+
+	lastProductEventId++
+	event := ProductEvent{Id: lastProductEventId, Type: eventType, Product: product, At: time.Now()}
+
+	if len(productEvents) < ProductEventHistory {
+		productEvents = append(productEvents, event)
+	} else if len(productEvents) > 0 {
+		productEvents[productEventsStart] = event
+		productEventsStart = (productEventsStart + 1) % len(productEvents)
+	}
+
+	for events := range productSubscribers {
+		select {
+		case events <- event:
+		default:
+			delete(productSubscribers, events)
+			close(events)
+		}
+	}
+}
+
+// ProductEventsSince returns the kept changes made after the change with id lastId, oldest first. complete is false
+// when changes after lastId are no longer kept or lastId is not a change of this server, the changes returned are
+// then every change kept. after is the id the changes returned follow, lastId unless complete is false.
+func ProductEventsSince(lastId uint64) (events []ProductEvent, after uint64, complete bool) {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+
+	oldestId := lastProductEventId + 1 - uint64(len(productEvents))
+	complete = lastId+1 >= oldestId && lastId <= lastProductEventId
+	if !complete {
+		lastId = oldestId - 1
+	}
+
+	for i := range productEvents {
+		event := productEvents[(productEventsStart+i)%len(productEvents)]
+		if event.Id > lastId {
+			events = append(events, event)
+		}
+	}
+	return events, lastId, complete
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with the idempotency key is still in progress")
+)
+
+// IdempotentResponse is the first response sent for an idempotency key, it is replayed on retries
+type IdempotentResponse struct {
+	Key         string
+	Owner       string
+	Fingerprint string
+	Status      int
+	ContentType string
+	Body        []byte
+	// Done is false while the first request is still being handled
+	Done      bool
+	ExpiresAt time.Time
+}
+
+var idempotencyLock sync.Mutex
+
+var IdempotencyData = map[string]IdempotentResponse{}
+
+func idempotencyId(owner string, key string) string {
+	return owner + "\x00" + key
+}
+
+// BeginIdempotentRequest returns the stored response when the key was already used for the same request.
+// Otherwise it reserves the key until CompleteIdempotentRequest or AbortIdempotentRequest is called.
+func BeginIdempotentRequest(owner string, key string, fingerprint string, ttl time.Duration) (IdempotentResponse, bool, error) {
+	idempotencyLock.Lock()
+	defer idempotencyLock.Unlock()
+
+	id := idempotencyId(owner, key)
+	now := time.Now()
+
+	if stored, ok := IdempotencyData[id]; ok && stored.ExpiresAt.After(now) {
+		if stored.Fingerprint != fingerprint {
+			return IdempotentResponse{}, false, ErrIdempotencyKeyReused
+		}
+		if !stored.Done {
+			return IdempotentResponse{}, false, ErrIdempotencyKeyInProgress
+		}
+		return stored, true, nil
+	}
+
+	IdempotencyData[id] = IdempotentResponse{
+		Key:         key,
+		Owner:       owner,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(ttl),
+	}
+	return IdempotentResponse{}, false, nil
+}
+
+func CompleteIdempotentRequest(owner string, key string, status int, contentType string, body []byte) {
+	idempotencyLock.Lock()
+	defer idempotencyLock.Unlock()
+
+	id := idempotencyId(owner, key)
+	stored, ok := IdempotencyData[id]
+	if !ok {
+		return
+	}
+
+	stored.Status = status
+	stored.ContentType = contentType
+	stored.Body = body
+	stored.Done = true
+	IdempotencyData[id] = stored
+}
+
+// AbortIdempotentRequest frees the key so the request can be retried
+func AbortIdempotentRequest(owner string, key string) {
+	idempotencyLock.Lock()
+	defer idempotencyLock.Unlock()
+
+	delete(IdempotencyData, idempotencyId(owner, key))
+}
+
+// ExpireIdempotencyKeys removes the stored responses older than their ttl and returns how many were removed
+func ExpireIdempotencyKeys(now time.Time) int {
+	idempotencyLock.Lock()
+	defer idempotencyLock.Unlock()
+
+	expired := 0
+	for id, stored := range IdempotencyData {
+		if !stored.ExpiresAt.After(now) {
+			delete(IdempotencyData, id)
+			expired++
+		}
+	}
+	return expired
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrInvalidImageOrder = errors.New("image order must list every image of the product exactly once")
+
+type Image struct {
+	Id           string    `json:"id"`
+	ProductId    string    `json:"productId"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	Url          string    `json:"url"`
+	ThumbnailUrl string    `json:"thumbnailUrl"`
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Position     int       `json:"position"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+var ImageData = map[string]Image{}
+
+func GetProductImages(productId string) []Image {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	return productImages(productId)
+}
+
+func FindImageById(productId string, id string) (Image, bool) {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	image, ok := ImageData[id]
+	if !ok || image.ProductId != productId {
+		return Image{}, false
+	}
+	return image, true
+}
+
+// AddImage stores a new image after the existing images of its product
+func AddImage(image Image) Image {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	image.Position = len(productImages(image.ProductId))
+	ImageData[image.Id] = image
+	return image
+}
+
+// DeleteImage removes an image and closes the gap it leaves in the ordering
+func DeleteImage(id string) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	image, ok := ImageData[id]
+	if !ok {
+		return
+	}
+	delete(ImageData, id)
+
+	for position, remaining := range productImages(image.ProductId) {
+		remaining.Position = position
+		ImageData[remaining.Id] = remaining
+	}
+}
+
+// ReorderImages sets the position of every image of a product to its index in imageIds
+func ReorderImages(productId string, imageIds []string) ([]Image, error) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	images := productImages(productId)
+	if len(images) != len(imageIds) {
+		return nil, ErrInvalidImageOrder
+	}
+
+	seen := map[string]bool{}
+	for _, id := range imageIds {
+		image, ok := ImageData[id]
+		if !ok || image.ProductId != productId || seen[id] {
+			return nil, ErrInvalidImageOrder
+		}
+		seen[id] = true
+	}
+
+	for position, id := range imageIds {
+		image := ImageData[id]
+		image.Position = position
+		ImageData[id] = image
+	}
+	return productImages(productId), nil
+}
+
+// productImages expects the caller to hold storeLock
+func productImages(productId string) []Image {
+	images := make([]Image, 0)
+	for _, image := range ImageData {
+		if image.ProductId == productId {
+			images = append(images, image)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Position < images[j].Position
+	})
+	return images
+}
@@ -0,0 +1,328 @@
+package models
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+var (
+	ErrProductNotFound     = errors.New("product not found")
+	ErrVariantNotFound     = errors.New("variant not found")
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+)
+
+type Reservation struct {
+	Id        string    `json:"id"`
+	ProductId string    `json:"productId"`
+	VariantId string    `json:"variantId"`
+	Quantity  int       `json:"quantity"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type StockLevel struct {
+	ProductId string `json:"productId"`
+	VariantId string `json:"variantId"`
+	Stock     int    `json:"stock"`
+	Reserved  int    `json:"reserved"`
+	Available int    `json:"available"`
+	LowStock  bool   `json:"lowStock"`
+}
+
+// StockLine is a quantity of a product, or of one of its variants when VariantId is set
+type StockLine struct {
+	ProductId string
+	VariantId string
+	Quantity  int
+}
+
+var ReservationData = map[string]Reservation{}
+
+func (reservation Reservation) Expired(now time.Time) bool {
+	return !now.Before(reservation.ExpiresAt)
+}
+
+// ProductStockLevels returns the stock level of a product followed by one for each of its variants
+func ProductStockLevels(productId string) (StockLevel, []StockLevel, error) {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	product, ok := ProductData[productId]
+	if !ok {
+		return StockLevel{}, nil, ErrProductNotFound
+	}
+
+	variantLevels := make([]StockLevel, 0)
+	for _, variant := range productVariants(productId) {
+		variantLevels = append(variantLevels, stockLevel(product, variant.Id))
+	}
+	return stockLevel(product, ""), variantLevels, nil
+}
+
+// AdjustStock atomically adds delta to the stock of a product, or of one of its variants
+// when variantId is set. Decrements never take the stock below what is reserved.
+func AdjustStock(productId string, variantId string, delta int) (StockLevel, error) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	product, ok := ProductData[productId]
+	if !ok {
+		return StockLevel{}, ErrProductNotFound
+	}
+
+	if variantId != "" {
+		variant, ok := VariantData[variantId]
+		if !ok || variant.ProductId != productId {
+			return StockLevel{}, ErrVariantNotFound
+		}
+		if delta < 0 && availableStock(product, variantId) < -delta {
+			return StockLevel{}, ErrInsufficientStock
+		}
+		variant.Stock += delta
+		variant.UpdatedAt = time.Now()
+		VariantData[variantId] = variant
+		return stockLevel(product, variantId), nil
+	}
+
+	if delta < 0 && availableStock(product, "") < -delta {
+		return StockLevel{}, ErrInsufficientStock
+	}
+	product.Stock += delta
+	product.UpdatedAt = time.Now()
+	ProductData[productId] = product
+	publishProductEvent(ProductUpdated, product)
+	return stockLevel(product, ""), nil
+}
+
+// ReserveStock holds quantity units of a product or variant until the reservation
+// is committed, released or expires after ttl
+func ReserveStock(productId string, variantId string, quantity int, ttl time.Duration) (Reservation, error) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	product, ok := ProductData[productId]
+	if !ok {
+		return Reservation{}, ErrProductNotFound
+	}
+
+	if variantId != "" {
+		if variant, ok := VariantData[variantId]; !ok || variant.ProductId != productId {
+			return Reservation{}, ErrVariantNotFound
+		}
+	}
+
+	if availableStock(product, variantId) < quantity {
+		return Reservation{}, ErrInsufficientStock
+	}
+
+	now := time.Now()
+	reservation := Reservation{
+		Id:        uuid.Must(uuid.NewRandom()).String(),
+		ProductId: productId,
+		VariantId: variantId,
+		Quantity:  quantity,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	ReservationData[reservation.Id] = reservation
+	return reservation, nil
+}
+
+func FindReservationById(id string) (Reservation, bool) {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	reservation, ok := ReservationData[id]
+	if !ok || reservation.Expired(time.Now()) {
+		return Reservation{}, false
+	}
+	return reservation, true
+}
+
+// ReleaseReservation gives the reserved units back without touching the stock
+func ReleaseReservation(id string) (Reservation, error) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	reservation, ok := ReservationData[id]
+	if !ok || reservation.Expired(time.Now()) {
+		return Reservation{}, ErrReservationNotFound
+	}
+	delete(ReservationData, id)
+	return reservation, nil
+}
+
+// CommitReservation turns a reservation into a permanent decrement of the stock
+func CommitReservation(id string) (Reservation, error) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	reservation, ok := ReservationData[id]
+	if !ok || reservation.Expired(time.Now()) {
+		return Reservation{}, ErrReservationNotFound
+	}
+	delete(ReservationData, id)
+
+	addStock(reservation.ProductId, reservation.VariantId, -reservation.Quantity)
+	return reservation, nil
+}
+
+// TakeStock decrements the stock of every line at once, nothing is taken when a product or variant does not have
+// enough available. Units held by reservations are not available.
+func TakeStock(lines []StockLine) error {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	// A product or variant can be on several lines
+	needed := map[StockLine]int{}
+	for _, line := range lines {
+		if _, ok := ProductData[line.ProductId]; !ok {
+			return ErrProductNotFound
+		}
+		if line.VariantId != "" {
+			if variant, ok := VariantData[line.VariantId]; !ok || variant.ProductId != line.ProductId {
+				return ErrVariantNotFound
+			}
+		}
+		needed[StockLine{ProductId: line.ProductId, VariantId: line.VariantId}] += line.Quantity
+	}
+
+	for line, quantity := range needed {
+		if availableStock(ProductData[line.ProductId], line.VariantId) < quantity {
+			return ErrInsufficientStock
+		}
+	}
+
+	for line, quantity := range needed {
+		addStock(line.ProductId, line.VariantId, -quantity)
+	}
+	return nil
+}
+
+// ReturnStock puts the lines taken by TakeStock back, products and variants deleted since are skipped
+func ReturnStock(lines []StockLine) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	for _, line := range lines {
+		addStock(line.ProductId, line.VariantId, line.Quantity)
+	}
+}
+
+// ExpireReservations drops every reservation that expired before now and returns how many were removed
+func ExpireReservations(now time.Time) int {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	expired := 0
+	for id, reservation := range ReservationData {
+		if reservation.Expired(now) {
+			delete(ReservationData, id)
+			expired++
+		}
+	}
+	return expired
+}
+
+// LowStockLevels returns every product or variant of a merchant at or below its low stock threshold
+func LowStockLevels(skuId string) []StockLevel {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	levels := make([]StockLevel, 0)
+	for _, product := range ProductData {
+		if product.SkuId != skuId {
+			continue
+		}
+
+		variants := productVariants(product.Id)
+		if len(variants) == 0 {
+			if level := stockLevel(product, ""); level.LowStock {
+				levels = append(levels, level)
+			}
+			continue
+		}
+
+		for _, variant := range variants {
+			if level := stockLevel(product, variant.Id); level.LowStock {
+				levels = append(levels, level)
+			}
+		}
+	}
+
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Available < levels[j].Available
+	})
+	return levels
+}
+
+// The helpers below expect the caller to hold storeLock
+
+// addStock changes the stock of a product or variant without checking what is available
+func addStock(productId string, variantId string, delta int) {
+	if variantId != "" {
+		if variant, ok := VariantData[variantId]; ok {
+			variant.Stock += delta
+			variant.UpdatedAt = time.Now()
+			VariantData[variant.Id] = variant
+		}
+		return
+	}
+
+	if product, ok := ProductData[productId]; ok {
+		product.Stock += delta
+		product.UpdatedAt = time.Now()
+		ProductData[product.Id] = product
+		publishProductEvent(ProductUpdated, product)
+	}
+}
+
+func stockLevel(product Product, variantId string) StockLevel {
+	stock := product.Stock
+	if variantId != "" {
+		stock = VariantData[variantId].Stock
+	}
+
+	reserved := reservedStock(product.Id, variantId)
+	available := stock - reserved
+
+	return StockLevel{
+		ProductId: product.Id,
+		VariantId: variantId,
+		Stock:     stock,
+		Reserved:  reserved,
+		Available: available,
+		LowStock:  available <= product.LowStockThreshold,
+	}
+}
+
+func availableStock(product Product, variantId string) int {
+	return stockLevel(product, variantId).Available
+}
+
+func reservedStock(productId string, variantId string) int {
+	now := time.Now()
+	reserved := 0
+	for _, reservation := range ReservationData {
+		if reservation.ProductId == productId && reservation.VariantId == variantId && !reservation.Expired(now) {
+			reserved += reservation.Quantity
+		}
+	}
+	return reserved
+}
+
+// isInStock reports whether any unit of the product or of one of its variants is available
+func isInStock(product Product) bool {
+	if availableStock(product, "") > 0 {
+		return true
+	}
+	for _, variant := range productVariants(product.Id) {
+		if availableStock(product, variant.Id) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"github.com/gofiber/fiber/v2/log"
+	"time"
+)
+
+// StartJanitor removes expired stock reservations, inactive carts and idempotency keys every interval until stop is closed
+func StartJanitor(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if expired := ExpireReservations(now); expired > 0 {
+					log.Info("Expired stock reservations: ", expired)
+				}
+				if expired := ExpireCarts(now); expired > 0 {
+					log.Info("Expired carts: ", expired)
+				}
+				if expired := ExpireIdempotencyKeys(now); expired > 0 {
+					log.Info("Expired idempotency keys: ", expired)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,394 @@
+package models
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	OrderPending   OrderStatus = "pending"
+	OrderPaid      OrderStatus = "paid"
+	OrderFulfilled OrderStatus = "fulfilled"
+	OrderDelivered OrderStatus = "delivered"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderRefunded  OrderStatus = "refunded"
+)
+
+// orderTransitions lists the statuses each status may move to, cancelled and refunded are final
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderFulfilled, OrderRefunded},
+	OrderFulfilled: {OrderDelivered, OrderRefunded},
+	OrderDelivered: {OrderRefunded},
+}
+
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+)
+
+// OrderItem is a snapshot of the product as it was when the order was placed
+type OrderItem struct {
+	Id          string            `json:"id"`
+	ProductId   string            `json:"productId"`
+	VariantId   string            `json:"variantId"`
+	SkuId       string            `json:"skuId"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Options     map[string]string `json:"options"`
+	UnitPrice   float32           `json:"unitPrice"`
+	Quantity    int               `json:"quantity"`
+	Discount    float32           `json:"discount"`
+	PromotionId string            `json:"promotionId"`
+	LineTotal   float32           `json:"lineTotal"`
+	Tax         TaxBreakdown      `json:"tax"`
+	// Status is moved by the merchant of the item, the items of other merchants are left alone
+	Status OrderStatus `json:"status"`
+}
+
+type OrderTransition struct {
+	// SkuId is the merchant whose items moved, it is empty when the whole order moved
+	SkuId string      `json:"skuId,omitempty"`
+	From  OrderStatus `json:"from"`
+	To    OrderStatus `json:"to"`
+	Note  string      `json:"note"`
+	At    time.Time   `json:"at"`
+}
+
+type Order struct {
+	Id     string `json:"id"`
+	CartId string `json:"cartId"`
+	// Status follows the least advanced item that is not cancelled or refunded
+	Status OrderStatus `json:"status"`
+	Items  []OrderItem `json:"items"`
+	// Tax is the tax on every item that is not cancelled, Total only includes the part added on top of tax exclusive prices
+	Tax     float32           `json:"tax"`
+	Total   float32           `json:"total"`
+	History []OrderTransition `json:"history"`
+	// MerchantIds are the skuIds of every merchant with a product in the order
+	MerchantIds []string `json:"merchantIds"`
+	// PaymentProvider and PaymentReference are set once checkout has started, once the order is paid
+	// PaymentReference is the transaction that paid it
+	PaymentProvider  string `json:"paymentProvider"`
+	PaymentReference string `json:"paymentReference"`
+	// PaymentReferences are every transaction started for the order, a customer may pay any of them
+	PaymentReferences []string  `json:"paymentReferences"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+var orderLock sync.RWMutex
+
+var OrderData = map[string]Order{}
+
+func CanTransitionOrder(from OrderStatus, to OrderStatus) bool {
+	for _, allowed := range orderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func IsOrderStatus(status OrderStatus) bool {
+	switch status {
+	case OrderPending, OrderPaid, OrderFulfilled, OrderDelivered, OrderCancelled, OrderRefunded:
+		return true
+	}
+	return false
+}
+
+func (order Order) HasMerchant(skuId string) bool {
+	for _, id := range order.MerchantIds {
+		if id == skuId {
+			return true
+		}
+	}
+	return false
+}
+
+// MerchantItems returns the items of the order sold by the merchant
+func (order Order) MerchantItems(skuId string) []OrderItem {
+	items := make([]OrderItem, 0)
+	for _, item := range order.Items {
+		if item.SkuId == skuId {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// MerchantTotal is what the customer pays for the items of the merchant
+func (order Order) MerchantTotal(skuId string) float32 {
+	var total float32
+	for _, item := range order.Items {
+		if item.SkuId == skuId {
+			total += item.total()
+		}
+	}
+	return total
+}
+
+func (item OrderItem) total() float32 {
+	if item.Tax.Inclusive {
+		return item.LineTotal
+	}
+	return item.LineTotal + item.Tax.Tax
+}
+
+// orderStatusRank orders the statuses an item goes through before it is delivered
+var orderStatusRank = map[OrderStatus]int{
+	OrderPending:   0,
+	OrderPaid:      1,
+	OrderFulfilled: 2,
+	OrderDelivered: 3,
+}
+
+// summarize sets the status and totals of the order from its items,
+// once every item is closed the order is refunded when any item was and cancelled otherwise
+func (order *Order) summarize() {
+	order.Status, order.Tax, order.Total = "", 0, 0
+
+	closed := OrderCancelled
+	for _, item := range order.Items {
+		if item.Status != OrderCancelled {
+			order.Total += item.total()
+			order.Tax += item.Tax.Tax
+		}
+
+		switch item.Status {
+		case OrderRefunded:
+			closed = OrderRefunded
+		case OrderCancelled:
+		default:
+			if order.Status == "" || orderStatusRank[item.Status] < orderStatusRank[order.Status] {
+				order.Status = item.Status
+			}
+		}
+	}
+
+	if order.Status == "" {
+		order.Status = closed
+	}
+}
+
+// CreateOrder stores a new pending order for the items of a cart
+func CreateOrder(cartId string, items []OrderItem) Order {
+	orderLock.Lock()
+	defer orderLock.Unlock()
+
+	now := time.Now()
+	order := Order{
+		Id:                uuid.Must(uuid.NewRandom()).String(),
+		CartId:            cartId,
+		Items:             make([]OrderItem, 0, len(items)),
+		History:           []OrderTransition{},
+		MerchantIds:       []string{},
+		PaymentReferences: []string{},
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	merchants := map[string]bool{}
+	for _, item := range items {
+		item.Status = OrderPending
+		order.Items = append(order.Items, item)
+		if !merchants[item.SkuId] {
+			merchants[item.SkuId] = true
+			order.MerchantIds = append(order.MerchantIds, item.SkuId)
+		}
+	}
+	order.summarize()
+
+	OrderData[order.Id] = order
+	return order
+}
+
+func FindOrderById(id string) (Order, bool) {
+	orderLock.RLock()
+	defer orderLock.RUnlock()
+
+	order, ok := OrderData[id]
+	return order, ok
+}
+
+func FindOrderByPaymentReference(reference string) (Order, bool) {
+	orderLock.RLock()
+	defer orderLock.RUnlock()
+
+	for _, order := range OrderData {
+		if reference != "" && slices.Contains(order.PaymentReferences, reference) {
+			return order, true
+		}
+	}
+	return Order{}, false
+}
+
+// SetOrderPayment records the transaction a pending order is being paid with,
+// starting checkout again adds a transaction and the previous ones can still be paid
+func SetOrderPayment(id string, provider string, reference string) (Order, error) {
+	orderLock.Lock()
+	defer orderLock.Unlock()
+
+	order, ok := OrderData[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+
+	if order.Status != OrderPending {
+		return Order{}, ErrInvalidTransition
+	}
+
+	order.PaymentProvider = provider
+	order.PaymentReference = reference
+	order.PaymentReferences = append(append([]string{}, order.PaymentReferences...), reference)
+	order.UpdatedAt = time.Now()
+	OrderData[id] = order
+	return order, nil
+}
+
+// MerchantOrders returns the orders containing a product of the merchant, newest first
+func MerchantOrders(skuId string, status OrderStatus) []Order {
+	orderLock.RLock()
+	defer orderLock.RUnlock()
+
+	orders := make([]Order, 0)
+	for _, order := range OrderData {
+		if order.HasMerchant(skuId) && (status == "" || order.Status == status) {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].CreatedAt.After(orders[j].CreatedAt)
+	})
+	return orders
+}
+
+// TransitionOrder moves every item at the status of the order to a new status, it is how a payment moves the order
+func TransitionOrder(id string, to OrderStatus, note string) (Order, error) {
+	orderLock.Lock()
+	defer orderLock.Unlock()
+
+	order, ok := OrderData[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+
+	from := order.Status
+	return transitionOrderItems(order, "", from, to, note, func(item OrderItem) bool {
+		return item.Status == from
+	})
+}
+
+// PayOrder marks a pending order as paid with one of the transactions started for it,
+// refunds then go to that transaction
+func PayOrder(id string, reference string, note string) (Order, error) {
+	orderLock.Lock()
+	defer orderLock.Unlock()
+
+	order, ok := OrderData[id]
+	if !ok || !slices.Contains(order.PaymentReferences, reference) {
+		return Order{}, ErrOrderNotFound
+	}
+
+	if order.Status != OrderPending {
+		return Order{}, ErrInvalidTransition
+	}
+
+	order.PaymentReference = reference
+	return transitionOrderItems(order, "", OrderPending, OrderPaid, note, func(item OrderItem) bool {
+		return item.Status == OrderPending
+	})
+}
+
+// TransitionMerchantItems moves the items of one merchant to a new status and returns the status they had.
+// Checking and changing the status happen under one lock, so only one of two identical requests succeeds
+func TransitionMerchantItems(id string, skuId string, to OrderStatus, note string) (Order, OrderStatus, error) {
+	orderLock.Lock()
+	defer orderLock.Unlock()
+
+	order, ok := OrderData[id]
+	if !ok {
+		return Order{}, "", ErrOrderNotFound
+	}
+
+	// The items of a merchant always move together so they share a status
+	var from OrderStatus
+	for _, item := range order.Items {
+		if item.SkuId == skuId {
+			from = item.Status
+			break
+		}
+	}
+
+	order, err := transitionOrderItems(order, skuId, from, to, note, func(item OrderItem) bool {
+		return item.SkuId == skuId
+	})
+	return order, from, err
+}
+
+// RevertMerchantItems puts the items of a merchant back to the status they had before TransitionMerchantItems,
+// it is used when what the transition stands for, like a refund, could not be done
+func RevertMerchantItems(id string, skuId string, from OrderStatus, to OrderStatus) (Order, error) {
+	orderLock.Lock()
+	defer orderLock.Unlock()
+
+	order, ok := OrderData[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+
+	order.Items = append([]OrderItem{}, order.Items...)
+	for i, item := range order.Items {
+		if item.SkuId == skuId && item.Status == to {
+			order.Items[i].Status = from
+		}
+	}
+
+	order.History = append([]OrderTransition{}, order.History...)
+	for i := len(order.History) - 1; i >= 0; i-- {
+		if transition := order.History[i]; transition.SkuId == skuId && transition.From == from && transition.To == to {
+			order.History = append(order.History[:i], order.History[i+1:]...)
+			break
+		}
+	}
+
+	order.summarize()
+	order.UpdatedAt = time.Now()
+	OrderData[id] = order
+	return order, nil
+}
+
+// transitionOrderItems moves the items matched by selected from one status to another when the state machine
+// allows it, the caller holds orderLock
+func transitionOrderItems(order Order, skuId string, from OrderStatus, to OrderStatus, note string, selected func(OrderItem) bool) (Order, error) {
+	if !CanTransitionOrder(from, to) {
+		return Order{}, ErrInvalidTransition
+	}
+
+	order.Items = append([]OrderItem{}, order.Items...)
+	for i, item := range order.Items {
+		if selected(item) {
+			order.Items[i].Status = to
+		}
+	}
+
+	now := time.Now()
+	order.History = append(append([]OrderTransition{}, order.History...), OrderTransition{
+		SkuId: skuId,
+		From:  from,
+		To:    to,
+		Note:  note,
+		At:    now,
+	})
+	order.summarize()
+	order.UpdatedAt = now
+	OrderData[order.Id] = order
+	return order, nil
+}
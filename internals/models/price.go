@@ -0,0 +1,295 @@
+package models
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+type ScheduleStatus string
+
+const (
+	ScheduleScheduled ScheduleStatus = "scheduled"
+	ScheduleActive    ScheduleStatus = "active"
+	ScheduleCompleted ScheduleStatus = "completed"
+	ScheduleCancelled ScheduleStatus = "cancelled"
+)
+
+const (
+	PriceSourceManual        = "manual"
+	PriceSourceScheduleStart = "scheduleStart"
+	PriceSourceScheduleEnd   = "scheduleEnd"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("price schedule not found")
+	ErrScheduleOverlap  = errors.New("price schedule overlaps another schedule")
+	ErrScheduleFinished = errors.New("price schedule already finished")
+)
+
+// PriceChange is an entry of the price history of a product or one of its variants
+type PriceChange struct {
+	Id            string    `json:"id"`
+	ProductId     string    `json:"productId"`
+	VariantId     string    `json:"variantId"`
+	PreviousPrice float32   `json:"previousPrice"`
+	Price         float32   `json:"price"`
+	Source        string    `json:"source"`
+	ScheduleId    string    `json:"scheduleId"`
+	At            time.Time `json:"at"`
+}
+
+// PriceSchedule replaces the price of a product or variant between StartsAt and EndsAt,
+// without EndsAt the price stays until the schedule is cancelled
+type PriceSchedule struct {
+	Id        string         `json:"id"`
+	ProductId string         `json:"productId"`
+	VariantId string         `json:"variantId"`
+	Price     float32        `json:"price"`
+	StartsAt  time.Time      `json:"startsAt"`
+	EndsAt    *time.Time     `json:"endsAt"`
+	Status    ScheduleStatus `json:"status"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// priceLock guards the price history and schedules, it is taken after storeLock when both are needed
+var priceLock sync.RWMutex
+
+var PriceHistoryData = map[string][]PriceChange{}
+
+var PriceScheduleData = map[string]PriceSchedule{}
+
+// ActiveAt reports whether the schedule sets the price at the given time
+func (schedule PriceSchedule) ActiveAt(now time.Time) bool {
+	if schedule.Status == ScheduleCancelled || now.Before(schedule.StartsAt) {
+		return false
+	}
+	return schedule.EndsAt == nil || now.Before(*schedule.EndsAt)
+}
+
+func (schedule PriceSchedule) overlaps(other PriceSchedule) bool {
+	if schedule.ProductId != other.ProductId || schedule.VariantId != other.VariantId {
+		return false
+	}
+	startsBeforeOtherEnds := other.EndsAt == nil || schedule.StartsAt.Before(*other.EndsAt)
+	endsAfterOtherStarts := schedule.EndsAt == nil || schedule.EndsAt.After(other.StartsAt)
+	return startsBeforeOtherEnds && endsAfterOtherStarts
+}
+
+// EffectivePrice returns the price a product or variant sells for at the given time
+func EffectivePrice(productId string, variantId string, basePrice float32, now time.Time) float32 {
+	priceLock.RLock()
+	defer priceLock.RUnlock()
+
+	return effectivePrice(productId, variantId, basePrice, now)
+}
+
+// effectivePrice expects priceLock to be held
+func effectivePrice(productId string, variantId string, basePrice float32, now time.Time) float32 {
+	for _, schedule := range PriceScheduleData {
+		if schedule.ProductId == productId && schedule.VariantId == variantId && schedule.ActiveAt(now) {
+			return schedule.Price
+		}
+	}
+	return basePrice
+}
+
+// RecordPriceChange adds an entry to the price history, nothing is recorded when the price is unchanged
+func RecordPriceChange(change PriceChange) {
+	if change.PreviousPrice == change.Price {
+		return
+	}
+
+	priceLock.Lock()
+	defer priceLock.Unlock()
+
+	recordPriceChange(change)
+}
+
+func recordPriceChange(change PriceChange) {
+	change.Id = uuid.Must(uuid.NewRandom()).String()
+	if change.At.IsZero() {
+		change.At = time.Now()
+	}
+	PriceHistoryData[change.ProductId] = append(PriceHistoryData[change.ProductId], change)
+}
+
+// GetPriceHistory returns the price changes of a product and its variants, newest first
+func GetPriceHistory(productId string) []PriceChange {
+	priceLock.RLock()
+	defer priceLock.RUnlock()
+
+	history := PriceHistoryData[productId]
+	changes := make([]PriceChange, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		changes = append(changes, history[i])
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].At.After(changes[j].At)
+	})
+	return changes
+}
+
+// GetPriceSchedules returns the schedules of a product in start order
+func GetPriceSchedules(productId string) []PriceSchedule {
+	priceLock.RLock()
+	defer priceLock.RUnlock()
+
+	schedules := make([]PriceSchedule, 0)
+	for _, schedule := range PriceScheduleData {
+		if schedule.ProductId == productId {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].StartsAt.Before(schedules[j].StartsAt)
+	})
+	return schedules
+}
+
+func FindPriceScheduleById(productId string, id string) (PriceSchedule, bool) {
+	priceLock.RLock()
+	defer priceLock.RUnlock()
+
+	schedule, ok := PriceScheduleData[id]
+	if !ok || schedule.ProductId != productId {
+		return PriceSchedule{}, false
+	}
+	return schedule, true
+}
+
+// SchedulePrice stores a schedule unless it overlaps another schedule of the same product or variant
+func SchedulePrice(schedule PriceSchedule) (PriceSchedule, error) {
+	priceLock.Lock()
+	defer priceLock.Unlock()
+
+	for _, other := range PriceScheduleData {
+		if other.Status != ScheduleCancelled && other.Status != ScheduleCompleted && schedule.overlaps(other) {
+			return PriceSchedule{}, ErrScheduleOverlap
+		}
+	}
+
+	schedule.Id = uuid.Must(uuid.NewRandom()).String()
+	schedule.Status = ScheduleScheduled
+	schedule.CreatedAt = time.Now()
+	PriceScheduleData[schedule.Id] = schedule
+	return schedule, nil
+}
+
+// CancelPriceSchedule stops a schedule, an active schedule gives the base price back straight away
+func CancelPriceSchedule(id string, basePrice float32) (PriceSchedule, error) {
+	priceLock.Lock()
+	defer priceLock.Unlock()
+
+	schedule, ok := PriceScheduleData[id]
+	if !ok {
+		return PriceSchedule{}, ErrScheduleNotFound
+	}
+
+	if schedule.Status == ScheduleCompleted || schedule.Status == ScheduleCancelled {
+		return PriceSchedule{}, ErrScheduleFinished
+	}
+
+	if schedule.Status == ScheduleActive {
+		recordPriceChange(PriceChange{
+			ProductId:     schedule.ProductId,
+			VariantId:     schedule.VariantId,
+			PreviousPrice: schedule.Price,
+			Price:         basePrice,
+			Source:        PriceSourceScheduleEnd,
+			ScheduleId:    schedule.Id,
+		})
+	}
+
+	schedule.Status = ScheduleCancelled
+	PriceScheduleData[id] = schedule
+	return schedule, nil
+}
+
+// ApplyPriceSchedules starts and ends the schedules due at the given time and records
+// the price changes in the history. It returns how many schedules started and ended.
+func ApplyPriceSchedules(now time.Time) (int, int) {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	priceLock.Lock()
+	defer priceLock.Unlock()
+
+	started, ended := 0, 0
+	for id, schedule := range PriceScheduleData {
+		if schedule.Status != ScheduleScheduled && schedule.Status != ScheduleActive {
+			continue
+		}
+
+		basePrice, ok := basePrice(schedule.ProductId, schedule.VariantId)
+		if !ok {
+			// The product or variant was deleted
+			schedule.Status = ScheduleCancelled
+			PriceScheduleData[id] = schedule
+			continue
+		}
+
+		if schedule.Status == ScheduleScheduled && !now.Before(schedule.StartsAt) {
+			recordPriceChange(PriceChange{
+				ProductId:     schedule.ProductId,
+				VariantId:     schedule.VariantId,
+				PreviousPrice: basePrice,
+				Price:         schedule.Price,
+				Source:        PriceSourceScheduleStart,
+				ScheduleId:    schedule.Id,
+				At:            schedule.StartsAt,
+			})
+			schedule.Status = ScheduleActive
+			started++
+		}
+
+		if schedule.Status == ScheduleActive && schedule.EndsAt != nil && !now.Before(*schedule.EndsAt) {
+			recordPriceChange(PriceChange{
+				ProductId:     schedule.ProductId,
+				VariantId:     schedule.VariantId,
+				PreviousPrice: schedule.Price,
+				Price:         basePrice,
+				Source:        PriceSourceScheduleEnd,
+				ScheduleId:    schedule.Id,
+				At:            *schedule.EndsAt,
+			})
+			schedule.Status = ScheduleCompleted
+			ended++
+		}
+
+		PriceScheduleData[id] = schedule
+	}
+	return started, ended
+}
+
+// basePrice expects storeLock to be held
+func basePrice(productId string, variantId string) (float32, bool) {
+	if variantId == "" {
+		product, ok := ProductData[productId]
+		return product.Price, ok
+	}
+	variant, ok := VariantData[variantId]
+	return variant.Price, ok && variant.ProductId == productId
+}
+
+// StartPriceScheduler applies the due price schedules every interval until stop is closed.
+// Prices are computed when they are read so the interval only delays the history and statuses.
+func StartPriceScheduler(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if started, ended := ApplyPriceSchedules(now); started+ended > 0 {
+					log.Info("Price schedules started: ", started, " ended: ", ended)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
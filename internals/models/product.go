@@ -8,15 +8,23 @@ import (
 )
 
 type Product struct {
-	SkuId       string    `json:"skuId"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float32   `json:"price"`
-	Id          string    `json:"id"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	SkuId             string    `json:"skuId"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	Price             float32   `json:"price"`
+	TaxClass          TaxClass  `json:"taxClass"`
+	Id                string    `json:"id"`
+	CategoryIds       []string  `json:"categoryIds"`
+	Stock             int       `json:"stock"`
+	LowStockThreshold int       `json:"lowStockThreshold"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
 }
 
+// storeLock guards ProductData, VariantData, CategoryData, ReservationData and ImageData
+// against concurrent requests
+var storeLock sync.RWMutex
+
 var ProductData = map[string]Product{
 	"1": {
 		Id:          "1",
@@ -39,6 +47,9 @@ var ProductData = map[string]Product{
 }
 
 func GetAllProducts() []Product {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
 	products := make([]Product, 0, len(ProductData))
 	for _, product := range ProductData {
 		products = append(products, product)
@@ -46,6 +57,92 @@ func GetAllProducts() []Product {
 	return products
 }
 
+type ProductFilter struct {
+	Search    string
+	SortKey   string
+	SortOrder string
+	// Categories is nil when the listing is not filtered by category
+	Categories map[string]bool
+	// InStock is nil when the listing is not filtered by stock
+	InStock *bool
+	// SkuId is empty when the listing is not filtered by merchant
+	SkuId string
+}
+
+// Matches expects the caller to hold storeLock
+func (filter ProductFilter) Matches(product Product) bool {
+	if filter.SkuId != "" && product.SkuId != filter.SkuId {
+		return false
+	}
+
+	if filter.Search != "" && !strings.Contains(strings.ToLower(product.Name), strings.ToLower(filter.Search)) {
+		return false
+	}
+
+	if filter.Categories != nil {
+		inCategory := false
+		for _, id := range product.CategoryIds {
+			if filter.Categories[id] {
+				inCategory = true
+				break
+			}
+		}
+		if !inCategory {
+			return false
+		}
+	}
+
+	if filter.InStock != nil && isInStock(product) != *filter.InStock {
+		return false
+	}
+	return true
+}
+
+func FilterProducts(products []Product, filter ProductFilter) []Product {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	filtered := make([]Product, 0)
+	for _, product := range products {
+		if filter.Matches(product) {
+			filtered = append(filtered, product)
+		}
+	}
+	return filtered
+}
+
+// EachProduct calls fn for every product matching the filter in sorted order.
+// Only the matching ids are held in memory, each product is looked up as it is visited.
+func EachProduct(filter ProductFilter, fn func(Product) error) error {
+	storeLock.RLock()
+	ids := make([]string, 0)
+	for id, product := range ProductData {
+		if filter.Matches(product) {
+			ids = append(ids, id)
+		}
+	}
+
+	less := productLess(filter.SortKey, filter.SortOrder)
+	if less != nil {
+		sort.Slice(ids, func(i, j int) bool {
+			return less(ProductData[ids[i]], ProductData[ids[j]])
+		})
+	}
+	storeLock.RUnlock()
+
+	for _, id := range ids {
+		product, ok := FindProductById(id)
+		if !ok {
+			// Deleted while the export was running
+			continue
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func FilterProductsByName(products []Product, name string) []Product {
 	filtered := make([]Product, 0)
 	for _, product := range products {
@@ -57,36 +154,47 @@ func FilterProductsByName(products []Product, name string) []Product {
 }
 
 func SortProducts(products []Product, sortBy string, sortOrder string) {
+	less := productLess(sortBy, sortOrder)
+	if less == nil {
+		return
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return less(products[i], products[j])
+	})
+}
+
+func productLess(sortBy string, sortOrder string) func(a, b Product) bool {
 	switch sortBy {
 	case "name":
-		sort.Slice(products, func(i, j int) bool {
+		return func(a, b Product) bool {
 			if sortOrder == "asc" {
-				return products[i].Name < products[j].Name
+				return a.Name < b.Name
 			}
-			return products[i].Name > products[j].Name
-		})
+			return a.Name > b.Name
+		}
 	case "price":
-		sort.Slice(products, func(i, j int) bool {
+		return func(a, b Product) bool {
 			if sortOrder == "asc" {
-				return products[i].Price < products[j].Price
+				return a.Price < b.Price
 			}
-			return products[i].Price > products[j].Price
-		})
+			return a.Price > b.Price
+		}
 	case "createdAt":
-		sort.Slice(products, func(i, j int) bool {
+		return func(a, b Product) bool {
 			if sortOrder == "asc" {
-				return products[i].CreatedAt.Before(products[j].CreatedAt)
+				return a.CreatedAt.Before(b.CreatedAt)
 			}
-			return products[i].CreatedAt.After(products[j].CreatedAt)
-		})
+			return a.CreatedAt.After(b.CreatedAt)
+		}
 	}
+	return nil
 }
 
 func ChunkProductsToChannel(products []Product, channel chan Product, numberOfGoroutines int, wg *sync.WaitGroup) {
 	chunkSize := (len(products) + numberOfGoroutines - 1) / numberOfGoroutines
 	for i := 0; i < len(products); i += chunkSize {
 		wg.Add(1)
-		go addProductToChannel(products, channel, i, i+chunkSize, wg)
+		go addProductToChannel(products, channel, i, min(i+chunkSize, len(products)), wg)
 	}
 }
 
@@ -98,6 +206,65 @@ func addProductToChannel(products []Product, channel chan Product, start int, en
 }
 
 func FindProductById(id string) (Product, bool) {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
 	product, ok := ProductData[id]
 	return product, ok
 }
+
+func SaveProduct(product Product) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	eventType := ProductCreated
+	if _, ok := ProductData[product.Id]; ok {
+		eventType = ProductUpdated
+	}
+
+	ProductData[product.Id] = product
+	publishProductEvent(eventType, product)
+}
+
+// UpdateProduct applies update to the stored product while holding the store lock,
+// so concurrent stock changes are never overwritten by a stale copy
+func UpdateProduct(id string, update func(product *Product)) (Product, bool) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	product, ok := ProductData[id]
+	if !ok {
+		return Product{}, false
+	}
+	update(&product)
+	product.UpdatedAt = time.Now()
+	ProductData[id] = product
+	publishProductEvent(ProductUpdated, product)
+	return product, true
+}
+
+// DeleteProduct removes a product together with its variants, stock reservations and image records
+func DeleteProduct(id string) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	if product, ok := ProductData[id]; ok {
+		delete(ProductData, id)
+		publishProductEvent(ProductDeleted, product)
+	}
+	for variantId, variant := range VariantData {
+		if variant.ProductId == id {
+			delete(VariantData, variantId)
+		}
+	}
+	for reservationId, reservation := range ReservationData {
+		if reservation.ProductId == id {
+			delete(ReservationData, reservationId)
+		}
+	}
+	for imageId, image := range ImageData {
+		if image.ProductId == id {
+			delete(ImageData, imageId)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package models
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DiscountType string
+
+const (
+	DiscountPercentage DiscountType = "percentage"
+	DiscountFixed      DiscountType = "fixed"
+)
+
+var (
+	ErrPromotionNotFound = errors.New("promotion not found")
+	ErrCouponExists      = errors.New("coupon code already used by another promotion")
+	ErrCouponNotFound    = errors.New("coupon not found")
+	ErrCouponUnavailable = errors.New("coupon expired or used up")
+)
+
+// Promotion discounts the products of a merchant. Without ProductIds and CategoryIds it applies
+// to every product of the merchant. Promotions with a Code are coupons that only apply once the
+// code has been added to a cart, the others apply everywhere including product listings.
+type Promotion struct {
+	Id          string       `json:"id"`
+	SkuId       string       `json:"skuId"`
+	Name        string       `json:"name"`
+	Type        DiscountType `json:"type"`
+	Value       float32      `json:"value"`
+	Code        string       `json:"code"`
+	ProductIds  []string     `json:"productIds"`
+	CategoryIds []string     `json:"categoryIds"`
+	// UsageLimit is how many orders can use the coupon, 0 means no limit
+	UsageLimit int        `json:"usageLimit"`
+	UsageCount int        `json:"usageCount"`
+	StartsAt   *time.Time `json:"startsAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// AppliedPromotion is the discount a promotion gives on one unit
+type AppliedPromotion struct {
+	PromotionId string  `json:"promotionId"`
+	Name        string  `json:"name"`
+	Code        string  `json:"code"`
+	Discount    float32 `json:"discount"`
+}
+
+var promotionLock sync.RWMutex
+
+var PromotionData = map[string]Promotion{}
+
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// AvailableAt reports whether the promotion has started, has not expired and is not used up
+func (promotion Promotion) AvailableAt(now time.Time) bool {
+	switch {
+	case promotion.StartsAt != nil && now.Before(*promotion.StartsAt),
+		promotion.ExpiresAt != nil && !now.Before(*promotion.ExpiresAt),
+		promotion.UsageLimit > 0 && promotion.UsageCount >= promotion.UsageLimit:
+		return false
+	}
+	return true
+}
+
+// DiscountOn returns the discount the promotion gives on a unit price, it never exceeds the price
+func (promotion Promotion) DiscountOn(price float32) float32 {
+	discount := promotion.Value
+	if promotion.Type == DiscountPercentage {
+		discount = price * promotion.Value / 100
+	}
+	return min(discount, price)
+}
+
+// appliesTo expects the categories to already include their descendants
+func (promotion Promotion) appliesTo(product Product, categories map[string]bool) bool {
+	if product.SkuId != promotion.SkuId {
+		return false
+	}
+
+	if len(promotion.ProductIds) == 0 && len(promotion.CategoryIds) == 0 {
+		return true
+	}
+
+	for _, id := range promotion.ProductIds {
+		if id == product.Id {
+			return true
+		}
+	}
+
+	for _, id := range product.CategoryIds {
+		if categories[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func CreatePromotion(promotion Promotion) (Promotion, error) {
+	promotionLock.Lock()
+	defer promotionLock.Unlock()
+
+	// Codes are unique within a merchant, two merchants can both run a SALE10
+	promotion.Code = NormalizeCouponCode(promotion.Code)
+	if promotion.Code != "" {
+		for _, other := range PromotionData {
+			if other.SkuId == promotion.SkuId && other.Code == promotion.Code {
+				return Promotion{}, ErrCouponExists
+			}
+		}
+	}
+
+	promotion.Id = uuid.Must(uuid.NewRandom()).String()
+	promotion.CreatedAt = time.Now()
+	PromotionData[promotion.Id] = promotion
+	return promotion, nil
+}
+
+func FindPromotionById(id string) (Promotion, bool) {
+	promotionLock.RLock()
+	defer promotionLock.RUnlock()
+
+	promotion, ok := PromotionData[id]
+	return promotion, ok
+}
+
+// FindCoupon returns the promotion of the merchant skuId with the code when it can still be used
+func FindCoupon(skuId string, code string, now time.Time) (Promotion, error) {
+	promotionLock.RLock()
+	defer promotionLock.RUnlock()
+
+	code = NormalizeCouponCode(code)
+	for _, promotion := range PromotionData {
+		if code != "" && promotion.SkuId == skuId && promotion.Code == code {
+			if !promotion.AvailableAt(now) {
+				return Promotion{}, ErrCouponUnavailable
+			}
+			return promotion, nil
+		}
+	}
+	return Promotion{}, ErrCouponNotFound
+}
+
+// MerchantPromotions returns the promotions of a merchant, newest first
+func MerchantPromotions(skuId string) []Promotion {
+	promotionLock.RLock()
+	defer promotionLock.RUnlock()
+
+	promotions := make([]Promotion, 0)
+	for _, promotion := range PromotionData {
+		if promotion.SkuId == skuId {
+			promotions = append(promotions, promotion)
+		}
+	}
+	sort.Slice(promotions, func(i, j int) bool {
+		return promotions[i].CreatedAt.After(promotions[j].CreatedAt)
+	})
+	return promotions
+}
+
+func DeletePromotion(id string) bool {
+	promotionLock.Lock()
+	defer promotionLock.Unlock()
+
+	_, ok := PromotionData[id]
+	delete(PromotionData, id)
+	return ok
+}
+
+// RedeemCoupons counts one use of every coupon, none is counted when one of them is used up
+func RedeemCoupons(ids []string, now time.Time) error {
+	promotionLock.Lock()
+	defer promotionLock.Unlock()
+
+	for _, id := range ids {
+		promotion, ok := PromotionData[id]
+		if !ok || !promotion.AvailableAt(now) {
+			return ErrCouponUnavailable
+		}
+	}
+
+	for _, id := range ids {
+		promotion := PromotionData[id]
+		promotion.UsageCount++
+		PromotionData[id] = promotion
+	}
+	return nil
+}
+
+// BestPromotion returns the largest discount on one unit of the product at the given price.
+// Automatic promotions always apply, coupons only when their code is in codes. Promotions do not stack.
+func BestPromotion(product Product, price float32, codes []string, now time.Time) (AppliedPromotion, bool) {
+	promotionLock.RLock()
+	candidates := make([]Promotion, 0)
+	for _, promotion := range PromotionData {
+		if promotion.SkuId == product.SkuId && promotion.AvailableAt(now) {
+			candidates = append(candidates, promotion)
+		}
+	}
+	promotionLock.RUnlock()
+
+	allowed := map[string]bool{}
+	for _, code := range codes {
+		allowed[NormalizeCouponCode(code)] = true
+	}
+
+	best, found := AppliedPromotion{}, false
+	for _, promotion := range candidates {
+		if promotion.Code != "" && !allowed[promotion.Code] {
+			continue
+		}
+
+		// Looked up outside promotionLock as it takes storeLock
+		categories := map[string]bool{}
+		for _, categoryId := range promotion.CategoryIds {
+			for id := range CategoryDescendantIds(categoryId) {
+				categories[id] = true
+			}
+		}
+
+		if !promotion.appliesTo(product, categories) {
+			continue
+		}
+
+		discount := promotion.DiscountOn(price)
+		if !found || discount > best.Discount || discount == best.Discount && promotion.Id < best.PromotionId {
+			best = AppliedPromotion{
+				PromotionId: promotion.Id,
+				Name:        promotion.Name,
+				Code:        promotion.Code,
+				Discount:    discount,
+			}
+			found = true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,215 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TaxClass string
+
+const (
+	TaxStandard TaxClass = "standard"
+	TaxReduced  TaxClass = "reduced"
+	TaxZero     TaxClass = "zero"
+	TaxExempt   TaxClass = "exempt"
+)
+
+// DefaultTaxJurisdiction is used for merchants that have not picked one
+const DefaultTaxJurisdiction = "NG"
+
+var ErrUnknownJurisdiction = errors.New("no tax rules for jurisdiction")
+
+// TaxRule is the rate, in percent, charged on a tax class in a jurisdiction
+type TaxRule struct {
+	Jurisdiction string   `json:"jurisdiction"`
+	Class        TaxClass `json:"class"`
+	Rate         float32  `json:"rate"`
+}
+
+// TaxSettings is how a merchant charges tax. With PricesIncludeTax the prices of its products
+// are gross prices the tax is taken out of, otherwise tax is added on top of them.
+type TaxSettings struct {
+	SkuId            string    `json:"skuId"`
+	Jurisdiction     string    `json:"jurisdiction"`
+	PricesIncludeTax bool      `json:"pricesIncludeTax"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// TaxBreakdown splits an amount into its net, tax and gross parts
+type TaxBreakdown struct {
+	Jurisdiction string   `json:"jurisdiction"`
+	Class        TaxClass `json:"class"`
+	Rate         float32  `json:"rate"`
+	Inclusive    bool     `json:"inclusive"`
+	Net          float32  `json:"net"`
+	Tax          float32  `json:"tax"`
+	Gross        float32  `json:"gross"`
+}
+
+// DefaultTaxRules are used until rules are loaded from config, Nigerian VAT is 7.5%
+var DefaultTaxRules = []TaxRule{
+	{Jurisdiction: "NG", Class: TaxStandard, Rate: 7.5},
+	{Jurisdiction: "NG", Class: TaxZero, Rate: 0},
+	{Jurisdiction: "NG", Class: TaxExempt, Rate: 0},
+}
+
+// taxLock guards TaxRuleData and TaxSettingsData
+var taxLock sync.RWMutex
+
+// TaxRuleData is keyed by jurisdiction and class
+var TaxRuleData = taxRuleTable(DefaultTaxRules)
+
+var TaxSettingsData = map[string]TaxSettings{}
+
+func IsTaxClass(class TaxClass) bool {
+	switch class {
+	case TaxStandard, TaxReduced, TaxZero, TaxExempt:
+		return true
+	}
+	return false
+}
+
+func taxRuleKey(jurisdiction string, class TaxClass) string {
+	return strings.ToUpper(jurisdiction) + "/" + string(class)
+}
+
+func taxRuleTable(rules []TaxRule) map[string]TaxRule {
+	table := make(map[string]TaxRule, len(rules))
+	for _, rule := range rules {
+		rule.Jurisdiction = strings.ToUpper(rule.Jurisdiction)
+		table[taxRuleKey(rule.Jurisdiction, rule.Class)] = rule
+	}
+	return table
+}
+
+// LoadTaxRules reads a JSON array of tax rules, every rule needs a jurisdiction, a known class and a rate from 0 to 100
+func LoadTaxRules(path string) ([]TaxRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []TaxRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid tax rules in %s: %w", path, err)
+	}
+
+	for _, rule := range rules {
+		if strings.TrimSpace(rule.Jurisdiction) == "" || !IsTaxClass(rule.Class) || rule.Rate < 0 || rule.Rate > 100 {
+			return nil, fmt.Errorf("invalid tax rule %+v in %s", rule, path)
+		}
+	}
+	return rules, nil
+}
+
+// SetTaxRules replaces every tax rule
+func SetTaxRules(rules []TaxRule) {
+	taxLock.Lock()
+	defer taxLock.Unlock()
+
+	TaxRuleData = taxRuleTable(rules)
+}
+
+// TaxRules returns every tax rule sorted by jurisdiction and class
+func TaxRules() []TaxRule {
+	taxLock.RLock()
+	defer taxLock.RUnlock()
+
+	rules := make([]TaxRule, 0, len(TaxRuleData))
+	for _, rule := range TaxRuleData {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Jurisdiction != rules[j].Jurisdiction {
+			return rules[i].Jurisdiction < rules[j].Jurisdiction
+		}
+		return rules[i].Class < rules[j].Class
+	})
+	return rules
+}
+
+func HasTaxJurisdiction(jurisdiction string) bool {
+	taxLock.RLock()
+	defer taxLock.RUnlock()
+
+	for _, rule := range TaxRuleData {
+		if rule.Jurisdiction == strings.ToUpper(jurisdiction) {
+			return true
+		}
+	}
+	return false
+}
+
+// MerchantTaxSettings returns the tax settings of a merchant, merchants start in the default
+// jurisdiction with tax included in their prices
+func MerchantTaxSettings(skuId string) TaxSettings {
+	taxLock.RLock()
+	defer taxLock.RUnlock()
+
+	return merchantTaxSettings(skuId)
+}
+
+func merchantTaxSettings(skuId string) TaxSettings {
+	if settings, ok := TaxSettingsData[skuId]; ok {
+		return settings
+	}
+	return TaxSettings{SkuId: skuId, Jurisdiction: DefaultTaxJurisdiction, PricesIncludeTax: true}
+}
+
+func SaveTaxSettings(settings TaxSettings) (TaxSettings, error) {
+	if !HasTaxJurisdiction(settings.Jurisdiction) {
+		return TaxSettings{}, ErrUnknownJurisdiction
+	}
+
+	taxLock.Lock()
+	defer taxLock.Unlock()
+
+	settings.Jurisdiction = strings.ToUpper(settings.Jurisdiction)
+	settings.UpdatedAt = time.Now()
+	TaxSettingsData[settings.SkuId] = settings
+	return settings, nil
+}
+
+// CalculateTax splits an amount at a rate in percent. Inclusive amounts already contain the tax,
+// exclusive amounts are net and get the tax added. Tax is rounded to two decimals.
+func CalculateTax(amount float32, rate float32, inclusive bool) (net float32, tax float32, gross float32) {
+	if inclusive {
+		tax = roundMoney(amount * rate / (100 + rate))
+		return amount - tax, tax, amount
+	}
+	tax = roundMoney(amount * rate / 100)
+	return amount, tax, amount + tax
+}
+
+func roundMoney(amount float32) float32 {
+	return float32(math.Round(float64(amount)*100) / 100)
+}
+
+// ProductTax returns the tax on an amount charged for a product by its merchant.
+// Products without a class are standard rated, classes without a rule in the jurisdiction are not taxed.
+func ProductTax(product Product, amount float32) TaxBreakdown {
+	taxLock.RLock()
+	settings := merchantTaxSettings(product.SkuId)
+	class := product.TaxClass
+	if class == "" {
+		class = TaxStandard
+	}
+	rule := TaxRuleData[taxRuleKey(settings.Jurisdiction, class)]
+	taxLock.RUnlock()
+
+	breakdown := TaxBreakdown{
+		Jurisdiction: settings.Jurisdiction,
+		Class:        class,
+		Rate:         rule.Rate,
+		Inclusive:    settings.PricesIncludeTax,
+	}
+	breakdown.Net, breakdown.Tax, breakdown.Gross = CalculateTax(amount, rule.Rate, settings.PricesIncludeTax)
+	return breakdown
+}
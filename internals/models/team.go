@@ -0,0 +1,258 @@
+package models
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleManager Role = "manager"
+	RoleEditor  Role = "editor"
+	RoleViewer  Role = "viewer"
+)
+
+type Permission string
+
+const (
+	PermissionProductsCreate   Permission = "products:create"
+	PermissionProductsUpdate   Permission = "products:update"
+	PermissionProductsDelete   Permission = "products:delete"
+	PermissionMembersManage    Permission = "members:manage"
+	PermissionAPIKeysManage    Permission = "apiKeys:manage"
+	PermissionOrdersManage     Permission = "orders:manage"
+	PermissionAuditRead        Permission = "audit:read"
+	PermissionPromotionsManage Permission = "promotions:manage"
+	PermissionSettingsManage   Permission = "settings:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleOwner:   {PermissionProductsCreate, PermissionProductsUpdate, PermissionProductsDelete, PermissionMembersManage, PermissionAPIKeysManage, PermissionOrdersManage, PermissionAuditRead, PermissionPromotionsManage, PermissionSettingsManage},
+	RoleManager: {PermissionProductsCreate, PermissionProductsUpdate, PermissionProductsDelete, PermissionMembersManage, PermissionAPIKeysManage, PermissionOrdersManage, PermissionAuditRead, PermissionPromotionsManage, PermissionSettingsManage},
+	RoleEditor:  {PermissionProductsCreate, PermissionProductsUpdate},
+	RoleViewer:  {},
+}
+
+type MemberStatus string
+
+const (
+	MemberInvited MemberStatus = "invited"
+	MemberActive  MemberStatus = "active"
+)
+
+const (
+	inviteTokenPrefix = "inv_"
+	memberTokenPrefix = "mem_"
+)
+
+var (
+	ErrMemberNotFound = errors.New("member not found")
+	ErrMemberExists   = errors.New("member already in the team")
+	ErrInviteNotFound = errors.New("invite not found or already accepted")
+)
+
+// Member is a staff account of a merchant. Invited members join with their invite token
+// and get an access token, both are only stored hashed.
+type Member struct {
+	Id         string       `json:"id"`
+	SkuId      string       `json:"skuId"`
+	Email      string       `json:"email"`
+	Role       Role         `json:"role"`
+	Status     MemberStatus `json:"status"`
+	InvitedBy  string       `json:"invitedBy"`
+	InviteHash string       `json:"-"`
+	TokenHash  string       `json:"-"`
+	InvitedAt  time.Time    `json:"invitedAt"`
+	JoinedAt   *time.Time   `json:"joinedAt"`
+}
+
+var teamLock sync.RWMutex
+
+var MemberData = map[string]Member{}
+
+// memberTokens finds the id of a member from the hash of an invite or access token
+var memberTokens = map[string]string{}
+
+func IsRole(role Role) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func (role Role) Can(permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManageRole reports whether a member with the role can give or take away the target role.
+// Owners manage everyone else, managers only manage editors and viewers.
+func (role Role) CanManageRole(target Role) bool {
+	switch role {
+	case RoleOwner:
+		return target != RoleOwner
+	case RoleManager:
+		return target == RoleEditor || target == RoleViewer
+	}
+	return false
+}
+
+// InviteMember adds an invited member to the team of the merchant and returns the invite token
+func InviteMember(skuId string, email string, role Role, invitedBy string) (Member, string, error) {
+	token, err := newSecret(inviteTokenPrefix)
+	if err != nil {
+		return Member{}, "", err
+	}
+
+	teamLock.Lock()
+	defer teamLock.Unlock()
+
+	for _, member := range MemberData {
+		if member.SkuId == skuId && strings.EqualFold(member.Email, email) {
+			return Member{}, "", ErrMemberExists
+		}
+	}
+
+	member := Member{
+		Id:         uuid.Must(uuid.NewRandom()).String(),
+		SkuId:      skuId,
+		Email:      email,
+		Role:       role,
+		Status:     MemberInvited,
+		InvitedBy:  invitedBy,
+		InviteHash: HashSecret(token),
+		InvitedAt:  time.Now(),
+	}
+
+	MemberData[member.Id] = member
+	memberTokens[member.InviteHash] = member.Id
+	return member, token, nil
+}
+
+// AcceptInvite activates the invited member and returns the token they use from then on
+func AcceptInvite(inviteToken string) (Member, string, error) {
+	token, err := newSecret(memberTokenPrefix)
+	if err != nil {
+		return Member{}, "", err
+	}
+
+	teamLock.Lock()
+	defer teamLock.Unlock()
+
+	hash := HashSecret(inviteToken)
+	member, ok := MemberData[memberTokens[hash]]
+
+	if !ok || member.Status != MemberInvited || member.InviteHash != hash {
+		return Member{}, "", ErrInviteNotFound
+	}
+
+	now := time.Now()
+	delete(memberTokens, member.InviteHash)
+	member.InviteHash = ""
+	member.TokenHash = HashSecret(token)
+	member.Status = MemberActive
+	member.JoinedAt = &now
+
+	MemberData[member.Id] = member
+	memberTokens[member.TokenHash] = member.Id
+	return member, token, nil
+}
+
+// AuthenticateMember finds the active member with the access token
+func AuthenticateMember(token string) (Member, error) {
+	teamLock.RLock()
+	defer teamLock.RUnlock()
+
+	hash := HashSecret(token)
+	member, ok := MemberData[memberTokens[hash]]
+
+	if !ok || member.Status != MemberActive || member.TokenHash != hash {
+		return Member{}, ErrMemberNotFound
+	}
+	return member, nil
+}
+
+// MerchantMembers returns the team of a merchant in the order they were invited
+func MerchantMembers(skuId string) []Member {
+	teamLock.RLock()
+	defer teamLock.RUnlock()
+
+	members := make([]Member, 0)
+	for _, member := range MemberData {
+		if member.SkuId == skuId {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].InvitedAt.Before(members[j].InvitedAt)
+	})
+	return members
+}
+
+// MerchantHasMembers reports whether anyone has joined the team of the merchant
+func MerchantHasMembers(skuId string) bool {
+	teamLock.RLock()
+	defer teamLock.RUnlock()
+
+	for _, member := range MemberData {
+		if member.SkuId == skuId && member.Status == MemberActive {
+			return true
+		}
+	}
+	return false
+}
+
+// SkuIdRole is the role of a request that only names its merchant with skuId. A merchant without members or
+// API keys is still the owner, once it has either the skuId alone is the lowest role.
+func SkuIdRole(skuId string) Role {
+	if MerchantHasMembers(skuId) || MerchantHasAPIKeys(skuId) {
+		return RoleViewer
+	}
+	return RoleOwner
+}
+
+func FindMemberById(id string) (Member, bool) {
+	teamLock.RLock()
+	defer teamLock.RUnlock()
+
+	member, ok := MemberData[id]
+	return member, ok
+}
+
+func UpdateMemberRole(id string, role Role) (Member, error) {
+	teamLock.Lock()
+	defer teamLock.Unlock()
+
+	member, ok := MemberData[id]
+	if !ok {
+		return Member{}, ErrMemberNotFound
+	}
+
+	member.Role = role
+	MemberData[id] = member
+	return member, nil
+}
+
+// RemoveMember takes the member out of the team, their tokens stop working straight away
+func RemoveMember(id string) bool {
+	teamLock.Lock()
+	defer teamLock.Unlock()
+
+	member, ok := MemberData[id]
+	if !ok {
+		return false
+	}
+
+	delete(memberTokens, member.InviteHash)
+	delete(memberTokens, member.TokenHash)
+	delete(MemberData, id)
+	return true
+}
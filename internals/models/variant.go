@@ -0,0 +1,168 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrVariantSkuExists     = errors.New("variant skuId already exists")
+	ErrVariantOptionsExist  = errors.New("variant options already exist")
+	ErrVariantStockReserved = errors.New("variant stock below reserved units")
+)
+
+type Variant struct {
+	Id        string            `json:"id"`
+	ProductId string            `json:"productId"`
+	SkuId     string            `json:"skuId"`
+	Options   map[string]string `json:"options"`
+	Price     float32           `json:"price"`
+	Stock     int               `json:"stock"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+type PriceRange struct {
+	Min float32 `json:"min"`
+	Max float32 `json:"max"`
+}
+
+var VariantData = map[string]Variant{}
+
+func GetProductVariants(productId string) []Variant {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	return productVariants(productId)
+}
+
+func productVariants(productId string) []Variant {
+	variants := make([]Variant, 0)
+	for _, variant := range VariantData {
+		if variant.ProductId == productId {
+			variants = append(variants, variant)
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		return variants[i].CreatedAt.Before(variants[j].CreatedAt)
+	})
+	return variants
+}
+
+func FindVariantById(productId string, id string) (Variant, bool) {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+
+	variant, ok := VariantData[id]
+	if !ok || variant.ProductId != productId {
+		return Variant{}, false
+	}
+	return variant, true
+}
+
+// CreateVariant stores a new variant, the uniqueness checks and the insert share the store lock so two requests
+// cannot both add the same skuId
+func CreateVariant(variant Variant) error {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	if err := checkVariant(variant); err != nil {
+		return err
+	}
+
+	VariantData[variant.Id] = variant
+	return nil
+}
+
+// UpdateVariant applies update to the stored variant while holding the store lock. The result is checked like a
+// new variant and its stock cannot be lowered below the units reserved
+func UpdateVariant(id string, update func(variant *Variant)) (Variant, error) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	variant, ok := VariantData[id]
+	if !ok {
+		return Variant{}, ErrVariantNotFound
+	}
+
+	stock := variant.Stock
+	update(&variant)
+
+	if err := checkVariant(variant); err != nil {
+		return Variant{}, err
+	}
+
+	if variant.Stock < stock && variant.Stock < reservedStock(variant.ProductId, id) {
+		return Variant{}, ErrVariantStockReserved
+	}
+
+	variant.UpdatedAt = time.Now()
+	VariantData[id] = variant
+	return variant, nil
+}
+
+// checkVariant fails when another variant has the skuId of variant or the same options on the same product,
+// the caller holds storeLock
+func checkVariant(variant Variant) error {
+	for _, other := range VariantData {
+		if other.Id == variant.Id {
+			continue
+		}
+		if other.SkuId == variant.SkuId {
+			return ErrVariantSkuExists
+		}
+		if other.ProductId == variant.ProductId && sameOptions(other.Options, variant.Options) {
+			return ErrVariantOptionsExist
+		}
+	}
+	return nil
+}
+
+// DeleteVariant removes a variant together with its stock reservations
+func DeleteVariant(id string) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	delete(VariantData, id)
+	for reservationId, reservation := range ReservationData {
+		if reservation.VariantId == id {
+			delete(ReservationData, reservationId)
+		}
+	}
+}
+
+// ProductPriceRange returns the cheapest and dearest variant price, or the product price when it has no variants
+func ProductPriceRange(product Product) PriceRange {
+	now := time.Now()
+	variants := GetProductVariants(product.Id)
+	if len(variants) == 0 {
+		price := EffectivePrice(product.Id, "", product.Price, now)
+		return PriceRange{Min: price, Max: price}
+	}
+
+	first := EffectivePrice(product.Id, variants[0].Id, variants[0].Price, now)
+	priceRange := PriceRange{Min: first, Max: first}
+	for _, variant := range variants[1:] {
+		price := EffectivePrice(product.Id, variant.Id, variant.Price, now)
+		if price < priceRange.Min {
+			priceRange.Min = price
+		}
+		if price > priceRange.Max {
+			priceRange.Max = price
+		}
+	}
+	return priceRange
+}
+
+func sameOptions(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if b[key] != value {
+			return false
+		}
+	}
+	return true
+}
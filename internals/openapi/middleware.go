@@ -0,0 +1,229 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/validators"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationConfig sets what Validation checks against the document
+type ValidationConfig struct {
+	// Document defaults to the document of the API
+	Document *Document
+	// Responses also checks what handlers send and turns responses that do not match into
+	// invalid_response problems, it is meant for development
+	Responses bool
+}
+
+// Validation rejects requests whose parameters or body do not match the document with a validation problem.
+// Requests to routes the document does not have are left to the router.
+func Validation(config ValidationConfig) fiber.Handler {
+	document := config.Document
+	if document == nil {
+		document = Build()
+	}
+	router := newRouter(document)
+
+	return func(ctx *fiber.Ctx) error {
+		operation, path, params := router.find(ctx.Method(), ctx.Path())
+
+		if operation == nil {
+			return ctx.Next()
+		}
+
+		if err := checkRequest(ctx, document, operation, params); err != nil {
+			return err
+		}
+
+		if !config.Responses {
+			return ctx.Next()
+		}
+
+		// Errors are written first so their problem is checked too
+		if err := ctx.Next(); err != nil {
+			if err := ctx.App().ErrorHandler(ctx, err); err != nil {
+				return err
+			}
+		}
+
+		return checkResponse(ctx, document, operation, path)
+	}
+}
+
+func checkRequest(ctx *fiber.Ctx, document *Document, operation *Operation, params map[string]string) error {
+	trans := validators.Translator(ctx.Get(fiber.HeaderAcceptLanguage))
+	c := &checker{schemas: document.Components.Schemas, trans: trans}
+
+	for _, parameter := range operation.Parameters {
+		parameter = document.parameter(parameter)
+
+		var value string
+		var present bool
+		switch parameter.In {
+		case "path":
+			value, present = params[parameter.Name]
+		case "query":
+			present = ctx.Context().QueryArgs().Has(parameter.Name)
+			value = ctx.Query(parameter.Name)
+		case "header":
+			value = ctx.Get(parameter.Name)
+			present = value != ""
+		}
+
+		if !present {
+			if parameter.Required {
+				c.fail(parameter.Name, "required", "")
+			}
+			continue
+		}
+
+		c.check(parameter.Schema, parameterValue(parameter.Schema, value), parameter.Name)
+	}
+
+	if body := operation.RequestBody; body != nil {
+		mediaType := baseMediaType(ctx.Get(fiber.HeaderContentType))
+		content, documented := body.Content[mediaType]
+
+		switch {
+		case len(ctx.Body()) == 0 && mediaType == "":
+			if body.Required {
+				c.fail("body", "required", "")
+			}
+		case !documented:
+			return problem.New(415, problem.CodeUnsupportedMediaType, "Send the body as "+strings.Join(mediaTypes(body.Content), " or "))
+		case mediaType == fiber.MIMEMultipartForm:
+			form, err := ctx.MultipartForm()
+			if err != nil {
+				return problem.New(400, problem.CodeInvalidPayload, "Invalid request payload")
+			}
+			for _, name := range c.resolve(content.Schema).Required {
+				if len(form.File[name]) == 0 && len(form.Value[name]) == 0 {
+					c.fail(name, "required", "")
+				}
+			}
+		default:
+			value, err := decode(ctx.Body())
+			if err != nil {
+				return problem.New(400, problem.CodeInvalidPayload, "Invalid request payload")
+			}
+			c.check(content.Schema, value, "")
+		}
+	}
+
+	if len(c.errors) > 0 {
+		detail, _ := trans.T(translationKey("requestInvalid"))
+		return problem.Validation(detail, c.errors)
+	}
+	return nil
+}
+
+func checkResponse(ctx *fiber.Ctx, document *Document, operation *Operation, path string) error {
+	response := ctx.Response()
+
+	// Streamed bodies such as exports are not buffered to be checked
+	if response.IsBodyStream() {
+		return nil
+	}
+
+	status := response.StatusCode()
+	documented, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		documented = operation.Responses["default"]
+	}
+	documented = document.response(documented)
+
+	c := &checker{schemas: document.Components.Schemas, trans: validators.Translator("")}
+	mediaType := baseMediaType(string(response.Header.ContentType()))
+
+	if documented == nil {
+		c.fail("status", "enum", strings.Join(responseStatuses(operation), " "))
+	} else if content, ok := documented.Content[mediaType]; !ok {
+		c.fail("content-type", "enum", strings.Join(mediaTypes(documented.Content), " "))
+	} else if mediaType == fiber.MIMEApplicationJSON || mediaType == problem.ContentType {
+		value, err := decode(response.Body())
+		if err != nil {
+			c.fail("", "type", "json")
+		} else {
+			c.check(content.Schema, value, "")
+		}
+	}
+
+	if len(c.errors) > 0 {
+		invalid := problem.New(500, problem.CodeInvalidResponse, ctx.Method()+" "+path+" sent a "+strconv.Itoa(status)+" response that does not match the API specification")
+		invalid.Errors = c.errors
+		return invalid
+	}
+	return nil
+}
+
+// parameter resolves a reference to a parameter of the components
+func (document *Document) parameter(parameter Parameter) Parameter {
+	if name, ok := strings.CutPrefix(parameter.Ref, "#/components/parameters/"); ok {
+		return document.Components.Parameters[name]
+	}
+	return parameter
+}
+
+// response resolves a reference to a response of the components
+func (document *Document) response(response *Response) *Response {
+	if response == nil {
+		return nil
+	}
+	if name, ok := strings.CutPrefix(response.Ref, "#/components/responses/"); ok {
+		return document.Components.Responses[name]
+	}
+	return response
+}
+
+// parameterValue converts a parameter to the JSON type of its schema, values that do not convert stay strings
+// so the type check rejects them
+func parameterValue(schema *Schema, value string) interface{} {
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return value
+}
+
+func decode(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	err := decoder.Decode(&value)
+	return value, err
+}
+
+func baseMediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+func mediaTypes(content map[string]MediaType) []string {
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func responseStatuses(operation *Operation) []string {
+	statuses := make([]string, 0, len(operation.Responses))
+	for status := range operation.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	return statuses
+}
@@ -0,0 +1,242 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/problem"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const Version = "3.0.3"
+
+var pathParameterPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// Security schemes that identify the merchant
+const (
+	securityAPIKey      = "ApiKey"
+	securityMemberToken = "MemberToken"
+	securitySkuId       = "SkuId"
+)
+
+// Build generates the OpenAPI document of every route of the API from the types package
+func Build() *Document {
+	g := newGenerator()
+
+	document := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "ShopAnythingLagos API",
+			Description: "This is the ShopAnythingLagos API documentation. Errors are RFC 7807 problem details.",
+			Version:     "1.0",
+		},
+		Servers: []Server{{URL: "/"}},
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Parameters: map[string]Parameter{
+				"AcceptLanguage": {
+					Name: "Accept-Language", In: "header",
+					Description: "The locale of validation messages, en or fr",
+					Schema:      &Schema{Type: "string"},
+				},
+				"IdempotencyKey": {
+					Name: middleware.IdempotencyKeyHeader, In: "header",
+					Description: "Retries with the same key within 24 hours get the first response back",
+					Schema:      &Schema{Type: "string", MaxLength: intPointer(255)},
+				},
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				securityAPIKey: {
+					Type: "apiKey", In: "header", Name: middleware.APIKeyHeader,
+					Description: "An API key of the merchant, limited to its scopes",
+				},
+				securityMemberToken: {
+					Type: "apiKey", In: "header", Name: middleware.MemberTokenHeader,
+					Description: "The access token of a team member, limited to their role",
+				},
+				securitySkuId: {
+					Type: "apiKey", In: "query", Name: "skuId",
+					Description: "The id of the merchant, who acts as the owner",
+				},
+			},
+		},
+	}
+
+	problemSchema := g.of(problem.Problem{}, false)
+	document.Components.Responses = map[string]*Response{}
+	for _, status := range []int{400, 401, 403, 404, 409, 429, 500} {
+		document.Components.Responses[strconv.Itoa(status)] = &Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{problem.ContentType: {Schema: problemSchema}},
+		}
+	}
+
+	for _, group := range groups {
+		document.Tags = append(document.Tags, Tag{Name: group.tag, Description: group.description})
+	}
+
+	for _, route := range routes {
+		path := OpenAPIPath(route.path)
+		item, ok := document.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			document.Paths[path] = item
+		}
+
+		if item.Operation(route.method) != nil {
+			panic("openapi: " + route.method + " " + route.path + " is documented twice")
+		}
+		item.setOperation(route.method, g.operation(route))
+	}
+
+	document.Components.Schemas = g.schemas
+	return document
+}
+
+func (g *generator) operation(route route) *Operation {
+	group := groupOf(route.path)
+	operation := &Operation{
+		OperationId: operationId(route.method, route.path),
+		Summary:     route.summary,
+		Description: route.description,
+		Tags:        []string{group.tag},
+		Responses:   map[string]*Response{},
+	}
+
+	for _, match := range pathParameterPattern.FindAllStringSubmatch(route.path, -1) {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	operation.Parameters = append(operation.Parameters, route.parameters...)
+
+	if route.method != "GET" {
+		operation.Parameters = append(operation.Parameters, Parameter{Ref: "#/components/parameters/IdempotencyKey"})
+	}
+
+	if route.body != nil {
+		operation.Parameters = append(operation.Parameters, Parameter{Ref: "#/components/parameters/AcceptLanguage"})
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{fiber.MIMEApplicationJSON: {Schema: g.of(route.body, true)}},
+		}
+		operation.Responses["400"] = problemResponse(400)
+	}
+
+	if route.upload != "" {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{fiber.MIMEMultipartForm: {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{route.upload: {Type: "array", Items: &Schema{Type: "string", Format: "binary"}}},
+				Required:   []string{route.upload},
+			}}},
+		}
+		operation.Responses["400"] = problemResponse(400)
+	}
+
+	if route.merchant {
+		operation.Security = []map[string][]string{{securityMemberToken: {}}, {securitySkuId: {}}}
+		if !group.denyAPIKeys {
+			operation.Security = append([]map[string][]string{{securityAPIKey: {}}}, operation.Security...)
+		}
+		operation.Responses["401"] = problemResponse(401)
+		operation.Responses["403"] = problemResponse(403)
+	}
+
+	if scope := group.scope(route.method); scope != "" {
+		operation.Description = strings.TrimSpace(operation.Description + "\n\nAPI keys need the " + scope + " scope.")
+		operation.Responses["403"] = problemResponse(403)
+	}
+
+	if group.denyAPIKeys {
+		operation.Description = strings.TrimSpace(operation.Description + "\n\nAPI keys cannot be used.")
+	}
+
+	if strings.Contains(route.path, ":") {
+		operation.Responses["404"] = problemResponse(404)
+	}
+	operation.Responses["429"] = problemResponse(429)
+	operation.Responses["default"] = problemResponse(500)
+
+	success := &Response{
+		Description: http.StatusText(route.status),
+		Content:     map[string]MediaType{},
+	}
+	if route.response != nil {
+		success.Content[fiber.MIMEApplicationJSON] = MediaType{Schema: g.of(route.response, false)}
+	}
+	for _, mediaType := range route.files {
+		success.Content[mediaType] = MediaType{Schema: &Schema{Type: "string"}}
+	}
+	operation.Responses[strconv.Itoa(route.status)] = success
+
+	return operation
+}
+
+func (group group) scope(method string) string {
+	if method == "GET" {
+		return group.readScope
+	}
+	return group.writeScope
+}
+
+// groupOf returns the group of the longest prefix of path
+func groupOf(path string) group {
+	found := group{}
+	for _, group := range groups {
+		matches := path == group.prefix || strings.HasPrefix(path, strings.TrimSuffix(group.prefix, "/")+"/")
+		if matches && len(group.prefix) > len(found.prefix) {
+			found = group
+		}
+	}
+	return found
+}
+
+// OpenAPIPath turns a Fiber path such as /product/:id into /product/{id}
+func OpenAPIPath(path string) string {
+	return pathParameterPattern.ReplaceAllString(path, "{$1}")
+}
+
+// operationId names an operation after its method and path, GET /product/:id/variants is getProductIdVariants
+func operationId(method string, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '.' || r == ':' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	if path == "/" {
+		id += "Root"
+	}
+	return id
+}
+
+func problemResponse(status int) *Response {
+	return &Response{Ref: "#/components/responses/" + strconv.Itoa(status)}
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+var document = sync.OnceValue(func() []byte {
+	data, err := json.MarshalIndent(Build(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+})
+
+// JSON returns the document as indented JSON, it is built once
+func JSON() []byte {
+	return document()
+}
+
+// Handler serves the document
+func Handler(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	return ctx.Send(JSON())
+}
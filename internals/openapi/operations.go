@@ -0,0 +1,167 @@
+package openapi
+
+import (
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/payment"
+	"github.com/rnwonder/SAL/types"
+)
+
+// group is what the routes under a path prefix share, the scopes are the ones API keys need
+type group struct {
+	prefix      string
+	tag         string
+	description string
+	readScope   string
+	writeScope  string
+	denyAPIKeys bool
+}
+
+var groups = []group{
+	{"/product", "Product", "Products, their variants, stock, prices and images", models.ScopeProductsRead, models.ScopeProductsWrite, false},
+	{"/category", "Category", "The category tree of a merchant", models.ScopeProductsRead, models.ScopeProductsWrite, false},
+	{"/tax", "Tax", "Tax rules and the tax settings of a merchant", models.ScopeProductsRead, models.ScopeProductsWrite, false},
+	{"/cart", "Cart", "Anonymous shopping carts", "", "", false},
+	{"/promotion", "Promotion", "Automatic promotions and coupons", models.ScopeProductsRead, models.ScopeProductsWrite, false},
+	{"/order", "Order", "Orders placed from carts and their payments", models.ScopeOrdersRead, models.ScopeOrdersWrite, false},
+	{"/api-key", "API Key", "API keys for server to server integrations", "", "", true},
+	{"/team", "Team", "The members of a merchant and their roles", "", "", true},
+	{"/audit", "Audit", "The audit log of a merchant", "", "", true},
+	{"/payment", "Payment", "Notifications from the payment provider", "", "", false},
+	{"/graphql", "GraphQL", "Products, merchants and categories over GraphQL", "", "", false},
+	{"/", "Meta", "The API itself", "", "", false},
+}
+
+// route documents one registered route. Path is written the way it is registered with Fiber.
+type route struct {
+	method      string
+	path        string
+	summary     string
+	description string
+	// merchant routes need the merchant to be identified by an API key, a member token or the skuId query parameter
+	merchant bool
+	// parameters are the query and header parameters, path parameters are read from the path
+	parameters []Parameter
+	// body is a value of the request payload type
+	body interface{}
+	// upload is the multipart/form-data field files are uploaded in
+	upload   string
+	status   int
+	response interface{}
+	// files are the other media types the response can be sent as, the only ones when response is nil
+	files []string
+}
+
+// Query parameters shared by several routes
+var (
+	pageParameters = []Parameter{
+		{Name: "page", In: "query", Description: "The page to return, starting at 1", Schema: &Schema{Type: "integer", Minimum: float(1)}},
+		{Name: "limit", In: "query", Description: "The number of items per page", Schema: &Schema{Type: "integer", Minimum: float(1)}},
+	}
+	productFilterParameters = []Parameter{
+		{Name: "search", In: "query", Description: "Only products whose name contains the text, ignoring case", Schema: &Schema{Type: "string"}},
+		{Name: "sortKey", In: "query", Description: "Defaults to createdAt", Schema: &Schema{Type: "string", Enum: []interface{}{"name", "price", "createdAt"}}},
+		{Name: "sortOrder", In: "query", Description: "Defaults to desc", Schema: &Schema{Type: "string", Enum: []interface{}{"asc", "desc"}}},
+		{Name: "category", In: "query", Description: "Only products in the category or one of its descendants", Schema: &Schema{Type: "string"}},
+		{Name: "inStock", In: "query", Description: "Only products that are in stock, or out of stock when false", Schema: &Schema{Type: "boolean"}},
+	}
+)
+
+var routes = []route{
+	{method: "GET", path: "/product", summary: "Get all products", parameters: append(append([]Parameter{}, pageParameters...), productFilterParameters...), status: 200, response: types.GetProductResponse{}},
+	{method: "GET", path: "/product/export", summary: "Export products", description: "Streams every product matching the search and sort parameters as csv, ndjson or json", parameters: append([]Parameter{
+		{Name: "format", In: "query", Description: "Defaults to csv", Schema: &Schema{Type: "string", Enum: []interface{}{"csv", "ndjson", "json"}}},
+	}, productFilterParameters...), status: 200, response: []models.Product{}, files: []string{"text/csv", "application/x-ndjson"}},
+	{method: "GET", path: "/product/events", summary: "Stream product changes", description: "Server-sent events for every product created, updated or deleted. Each message has an id, the event product.created, product.updated or product.deleted, and as data the JSON of the type, the product as GET /product/{id} returns it and when it changed. Reconnecting with Last-Event-ID sends the changes missed since, a reset event is sent first when some of them are no longer kept.", parameters: []Parameter{
+		{Name: "skuId", In: "query", Description: "Only the changes to the products of the merchant", Schema: &Schema{Type: "string"}},
+		{Name: "Last-Event-ID", In: "header", Description: "Resume after the message with this id, ids from an earlier run of the server get a reset event", Schema: &Schema{Type: "string"}},
+	}, status: 200, files: []string{"text/event-stream"}},
+	{method: "GET", path: "/product/stock/low", summary: "Get low stock products", merchant: true, status: 200, response: types.StockLevelsResponse{}},
+	{method: "GET", path: "/product/:id", summary: "Get a product", status: 200, response: types.OneProductResponse{}},
+	{method: "GET", path: "/product/:id/history", summary: "Get the history of a product", merchant: true, parameters: pageParameters, status: 200, response: types.GetAuditResponse{}},
+	{method: "GET", path: "/product/:id/prices/history", summary: "Get the price history of a product", status: 200, response: types.PriceHistoryResponse{}},
+	{method: "GET", path: "/product/:id/prices/schedules", summary: "Get the price schedules of a product", merchant: true, status: 200, response: types.PriceSchedulesResponse{}},
+	{method: "POST", path: "/product/:id/prices/schedules", summary: "Schedule a price change", merchant: true, body: types.PriceSchedulePayload{}, status: 201, response: types.PriceScheduleResponse{}},
+	{method: "DELETE", path: "/product/:id/prices/schedules/:scheduleId", summary: "Cancel a price schedule", merchant: true, status: 200, response: types.PriceScheduleResponse{}},
+	{method: "POST", path: "/product", summary: "Create a product", merchant: true, body: types.ProductCreatePayload{}, status: 201, response: types.OneProductResponse{}},
+	{method: "PUT", path: "/product/:id", summary: "Update a product", merchant: true, body: types.ProductUpdatePayload{}, status: 200, response: types.OneProductResponse{}},
+	{method: "DELETE", path: "/product/:id", summary: "Delete a product", merchant: true, status: 200, response: types.MessageResponse{}},
+	{method: "POST", path: "/product/:id/categories", summary: "Assign categories to a product", merchant: true, body: types.ProductCategoriesPayload{}, status: 200, response: types.OneProductResponse{}},
+	{method: "DELETE", path: "/product/:id/categories/:categoryId", summary: "Remove a category from a product", merchant: true, status: 200, response: types.OneProductResponse{}},
+	{method: "GET", path: "/product/:id/variants", summary: "Get all variants of a product", status: 200, response: types.GetVariantsResponse{}},
+	{method: "GET", path: "/product/:id/variants/:variantId", summary: "Get a variant", status: 200, response: types.OneVariantResponse{}},
+	{method: "POST", path: "/product/:id/variants", summary: "Create a variant", merchant: true, body: types.VariantCreatePayload{}, status: 201, response: types.OneVariantResponse{}},
+	{method: "PUT", path: "/product/:id/variants/:variantId", summary: "Update a variant", merchant: true, body: types.VariantUpdatePayload{}, status: 200, response: types.OneVariantResponse{}},
+	{method: "DELETE", path: "/product/:id/variants/:variantId", summary: "Delete a variant", merchant: true, status: 200, response: types.MessageResponse{}},
+	{method: "GET", path: "/product/:id/stock", summary: "Get the stock of a product", status: 200, response: types.StockResponse{}},
+	{method: "PUT", path: "/product/:id/stock", summary: "Update the stock settings of a product", merchant: true, body: types.StockSettingsPayload{}, status: 200, response: types.StockResponse{}},
+	{method: "POST", path: "/product/:id/stock/increment", summary: "Increment the stock of a product", merchant: true, body: types.StockAdjustPayload{}, status: 200, response: types.StockResponse{}},
+	{method: "POST", path: "/product/:id/stock/decrement", summary: "Decrement the stock of a product", description: "Reserved units cannot be removed", merchant: true, body: types.StockAdjustPayload{}, status: 200, response: types.StockResponse{}},
+	{method: "POST", path: "/product/:id/reservations", summary: "Reserve stock", body: types.ReservationCreatePayload{}, status: 201, response: types.ReservationResponse{}},
+	{method: "DELETE", path: "/product/:id/reservations/:reservationId", summary: "Release a reservation", status: 200, response: types.ReservationResponse{}},
+	{method: "POST", path: "/product/:id/reservations/:reservationId/commit", summary: "Commit a reservation", merchant: true, status: 200, response: types.ReservationResponse{}},
+	{method: "GET", path: "/product/:id/images", summary: "Get the images of a product", status: 200, response: types.GetImagesResponse{}},
+	{method: "POST", path: "/product/:id/images", summary: "Upload images of a product", merchant: true, upload: "image", status: 201, response: types.GetImagesResponse{}},
+	{method: "PUT", path: "/product/:id/images/order", summary: "Reorder the images of a product", merchant: true, body: types.ImageOrderPayload{}, status: 200, response: types.GetImagesResponse{}},
+	{method: "DELETE", path: "/product/:id/images/:imageId", summary: "Delete an image of a product", merchant: true, status: 200, response: types.MessageResponse{}},
+
+	{method: "GET", path: "/category", summary: "Get all categories", status: 200, response: types.GetCategoriesResponse{}},
+	{method: "GET", path: "/category/:id", summary: "Get a category", status: 200, response: types.OneCategoryResponse{}},
+	{method: "POST", path: "/category", summary: "Create a category", merchant: true, body: types.CategoryCreatePayload{}, status: 201, response: types.OneCategoryResponse{}},
+	{method: "PUT", path: "/category/:id", summary: "Update a category", merchant: true, body: types.CategoryUpdatePayload{}, status: 200, response: types.OneCategoryResponse{}},
+	{method: "DELETE", path: "/category/:id", summary: "Delete a category", merchant: true, status: 200, response: types.MessageResponse{}},
+
+	{method: "GET", path: "/tax/rules", summary: "Get the tax rules", status: 200, response: types.TaxRulesResponse{}},
+	{method: "GET", path: "/tax/settings", summary: "Get the tax settings", merchant: true, status: 200, response: types.TaxSettingsResponse{}},
+	{method: "PUT", path: "/tax/settings", summary: "Update the tax settings", merchant: true, body: types.TaxSettingsPayload{}, status: 200, response: types.TaxSettingsResponse{}},
+
+	{method: "POST", path: "/cart", summary: "Create a cart", status: 201, response: types.CartResponse{}},
+	{method: "GET", path: "/cart/:id", summary: "Get a cart", status: 200, response: types.CartResponse{}},
+	{method: "DELETE", path: "/cart/:id", summary: "Delete a cart", status: 200, response: types.MessageResponse{}},
+	{method: "POST", path: "/cart/:id/items", summary: "Add an item to a cart", body: types.CartItemPayload{}, status: 200, response: types.CartResponse{}},
+	{method: "PUT", path: "/cart/:id/items/:itemId", summary: "Update an item in a cart", body: types.CartItemUpdatePayload{}, status: 200, response: types.CartResponse{}},
+	{method: "DELETE", path: "/cart/:id/items/:itemId", summary: "Remove an item from a cart", status: 200, response: types.CartResponse{}},
+	{method: "POST", path: "/cart/:id/coupons", summary: "Add a coupon to a cart", body: types.CartCouponPayload{}, status: 200, response: types.CartResponse{}},
+	{method: "DELETE", path: "/cart/:id/coupons/:code", summary: "Remove a coupon from a cart", status: 200, response: types.CartResponse{}},
+
+	{method: "GET", path: "/promotion", summary: "Get all promotions", merchant: true, status: 200, response: types.GetPromotionsResponse{}},
+	{method: "POST", path: "/promotion", summary: "Create a promotion", merchant: true, body: types.PromotionCreatePayload{}, status: 201, response: types.PromotionResponse{}},
+	{method: "GET", path: "/promotion/:id", summary: "Get a promotion", merchant: true, status: 200, response: types.PromotionResponse{}},
+	{method: "DELETE", path: "/promotion/:id", summary: "Delete a promotion", merchant: true, status: 200, response: types.MessageResponse{}},
+
+	{method: "GET", path: "/order", summary: "Get the orders of a merchant", merchant: true, parameters: []Parameter{
+		{Name: "status", In: "query", Description: "Only orders with the status", Schema: &Schema{Type: "string", Enum: []interface{}{"pending", "paid", "fulfilled", "delivered", "cancelled", "refunded"}}},
+	}, status: 200, response: types.GetOrdersResponse{}},
+	{method: "POST", path: "/order", summary: "Place an order", description: "Turns a cart into an order and removes the cart, the coupons in it are redeemed and the stock of every item is taken. Units held by reservations are not available, an order asking for more is refused with insufficient_stock", body: types.OrderCreatePayload{}, status: 201, response: types.OrderResponse{}},
+	{method: "GET", path: "/order/:id", summary: "Get an order", status: 200, response: types.OrderResponse{}},
+	{method: "POST", path: "/order/:id/transitions", summary: "Change the status of an order", description: "Moves only the items of the merchant, refunding them refunds their part of the payment. Orders become paid through the payment webhook", merchant: true, body: types.OrderTransitionPayload{}, status: 200, response: types.OrderResponse{}},
+	{method: "POST", path: "/order/:id/payment", summary: "Start paying for an order", body: types.OrderPaymentPayload{}, status: 201, response: types.PaymentResponse{}},
+
+	{method: "GET", path: "/api-key", summary: "Get the API keys of a merchant", merchant: true, status: 200, response: types.GetAPIKeysResponse{}},
+	{method: "POST", path: "/api-key", summary: "Create an API key", description: "The secret is only returned once. Only members can create keys, send the X-Member-Token of a manager", merchant: true, body: types.APIKeyCreatePayload{}, status: 201, response: types.APIKeyResponse{}},
+	{method: "DELETE", path: "/api-key/:id", summary: "Revoke an API key", merchant: true, status: 200, response: types.APIKeyResponse{}},
+	{method: "POST", path: "/api-key/:id/rotate", summary: "Rotate an API key", merchant: true, status: 200, response: types.APIKeyResponse{}},
+
+	{method: "GET", path: "/team/members", summary: "Get the team of a merchant", merchant: true, status: 200, response: types.GetMembersResponse{}},
+	{method: "POST", path: "/team/members", summary: "Invite a member", merchant: true, body: types.MemberInvitePayload{}, status: 201, response: types.MemberResponse{}},
+	{method: "PUT", path: "/team/members/:id", summary: "Change the role of a member", merchant: true, body: types.MemberUpdatePayload{}, status: 200, response: types.MemberResponse{}},
+	{method: "DELETE", path: "/team/members/:id", summary: "Remove a member", merchant: true, status: 200, response: types.MessageResponse{}},
+	{method: "POST", path: "/team/invites/accept", summary: "Accept an invite", description: "Returns the access token of the member", body: types.InviteAcceptPayload{}, status: 200, response: types.MemberResponse{}},
+
+	{method: "GET", path: "/audit", summary: "Get the audit log", merchant: true, parameters: append([]Parameter{
+		{Name: "productId", In: "query", Schema: &Schema{Type: "string"}},
+		{Name: "resourceType", In: "query", Schema: &Schema{Type: "string"}},
+		{Name: "action", In: "query", Schema: &Schema{Type: "string"}},
+		{Name: "actorId", In: "query", Schema: &Schema{Type: "string"}},
+		{Name: "from", In: "query", Description: "Only entries at or after the time", Schema: &Schema{Type: "string", Format: "date-time"}},
+		{Name: "to", In: "query", Description: "Only entries before the time", Schema: &Schema{Type: "string", Format: "date-time"}},
+	}, pageParameters...), status: 200, response: types.GetAuditResponse{}},
+
+	{method: "POST", path: "/payment/webhook", summary: "Receive payment notifications", description: "Called by the payment provider, the payload must be signed in the X-Payment-Signature header", parameters: []Parameter{
+		{Name: "X-Payment-Signature", In: "header", Required: true, Description: "The HMAC signature of the body", Schema: &Schema{Type: "string"}},
+	}, body: payment.Event{}, status: 200, response: types.MessageResponse{}},
+	{method: "POST", path: "/payment/fake/:reference", summary: "Complete a fake payment", description: "Only available with the fake payment provider, pays the transaction and sends its webhook", status: 200, response: types.MessageResponse{}},
+
+	{method: "POST", path: "/graphql", summary: "Run a GraphQL query", description: "Queries need the " + models.ScopeProductsRead + " scope for API keys, mutations also need the " + models.ScopeProductsWrite + " scope.\n\nMutations identify the merchant like the REST routes. Errors are returned in the errors of the response with a 200 status, their extensions hold the problem code, status and field errors.", body: types.GraphQLRequest{}, status: 200, response: types.GraphQLResponse{}},
+	{method: "GET", path: "/", summary: "Welcome", status: 200, response: types.WelcomeResponse{}},
+	{method: "GET", path: "/openapi.json", summary: "Get this OpenAPI document", status: 200, response: map[string]interface{}{}},
+}
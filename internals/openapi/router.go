@@ -0,0 +1,90 @@
+package openapi
+
+import "strings"
+
+// router finds the operation of a request among the paths of a document
+type router []routerPath
+
+type routerPath struct {
+	path     string
+	segments []string
+	item     *PathItem
+}
+
+func newRouter(document *Document) router {
+	paths := make(router, 0, len(document.Paths))
+	for path, item := range document.Paths {
+		paths = append(paths, routerPath{path: path, segments: segments(path), item: item})
+	}
+	return paths
+}
+
+// find returns the operation of method on path with the path it is documented under and the values of its
+// parameters. Literal segments win over parameters, so /product/export is not read as /product/{id}.
+func (r router) find(method string, path string) (*Operation, string, map[string]string) {
+	requested := segments(path)
+
+	var found *routerPath
+	for i := range r {
+		candidate := &r[i]
+		if candidate.item.Operation(method) == nil || !candidate.matches(requested) {
+			continue
+		}
+		if found == nil || candidate.moreSpecific(found) {
+			found = candidate
+		}
+	}
+
+	if found == nil {
+		return nil, "", nil
+	}
+
+	params := map[string]string{}
+	for i, segment := range found.segments {
+		if name, ok := parameterName(segment); ok {
+			params[name] = requested[i]
+		}
+	}
+	return found.item.Operation(method), found.path, params
+}
+
+func (p *routerPath) matches(requested []string) bool {
+	if len(requested) != len(p.segments) {
+		return false
+	}
+
+	for i, segment := range p.segments {
+		if _, ok := parameterName(segment); ok {
+			if requested[i] == "" {
+				return false
+			}
+		} else if segment != requested[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// moreSpecific reports whether p has a literal segment where other has its first parameter
+func (p *routerPath) moreSpecific(other *routerPath) bool {
+	for i, segment := range p.segments {
+		_, parameter := parameterName(segment)
+		_, otherParameter := parameterName(other.segments[i])
+
+		if parameter != otherParameter {
+			return otherParameter
+		}
+	}
+	return false
+}
+
+func segments(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func parameterName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
@@ -0,0 +1,346 @@
+package openapi
+
+import (
+	"github.com/rnwonder/SAL/validators"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// rulePatterns are the validation rules that can only be described with a pattern
+var rulePatterns = map[string]string{
+	"alpha":    `^[a-zA-Z]+$`,
+	"alphanum": `^[a-zA-Z0-9]+$`,
+	"numeric":  `^[-+]?[0-9]+(?:\.[0-9]+)?$`,
+	"notblank": `\S`,
+	"sku":      validators.SkuPattern,
+}
+
+// ruleFormats are the validation rules that match a JSON schema format
+var ruleFormats = map[string]string{
+	"email": "email",
+	"url":   "uri",
+	"uri":   "uri",
+	"uuid":  "uuid",
+}
+
+// generator turns Go types into schemas, structs become components named after their type.
+// Request schemas take their constraints from validate tags, response schemas only mark the
+// fields that are always sent as required.
+type generator struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+func newGenerator() *generator {
+	return &generator{
+		schemas: map[string]*Schema{},
+		types:   map[string]reflect.Type{},
+	}
+}
+
+// of returns the schema of the type of value, nil when value is nil
+func (g *generator) of(value interface{}, request bool) *Schema {
+	if value == nil {
+		return nil
+	}
+	return g.schema(reflect.TypeOf(value), request)
+}
+
+func (g *generator) schema(t reflect.Type, request bool) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(g.schema(t.Elem(), request))
+	case reflect.Struct:
+		return &Schema{Ref: "#/components/schemas/" + g.component(t, request)}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		// nil slices are sent as null
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), request), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem(), request), Nullable: true}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Interface:
+		// Any value
+		return &Schema{Nullable: true}
+	}
+
+	panic("openapi: unsupported type " + t.String())
+}
+
+// component registers the schema of a struct and returns its name. Types with the same name
+// in different packages are told apart by their package name.
+func (g *generator) component(t reflect.Type, request bool) string {
+	name := t.Name()
+	if registered, ok := g.types[name]; ok && registered != t {
+		name = strings.ToUpper(path.Base(t.PkgPath())[:1]) + path.Base(t.PkgPath())[1:] + name
+	}
+
+	if registered, ok := g.types[name]; ok {
+		if registered != t {
+			panic("openapi: two types are named " + name)
+		}
+		return name
+	}
+
+	// Registered before its fields so types that refer to themselves end
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.types[name] = t
+	g.schemas[name] = schema
+
+	g.fields(t, schema, request)
+	return name
+}
+
+func (g *generator) fields(t reflect.Type, schema *Schema, request bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a name are flattened by encoding/json
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.fields(embedded, schema, request)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schema(field.Type, request)
+
+		var required bool
+		if request {
+			tag := field.Tag.Get("validate")
+			required = applyRules(property, tag, field.Type)
+
+			if strings.HasPrefix(tag, "omitempty") {
+				property = allowZero(property, field.Type)
+			}
+		} else {
+			required = !strings.Contains(options, "omitempty")
+		}
+
+		if request && required {
+			// The required rule rejects null
+			property = notNullable(property)
+		}
+
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// applyRules adds the constraints of a validate tag to schema and reports whether the field is required.
+// Rules after dive apply to the items of slices and the values of maps, the rules of map keys are skipped.
+func applyRules(schema *Schema, tag string, t reflect.Type) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+
+	required := false
+	target := schema
+	inKeys := false
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+
+		if inKeys {
+			inKeys = name != "endkeys"
+			continue
+		}
+
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch name {
+		case "required":
+			required = required || target == schema
+			// Empty strings fail the rule too
+			if t.Kind() == reflect.String && target.MinLength == nil {
+				one := 1
+				target.MinLength = &one
+			}
+		case "dive":
+			if target.Items != nil {
+				target = target.Items
+			} else if target.AdditionalProperties != nil {
+				target = target.AdditionalProperties
+			}
+			t = t.Elem()
+		case "keys":
+			inKeys = true
+		case "min", "gte":
+			setBound(target, t, param, false, false)
+		case "gt":
+			setBound(target, t, param, false, true)
+		case "max", "lte":
+			setBound(target, t, param, true, false)
+		case "lt":
+			setBound(target, t, param, true, true)
+		case "len":
+			setBound(target, t, param, false, false)
+			setBound(target, t, param, true, false)
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				target.Enum = append(target.Enum, enumValue(t, value))
+			}
+		default:
+			if pattern, ok := rulePatterns[name]; ok {
+				target.Pattern = pattern
+			}
+			if format, ok := ruleFormats[name]; ok {
+				target.Format = format
+			}
+		}
+	}
+
+	return required
+}
+
+// allowZero lets the zero value of t through besides schema, the way the omitempty rule skips the other rules
+// for it. Pointers are skipped by the rule when they are nil, which schema already allows.
+func allowZero(schema *Schema, t reflect.Type) *Schema {
+	var zero *Schema
+	switch t.Kind() {
+	case reflect.String:
+		zero = &Schema{Type: "string", MaxLength: intPointer(0)}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		zero = &Schema{Type: schema.Type, Enum: []interface{}{0}}
+	case reflect.Slice, reflect.Array:
+		zero = &Schema{Type: "array", MaxItems: intPointer(0)}
+	case reflect.Map:
+		zero = &Schema{Type: "object", MaxProperties: intPointer(0)}
+	default:
+		return schema
+	}
+
+	unconstrained := *schema
+	unconstrained.Nullable = false
+	if reflect.DeepEqual(unconstrained, Schema{Type: schema.Type, Format: schema.Format, Items: schema.Items, AdditionalProperties: schema.AdditionalProperties}) {
+		return schema
+	}
+	return &Schema{AnyOf: []*Schema{zero, schema}, Nullable: schema.Nullable}
+}
+
+// setBound sets the lower or upper bound of schema, which is a length for strings and a count for slices and maps
+func setBound(schema *Schema, t reflect.Type, param string, upper bool, exclusive bool) {
+	value, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	count := int(value)
+	if exclusive {
+		count = int(value) + 1
+		if upper {
+			count = int(value) - 1
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if upper {
+			schema.MaxLength = &count
+		} else {
+			schema.MinLength = &count
+		}
+	case reflect.Slice, reflect.Array:
+		if upper {
+			schema.MaxItems = &count
+		} else {
+			schema.MinItems = &count
+		}
+	case reflect.Map:
+		if upper {
+			schema.MaxProperties = &count
+		} else {
+			schema.MinProperties = &count
+		}
+	default:
+		if upper {
+			schema.Maximum = &value
+			schema.ExclusiveMaximum = exclusive
+		} else {
+			schema.Minimum = &value
+			schema.ExclusiveMinimum = exclusive
+		}
+	}
+}
+
+// enumValue converts a oneof value to the JSON type of the field
+func enumValue(t reflect.Type, value string) interface{} {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	}
+	return value
+}
+
+// nullable allows null besides schema, references cannot have siblings so they are wrapped
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+	}
+
+	copied := *schema
+	copied.Nullable = true
+	return &copied
+}
+
+func notNullable(schema *Schema) *Schema {
+	if len(schema.AllOf) == 1 && schema.Nullable {
+		return schema.AllOf[0]
+	}
+
+	schema.Nullable = false
+	return schema
+}
+
+func float(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,63 @@
+package openapi
+
+import "github.com/rnwonder/SAL/validators"
+
+// messages are the validation messages of every schema rule in each locale, {0} is the field and {1} the rule parameter.
+// requestInvalid is the detail of the problem.
+var messages = map[string]map[string]string{
+	"en": {
+		"required":         "{0} is a required field",
+		"nullable":         "{0} cannot be null",
+		"type":             "{0} must be of type {1}",
+		"enum":             "{0} must be one of [{1}]",
+		"minimum":          "{0} must be {1} or greater",
+		"exclusiveMinimum": "{0} must be greater than {1}",
+		"maximum":          "{0} must be {1} or less",
+		"exclusiveMaximum": "{0} must be less than {1}",
+		"minLength":        "{0} must be at least {1} characters in length",
+		"maxLength":        "{0} must be a maximum of {1} characters in length",
+		"pattern":          "{0} is not in the expected format",
+		"format":           "{0} must be a valid {1}",
+		"minItems":         "{0} must contain at least {1} items",
+		"maxItems":         "{0} must contain at most {1} items",
+		"minProperties":    "{0} must contain at least {1} entries",
+		"maxProperties":    "{0} must contain at most {1} entries",
+		"requestInvalid":   "The request does not match the API specification",
+	},
+	"fr": {
+		"required":         "{0} est un champ obligatoire",
+		"nullable":         "{0} ne peut pas être nul",
+		"type":             "{0} doit être de type {1}",
+		"enum":             "{0} doit être l'une des valeurs [{1}]",
+		"minimum":          "{0} doit être {1} ou plus",
+		"exclusiveMinimum": "{0} doit être supérieur à {1}",
+		"maximum":          "{0} doit être {1} ou moins",
+		"exclusiveMaximum": "{0} doit être inférieur à {1}",
+		"minLength":        "{0} doit faire au moins {1} caractères",
+		"maxLength":        "{0} doit faire au maximum {1} caractères",
+		"pattern":          "{0} n'est pas au format attendu",
+		"format":           "{0} doit être un {1} valide",
+		"minItems":         "{0} doit contenir au moins {1} éléments",
+		"maxItems":         "{0} doit contenir au maximum {1} éléments",
+		"minProperties":    "{0} doit contenir au moins {1} entrées",
+		"maxProperties":    "{0} doit contenir au maximum {1} entrées",
+		"requestInvalid":   "La requête ne correspond pas à la spécification de l'API",
+	},
+}
+
+// translationKey keeps the messages of schema rules apart from the validator tags of the same name
+func translationKey(rule string) string {
+	return "openapi_" + rule
+}
+
+func init() {
+	for locale, texts := range messages {
+		trans, _ := validators.Translators.GetTranslator(locale)
+
+		for rule, text := range texts {
+			if err := trans.Add(translationKey(rule), text, false); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package openapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/rnwonder/SAL/internals/problem"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// patterns caches the compiled patterns of schemas
+var patterns sync.Map
+
+// checker validates values decoded with json.Decoder.UseNumber against the schemas of a document.
+// Every failure is collected with its message in the locale of trans.
+type checker struct {
+	schemas map[string]*Schema
+	trans   ut.Translator
+	errors  []problem.FieldError
+}
+
+func (c *checker) fail(field string, rule string, param string) {
+	if field == "" {
+		field = "body"
+	}
+
+	message, err := c.trans.T(translationKey(rule), field, param)
+	if err != nil {
+		message = field + " breaks the " + rule + " rule"
+	}
+
+	c.errors = append(c.errors, problem.FieldError{Field: field, Rule: rule, Param: param, Message: message})
+}
+
+func (c *checker) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = c.schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (c *checker) check(schema *Schema, value interface{}, field string) {
+	schema = c.resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	if value == nil {
+		if !schema.Nullable && (schema.Type != "" || len(schema.AllOf) > 0 || len(schema.AnyOf) > 0) {
+			c.fail(field, "nullable", "")
+		}
+		return
+	}
+
+	for _, part := range schema.AllOf {
+		c.check(part, value, field)
+	}
+
+	// The failures of the last option are reported when none of them match
+	if len(schema.AnyOf) > 0 {
+		var failures []problem.FieldError
+		for _, option := range schema.AnyOf {
+			optionChecker := &checker{schemas: c.schemas, trans: c.trans}
+			optionChecker.check(option, value, field)
+
+			if len(optionChecker.errors) == 0 {
+				failures = nil
+				break
+			}
+			failures = optionChecker.errors
+		}
+		c.errors = append(c.errors, failures...)
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		values := make([]string, 0, len(schema.Enum))
+		for _, allowed := range schema.Enum {
+			values = append(values, fmt.Sprint(allowed))
+		}
+		c.fail(field, "enum", strings.Join(values, " "))
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			c.fail(field, "type", schema.Type)
+			return
+		}
+		c.checkObject(schema, object, field)
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			c.fail(field, "type", schema.Type)
+			return
+		}
+		if schema.MinItems != nil && len(array) < *schema.MinItems {
+			c.fail(field, "minItems", strconv.Itoa(*schema.MinItems))
+		}
+		if schema.MaxItems != nil && len(array) > *schema.MaxItems {
+			c.fail(field, "maxItems", strconv.Itoa(*schema.MaxItems))
+		}
+		for i, item := range array {
+			c.check(schema.Items, item, field+"["+strconv.Itoa(i)+"]")
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			c.fail(field, "type", schema.Type)
+			return
+		}
+		c.checkString(schema, text, field)
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			c.fail(field, "type", schema.Type)
+			return
+		}
+		parsed, err := number.Float64()
+		if err != nil || (schema.Type == "integer" && parsed != float64(int64(parsed))) {
+			c.fail(field, "type", schema.Type)
+			return
+		}
+		c.checkNumber(schema, parsed, field)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			c.fail(field, "type", schema.Type)
+		}
+	}
+}
+
+func (c *checker) checkObject(schema *Schema, object map[string]interface{}, field string) {
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			c.fail(join(field, name), "required", "")
+		}
+	}
+
+	if schema.MinProperties != nil && len(object) < *schema.MinProperties {
+		c.fail(field, "minProperties", strconv.Itoa(*schema.MinProperties))
+	}
+	if schema.MaxProperties != nil && len(object) > *schema.MaxProperties {
+		c.fail(field, "maxProperties", strconv.Itoa(*schema.MaxProperties))
+	}
+
+	// Sorted so problems list the fields in the same order every time
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property := object[name]
+		if propertySchema, ok := schema.Properties[name]; ok {
+			c.check(propertySchema, property, join(field, name))
+		} else if schema.AdditionalProperties != nil {
+			c.check(schema.AdditionalProperties, property, join(field, name))
+		}
+	}
+}
+
+func (c *checker) checkString(schema *Schema, text string, field string) {
+	length := utf8.RuneCountInString(text)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		c.fail(field, "minLength", strconv.Itoa(*schema.MinLength))
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		c.fail(field, "maxLength", strconv.Itoa(*schema.MaxLength))
+	}
+
+	if schema.Pattern != "" && !compiled(schema.Pattern).MatchString(text) {
+		c.fail(field, "pattern", schema.Pattern)
+	}
+
+	if schema.Format != "" && !validFormat(schema.Format, text) {
+		c.fail(field, "format", schema.Format)
+	}
+}
+
+func (c *checker) checkNumber(schema *Schema, number float64, field string) {
+	if schema.Minimum != nil {
+		if schema.ExclusiveMinimum && number <= *schema.Minimum {
+			c.fail(field, "exclusiveMinimum", formatNumber(*schema.Minimum))
+		} else if number < *schema.Minimum {
+			c.fail(field, "minimum", formatNumber(*schema.Minimum))
+		}
+	}
+
+	if schema.Maximum != nil {
+		if schema.ExclusiveMaximum && number >= *schema.Maximum {
+			c.fail(field, "exclusiveMaximum", formatNumber(*schema.Maximum))
+		} else if number > *schema.Maximum {
+			c.fail(field, "maximum", formatNumber(*schema.Maximum))
+		}
+	}
+}
+
+// validFormat checks the formats the document uses, unknown formats are accepted
+func validFormat(format string, text string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, text)
+		return err == nil
+	case "email":
+		address, err := mail.ParseAddress(text)
+		return err == nil && address.Address == text
+	case "uri":
+		parsed, err := url.ParseRequestURI(text)
+		return err == nil && parsed.Scheme != ""
+	case "uuid":
+		return uuidPattern.MatchString(text)
+	case "byte":
+		_, err := base64.StdEncoding.DecodeString(text)
+		return err == nil
+	}
+	return true
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compiled(pattern string) *regexp.Regexp {
+	if cached, ok := patterns.Load(pattern); ok {
+		return cached.(*regexp.Regexp)
+	}
+
+	expression := regexp.MustCompile(pattern)
+	patterns.Store(pattern, expression)
+	return expression
+}
+
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+// join names a property the way validation problems do, options.color for the color key of options
+func join(field string, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
@@ -0,0 +1,120 @@
+package payment
+
+import (
+	"github.com/goccy/go-json"
+	"sync"
+	"time"
+)
+
+// FakeProvider keeps transactions in memory so checkout can be used locally and in tests
+// without a real gateway. Payments are completed by calling Pay.
+type FakeProvider struct {
+	Secret  string
+	BaseURL string
+
+	lock         sync.Mutex
+	transactions map[string]Transaction
+	// refunded is how much of each transaction has been refunded, a transaction can be refunded in parts
+	refunded map[string]float32
+}
+
+func NewFakeProvider(secret string, baseURL string) *FakeProvider {
+	return &FakeProvider{
+		Secret:       secret,
+		BaseURL:      baseURL,
+		transactions: map[string]Transaction{},
+		refunded:     map[string]float32{},
+	}
+}
+
+func (provider *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (provider *FakeProvider) Initialize(request InitializeRequest) (Transaction, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	transaction := Transaction{
+		Reference:        request.Reference,
+		Status:           TransactionPending,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		AuthorizationUrl: provider.BaseURL + "/" + request.Reference,
+	}
+	provider.transactions[request.Reference] = transaction
+	return transaction, nil
+}
+
+func (provider *FakeProvider) Verify(reference string) (Transaction, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	transaction, ok := provider.transactions[reference]
+	if !ok {
+		return Transaction{}, ErrTransactionNotFound
+	}
+	return transaction, nil
+}
+
+func (provider *FakeProvider) Refund(reference string, amount float32) (Refund, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	transaction, ok := provider.transactions[reference]
+	if !ok {
+		return Refund{}, ErrTransactionNotFound
+	}
+
+	if transaction.Status != TransactionSuccess || amount <= 0 || provider.refunded[reference]+amount > transaction.Amount {
+		return Refund{}, ErrNotRefundable
+	}
+
+	provider.refunded[reference] += amount
+	if provider.refunded[reference] >= transaction.Amount {
+		transaction.Status = TransactionRefunded
+		provider.transactions[reference] = transaction
+	}
+	return Refund{Reference: reference, Amount: amount, CreatedAt: time.Now()}, nil
+}
+
+func (provider *FakeProvider) ParseWebhook(payload []byte, signature string) (Event, error) {
+	if !VerifySignature(provider.Secret, payload, signature) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	event := Event{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// Pay completes a pending transaction the way a customer would on the gateway and
+// returns the signed webhook the gateway would send for it
+func (provider *FakeProvider) Pay(reference string) ([]byte, string, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	transaction, ok := provider.transactions[reference]
+	if !ok {
+		return nil, "", ErrTransactionNotFound
+	}
+
+	if transaction.Status == TransactionPending {
+		now := time.Now()
+		transaction.Status = TransactionSuccess
+		transaction.PaidAt = &now
+		provider.transactions[reference] = transaction
+	}
+
+	payload, err := json.Marshal(Event{
+		Type:      EventChargeSuccess,
+		Reference: reference,
+		Amount:    transaction.Amount,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, Sign(provider.Secret, payload), nil
+}
@@ -0,0 +1,71 @@
+package payment
+
+import (
+	"errors"
+	"time"
+)
+
+type TransactionStatus string
+
+const (
+	TransactionPending  TransactionStatus = "pending"
+	TransactionSuccess  TransactionStatus = "success"
+	TransactionFailed   TransactionStatus = "failed"
+	TransactionRefunded TransactionStatus = "refunded"
+)
+
+type EventType string
+
+const (
+	EventChargeSuccess EventType = "charge.success"
+	EventChargeFailed  EventType = "charge.failed"
+	EventRefunded      EventType = "refund.processed"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrInvalidSignature    = errors.New("invalid webhook signature")
+	ErrNotRefundable       = errors.New("transaction cannot be refunded")
+)
+
+type InitializeRequest struct {
+	Reference   string
+	Amount      float32
+	Currency    string
+	Email       string
+	CallbackUrl string
+}
+
+type Transaction struct {
+	Reference string            `json:"reference"`
+	Status    TransactionStatus `json:"status"`
+	Amount    float32           `json:"amount"`
+	Currency  string            `json:"currency"`
+	// AuthorizationUrl is where the customer completes the payment
+	AuthorizationUrl string     `json:"authorizationUrl"`
+	PaidAt           *time.Time `json:"paidAt"`
+}
+
+type Refund struct {
+	Reference string    `json:"reference"`
+	Amount    float32   `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Event is a webhook notification sent by a provider once its signature has been checked
+type Event struct {
+	Type      EventType `json:"event"`
+	Reference string    `json:"reference"`
+	Amount    float32   `json:"amount"`
+}
+
+// PaymentProvider is implemented by every payment gateway orders can be paid with.
+// Amounts are in the major unit of the currency, providers convert them when needed.
+type PaymentProvider interface {
+	Name() string
+	Initialize(request InitializeRequest) (Transaction, error)
+	Verify(reference string) (Transaction, error)
+	Refund(reference string, amount float32) (Refund, error)
+	// ParseWebhook checks the signature of a webhook payload before decoding it
+	ParseWebhook(payload []byte, signature string) (Event, error)
+}
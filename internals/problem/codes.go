@@ -0,0 +1,110 @@
+package problem
+
+import "github.com/gofiber/fiber/v2"
+
+// Code identifies a kind of error, codes never change once published
+type Code string
+
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeInvalidPayload       Code = "invalid_payload"
+	CodeInvalidParameter     Code = "invalid_parameter"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeUnprocessable        Code = "unprocessable_entity"
+	CodeRateLimited          Code = "rate_limited"
+	CodeInternal             Code = "internal_error"
+	CodeInvalidResponse      Code = "invalid_response"
+	CodeBadGateway           Code = "bad_gateway"
+	CodeUnavailable          Code = "service_unavailable"
+)
+
+const (
+	CodeMerchantRequired   Code = "merchant_required"
+	CodeInvalidAPIKey      Code = "invalid_api_key"
+	CodeInvalidMemberToken Code = "invalid_member_token"
+	CodeInvalidSignature   Code = "invalid_signature"
+	CodeInsufficientScope  Code = "insufficient_scope"
+	CodeInsufficientRole   Code = "insufficient_role"
+	CodeAPIKeyNotAllowed   Code = "api_key_not_allowed"
+)
+
+const (
+	CodeProductNotFound       Code = "product_not_found"
+	CodeVariantNotFound       Code = "variant_not_found"
+	CodeCategoryNotFound      Code = "category_not_found"
+	CodeImageNotFound         Code = "image_not_found"
+	CodeReservationNotFound   Code = "reservation_not_found"
+	CodePriceScheduleNotFound Code = "price_schedule_not_found"
+	CodeCartNotFound          Code = "cart_not_found"
+	CodeCartItemNotFound      Code = "cart_item_not_found"
+	CodeOrderNotFound         Code = "order_not_found"
+	CodeTransactionNotFound   Code = "transaction_not_found"
+	CodeAPIKeyNotFound        Code = "api_key_not_found"
+	CodeMemberNotFound        Code = "member_not_found"
+	CodeInviteNotFound        Code = "invite_not_found"
+	CodePromotionNotFound     Code = "promotion_not_found"
+	CodeCouponNotFound        Code = "coupon_not_found"
+)
+
+const (
+	CodeVariantExists            Code = "variant_exists"
+	CodeMemberExists             Code = "member_exists"
+	CodeCouponExists             Code = "coupon_exists"
+	CodeCategoryCycle            Code = "category_cycle"
+	CodeCategoryHasChildren      Code = "category_has_children"
+	CodeInsufficientStock        Code = "insufficient_stock"
+	CodeScheduleOverlap          Code = "schedule_overlap"
+	CodeScheduleFinished         Code = "schedule_finished"
+	CodeInvalidTransition        Code = "invalid_transition"
+	CodeCouponUnavailable        Code = "coupon_unavailable"
+	CodeAPIKeyRevoked            Code = "api_key_revoked"
+	CodeCartEmpty                Code = "cart_empty"
+	CodePaymentIncomplete        Code = "payment_incomplete"
+	CodePaymentFailed            Code = "payment_failed"
+	CodeUnknownJurisdiction      Code = "unknown_jurisdiction"
+	CodeIdempotencyKeyInProgress Code = "idempotency_key_in_progress"
+	CodeIdempotencyKeyReused     Code = "idempotency_key_reused"
+	CodeIdempotencyKeyTooLong    Code = "idempotency_key_too_long"
+)
+
+// codeForStatus is the generic code of errors that only carry a status, such as Fiber's own errors
+func codeForStatus(status int) Code {
+	switch status {
+	case fiber.StatusBadRequest:
+		return CodeBadRequest
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound:
+		return CodeNotFound
+	case fiber.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case fiber.StatusConflict:
+		return CodeConflict
+	case fiber.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case fiber.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case fiber.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case fiber.StatusTooManyRequests:
+		return CodeRateLimited
+	case fiber.StatusBadGateway:
+		return CodeBadGateway
+	case fiber.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+
+	if status < fiber.StatusInternalServerError {
+		return CodeBadRequest
+	}
+	return CodeInternal
+}
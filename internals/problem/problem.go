@@ -0,0 +1,78 @@
+package problem
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"net/http"
+)
+
+// ContentType is the media type of RFC 7807 problem details
+const ContentType = "application/problem+json"
+
+// FieldError describes one field of a request body that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details error. Handlers return it and Handler writes it.
+// Code is a machine-readable identifier clients can switch on, Detail is meant for people.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Code     Code         `json:"code"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+func New(status int, code Code, detail string) *Problem {
+	return &Problem{
+		Type:   "/problems/" + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+func (problem *Problem) Error() string {
+	return string(problem.Code) + ": " + problem.Detail
+}
+
+func Validation(detail string, errors []FieldError) *Problem {
+	problem := New(fiber.StatusBadRequest, CodeValidationFailed, detail)
+	problem.Errors = errors
+	return problem
+}
+
+// From turns any error into a problem. Fiber errors keep their status, anything else is an internal error.
+func From(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	var fiberError *fiber.Error
+	if errors.As(err, &fiberError) {
+		return New(fiberError.Code, codeForStatus(fiberError.Code), fiberError.Message)
+	}
+
+	return New(fiber.StatusInternalServerError, CodeInternal, "Something went wrong")
+}
+
+// Handler is the Fiber ErrorHandler, it writes every error returned by a handler as problem+json
+func Handler(ctx *fiber.Ctx, err error) error {
+	problem := *From(err)
+	problem.Instance = ctx.OriginalURL()
+
+	if problem.Status >= fiber.StatusInternalServerError {
+		log.Error(ctx.Method(), " ", ctx.OriginalURL(), ": ", err)
+	}
+
+	return ctx.Status(problem.Status).JSON(problem, ContentType)
+}
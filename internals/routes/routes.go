@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/swagger"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/openapi"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+)
+
+// Config holds the settings main reads from the environment that change the routes
+type Config struct {
+	// UploadDir is served under /uploads, nothing is served when it is empty
+	UploadDir string
+	// FakePayments adds the route that completes payments of the fake provider
+	FakePayments bool
+}
+
+// Register adds every route of the API to app, global middleware is left to the caller.
+// Routes added here must be documented in the openapi package.
+func Register(app *fiber.App, config Config) {
+	products := app.Group("/product", middleware.RequireScope(models.ScopeProductsRead, models.ScopeProductsWrite))
+	products.Get("/", handlers.GetAllProductsEndpoint)
+	products.Get("/export", handlers.ExportProductsEndpoint)
+	products.Get("/events", handlers.ProductEventsEndpoint)
+	products.Get("/stock/low", handlers.GetLowStockEndpoint)
+	products.Get("/:id", handlers.FindAProductEndpoint)
+	products.Get("/:id/history", handlers.GetProductHistoryEndpoint)
+	products.Get("/:id/prices/history", handlers.GetPriceHistoryEndpoint)
+	products.Get("/:id/prices/schedules", handlers.GetPriceSchedulesEndpoint)
+	products.Post("/:id/prices/schedules", handlers.CreatePriceScheduleEndpoint)
+	products.Delete("/:id/prices/schedules/:scheduleId", handlers.CancelPriceScheduleEndpoint)
+	products.Post("/", handlers.CreateProductEndpoint)
+	products.Put("/:id", handlers.UpdateProductEndpoint)
+	products.Delete("/:id", handlers.DeleteProductEndpoint)
+	products.Post("/:id/categories", handlers.AssignProductCategoriesEndpoint)
+	products.Delete("/:id/categories/:categoryId", handlers.RemoveProductCategoryEndpoint)
+	products.Get("/:id/variants", handlers.GetProductVariantsEndpoint)
+	products.Get("/:id/variants/:variantId", handlers.FindAVariantEndpoint)
+	products.Post("/:id/variants", handlers.CreateVariantEndpoint)
+	products.Put("/:id/variants/:variantId", handlers.UpdateVariantEndpoint)
+	products.Delete("/:id/variants/:variantId", handlers.DeleteVariantEndpoint)
+	products.Get("/:id/stock", handlers.GetProductStockEndpoint)
+	products.Put("/:id/stock", handlers.UpdateStockSettingsEndpoint)
+	products.Post("/:id/stock/increment", handlers.IncrementStockEndpoint)
+	products.Post("/:id/stock/decrement", handlers.DecrementStockEndpoint)
+	products.Post("/:id/reservations", handlers.CreateReservationEndpoint)
+	products.Delete("/:id/reservations/:reservationId", handlers.ReleaseReservationEndpoint)
+	products.Post("/:id/reservations/:reservationId/commit", handlers.CommitReservationEndpoint)
+	products.Get("/:id/images", handlers.GetProductImagesEndpoint)
+	products.Post("/:id/images", handlers.UploadProductImagesEndpoint)
+	products.Put("/:id/images/order", handlers.ReorderProductImagesEndpoint)
+	products.Delete("/:id/images/:imageId", handlers.DeleteProductImageEndpoint)
+
+	categories := app.Group("/category", middleware.RequireScope(models.ScopeProductsRead, models.ScopeProductsWrite))
+	categories.Get("/", handlers.GetAllCategoriesEndpoint)
+	categories.Get("/:id", handlers.FindACategoryEndpoint)
+	categories.Post("/", handlers.CreateCategoryEndpoint)
+	categories.Put("/:id", handlers.UpdateCategoryEndpoint)
+	categories.Delete("/:id", handlers.DeleteCategoryEndpoint)
+
+	taxes := app.Group("/tax", middleware.RequireScope(models.ScopeProductsRead, models.ScopeProductsWrite))
+	taxes.Get("/rules", handlers.GetTaxRulesEndpoint)
+	taxes.Get("/settings", handlers.GetTaxSettingsEndpoint)
+	taxes.Put("/settings", handlers.UpdateTaxSettingsEndpoint)
+
+	carts := app.Group("/cart")
+	carts.Post("/", handlers.CreateCartEndpoint)
+	carts.Get("/:id", handlers.GetCartEndpoint)
+	carts.Delete("/:id", handlers.DeleteCartEndpoint)
+	carts.Post("/:id/items", handlers.AddCartItemEndpoint)
+	carts.Put("/:id/items/:itemId", handlers.UpdateCartItemEndpoint)
+	carts.Delete("/:id/items/:itemId", handlers.RemoveCartItemEndpoint)
+	carts.Post("/:id/coupons", handlers.AddCartCouponEndpoint)
+	carts.Delete("/:id/coupons/:code", handlers.RemoveCartCouponEndpoint)
+
+	promotions := app.Group("/promotion", middleware.RequireScope(models.ScopeProductsRead, models.ScopeProductsWrite))
+	promotions.Get("/", handlers.GetPromotionsEndpoint)
+	promotions.Post("/", handlers.CreatePromotionEndpoint)
+	promotions.Get("/:id", handlers.FindAPromotionEndpoint)
+	promotions.Delete("/:id", handlers.DeletePromotionEndpoint)
+
+	orders := app.Group("/order", middleware.RequireScope(models.ScopeOrdersRead, models.ScopeOrdersWrite))
+	orders.Get("/", handlers.GetMerchantOrdersEndpoint)
+	orders.Post("/", handlers.PlaceOrderEndpoint)
+	orders.Get("/:id", handlers.GetOrderEndpoint)
+	orders.Post("/:id/transitions", handlers.TransitionOrderEndpoint)
+	orders.Post("/:id/payment", handlers.CheckoutOrderEndpoint)
+
+	apiKeys := app.Group("/api-key", middleware.DenyAPIKeys)
+	apiKeys.Get("/", handlers.GetAPIKeysEndpoint)
+	apiKeys.Post("/", handlers.CreateAPIKeyEndpoint)
+	apiKeys.Delete("/:id", handlers.RevokeAPIKeyEndpoint)
+	apiKeys.Post("/:id/rotate", handlers.RotateAPIKeyEndpoint)
+
+	team := app.Group("/team", middleware.DenyAPIKeys)
+	team.Get("/members", handlers.GetTeamMembersEndpoint)
+	team.Post("/members", handlers.InviteMemberEndpoint)
+	team.Put("/members/:id", handlers.UpdateMemberEndpoint)
+	team.Delete("/members/:id", handlers.RemoveMemberEndpoint)
+	team.Post("/invites/accept", handlers.AcceptInviteEndpoint)
+
+	app.Get("/audit", middleware.DenyAPIKeys, handlers.GetAuditLogEndpoint)
+
+	payments := app.Group("/payment")
+	payments.Post("/webhook", handlers.PaymentWebhookEndpoint)
+
+	if config.FakePayments {
+		payments.Post("/fake/:reference", handlers.FakePaymentEndpoint)
+	}
+
+	app.Post("/graphql", handlers.GraphQLEndpoint)
+
+	if config.UploadDir != "" {
+		app.Static("/uploads", config.UploadDir)
+	}
+
+	app.Get("/openapi.json", openapi.Handler)
+	app.Get("/swagger/*", swagger.New(swagger.Config{URL: "/openapi.json"}))
+
+	app.Get("/", welcomeToApi)
+	app.Use(notFound)
+}
+
+func welcomeToApi(ctx *fiber.Ctx) error {
+	return ctx.Status(200).JSON(types.WelcomeResponse{
+		Message: "Welcome to the ShopAnythingLagos",
+		Live:    true,
+	})
+}
+
+func notFound(ctx *fiber.Ctx) error {
+	return problem.New(404, problem.CodeNotFound, "Route not found")
+}
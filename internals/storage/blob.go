@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps binary objects such as product images under a key
+type BlobStore interface {
+	Put(key string, reader io.Reader, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// URL returns where clients can download the blob
+	URL(key string) string
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"bytes"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testKeyProductId = "4e4e4e4e-0000-4000-8000-000000000001"
+
+func apiKeyRequest(app *fiber.App, method string, route string, secret string, token string, body interface{}) (int, types.APIKeyResponse) {
+	req := httptest.NewRequest(method, route, nil)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, route, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if secret != "" {
+		req.Header.Set(middleware.APIKeyHeader, secret)
+	}
+	if token != "" {
+		req.Header.Set(middleware.MemberTokenHeader, token)
+	}
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0, types.APIKeyResponse{}
+	}
+
+	key := types.APIKeyResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&key)
+	return resp.StatusCode, key
+}
+
+func Test_apiKeys(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(middleware.APIKeyAuth)
+	app.Use(middleware.MemberAuth)
+	products := app.Group("/products", middleware.RequireScope(models.ScopeProductsRead, models.ScopeProductsWrite))
+	products.Get("/:id", handlers.FindAProductEndpoint)
+	products.Put("/:id", handlers.UpdateProductEndpoint)
+	apiKeys := app.Group("/api-key", middleware.DenyAPIKeys)
+	apiKeys.Get("/", handlers.GetAPIKeysEndpoint)
+	apiKeys.Post("/", handlers.CreateAPIKeyEndpoint)
+	apiKeys.Delete("/:id", handlers.RevokeAPIKeyEndpoint)
+	apiKeys.Post("/:id/rotate", handlers.RotateAPIKeyEndpoint)
+
+	skuId := testSkuId("keySkuId")
+
+	models.SaveProduct(models.Product{
+		Id:          testKeyProductId,
+		SkuId:       skuId,
+		Name:        "Leather sandals",
+		Description: "Handmade sandals",
+		Price:       7000,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+
+	defer models.DeleteProduct(testKeyProductId)
+
+	manager := joinAsManager(t, skuId)
+	defer func() {
+		for _, key := range models.MerchantAPIKeys(skuId) {
+			_, _ = models.RevokeAPIKey(key.Id)
+		}
+	}()
+
+	productRoute := "/products/" + testKeyProductId
+	update := map[string]interface{}{"name": "Leather slippers"}
+
+	status, _ := apiKeyRequest(app, "POST", "/api-key?skuId="+testSkuId("keyUnclaimedSkuId"), "", "", map[string]interface{}{"name": "ERP", "scopes": []string{"products:read"}})
+	assert.Equal(t, 401, status, "Create a key with the skuId alone")
+
+	status, _ = apiKeyRequest(app, "POST", "/api-key", "", manager, map[string]interface{}{"name": "ERP", "scopes": []string{"products:delete"}})
+	assert.Equal(t, 400, status, "Create a key with an unknown scope")
+
+	status, readOnly := apiKeyRequest(app, "POST", "/api-key", "", manager, map[string]interface{}{"name": "Reports", "scopes": []string{"products:read"}})
+	assert.Equal(t, 201, status, "Create a read only key")
+	assert.NotEmpty(t, readOnly.Secret, "The secret is returned once")
+	assert.NotEqual(t, readOnly.Secret, models.APIKeyData[readOnly.APIKey.Id].Hash, "The secret is stored hashed")
+
+	_, writer := apiKeyRequest(app, "POST", "/api-key", "", manager, map[string]interface{}{"name": "ERP", "scopes": []string{"products:read", "products:write"}})
+
+	status, _ = apiKeyRequest(app, "GET", productRoute, "sal_not_a_key", "", nil)
+	assert.Equal(t, 401, status, "Use a key that does not exist")
+
+	status, _ = apiKeyRequest(app, "GET", productRoute, readOnly.Secret, "", nil)
+	assert.Equal(t, 200, status, "Read with a read only key")
+
+	status, _ = apiKeyRequest(app, "PUT", productRoute, readOnly.Secret, "", update)
+	assert.Equal(t, 403, status, "Write with a read only key")
+
+	status, _ = apiKeyRequest(app, "PUT", productRoute+"?skuId="+skuId, "", "", update)
+	assert.Equal(t, 401, status, "Write with the skuId alone once the merchant has keys")
+
+	status, _ = apiKeyRequest(app, "PUT", productRoute, writer.Secret, "", update)
+	assert.Equal(t, 200, status, "Write with a key holding products:write, the key stands in for skuId")
+
+	used, _ := models.FindAPIKeyById(writer.APIKey.Id)
+	assert.NotNil(t, used.LastUsedAt, "The last use of a key is recorded")
+
+	status, _ = apiKeyRequest(app, "GET", "/api-key", writer.Secret, "", nil)
+	assert.Equal(t, 403, status, "Keys cannot manage keys")
+
+	status, _ = apiKeyRequest(app, "POST", "/api-key/"+writer.APIKey.Id+"/rotate?skuId=otherSkuId", "", "", nil)
+	assert.Equal(t, 403, status, "Rotate the key of another merchant")
+
+	status, rotated := apiKeyRequest(app, "POST", "/api-key/"+writer.APIKey.Id+"/rotate", "", manager, nil)
+	assert.Equal(t, 200, status, "Rotate a key")
+	assert.NotEqual(t, writer.Secret, rotated.Secret)
+
+	status, _ = apiKeyRequest(app, "GET", productRoute, writer.Secret, "", nil)
+	assert.Equal(t, 401, status, "The previous secret stops working after a rotation")
+
+	status, _ = apiKeyRequest(app, "GET", productRoute, rotated.Secret, "", nil)
+	assert.Equal(t, 200, status, "The new secret works after a rotation")
+
+	status, _ = apiKeyRequest(app, "DELETE", "/api-key/"+readOnly.APIKey.Id, "", manager, nil)
+	assert.Equal(t, 200, status, "Revoke a key")
+
+	status, _ = apiKeyRequest(app, "GET", productRoute, readOnly.Secret, "", nil)
+	assert.Equal(t, 401, status, "Revoked keys are rejected")
+
+	req := httptest.NewRequest("GET", "/api-key", nil)
+	req.Header.Set(middleware.MemberTokenHeader, manager)
+	resp, _ := app.Test(req, 1000)
+	listed := types.GetAPIKeysResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&listed)
+	assert.Len(t, listed.APIKeys, 2, "List the keys of the merchant")
+}
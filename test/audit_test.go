@@ -0,0 +1,112 @@
+package test
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func auditRequest(app *fiber.App, method string, route string, token string, body string) (int, types.GetAuditResponse, string) {
+	req := httptest.NewRequest(method, route, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set(middleware.MemberTokenHeader, token)
+	}
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0, types.GetAuditResponse{}, ""
+	}
+
+	audit := types.GetAuditResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&audit)
+	return resp.StatusCode, audit, resp.Header.Get(fiber.HeaderXRequestID)
+}
+
+func Test_audit(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(requestid.New())
+	app.Use(middleware.MemberAuth)
+	products := app.Group("/products")
+	products.Post("/", handlers.CreateProductEndpoint)
+	products.Put("/:id", handlers.UpdateProductEndpoint)
+	products.Delete("/:id", handlers.DeleteProductEndpoint)
+	products.Get("/:id/history", handlers.GetProductHistoryEndpoint)
+	app.Get("/audit", handlers.GetAuditLogEndpoint)
+	team := app.Group("/team")
+	team.Post("/members", handlers.InviteMemberEndpoint)
+	team.Post("/invites/accept", handlers.AcceptInviteEndpoint)
+
+	skuId, otherSkuId := testSkuId("auditSkuId"), testSkuId("auditOtherSkuId")
+
+	req := httptest.NewRequest("POST", "/products?skuId="+skuId, strings.NewReader(`{"name":"Clay pot","description":"A pot","price":1200}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req, 1000)
+	created := types.OneProductResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&created)
+	productId := created.Product.Id
+
+	_, manager := joinTeam(t, app, skuId, "", "manager@example.com", models.RoleManager)
+	_, editor := joinTeam(t, app, skuId, manager, "editor@example.com", models.RoleEditor)
+	defer func() {
+		for _, member := range models.MerchantMembers(skuId) {
+			models.RemoveMember(member.Id)
+		}
+	}()
+
+	status, _, requestId := auditRequest(app, "PUT", "/products/"+productId, editor, `{"price":1500}`)
+	assert.Equal(t, 200, status, "An editor changes the price")
+
+	status, _, _ = auditRequest(app, "GET", "/products/"+productId+"/history", editor, "")
+	assert.Equal(t, 403, status, "Editors cannot read the audit log")
+
+	status, _, _ = auditRequest(app, "GET", "/products/"+productId+"/history?skuId="+skuId, "", "")
+	assert.Equal(t, 403, status, "The skuId alone cannot read the history once the team has members")
+
+	status, _, _ = auditRequest(app, "GET", "/products/"+productId+"/history?skuId="+otherSkuId, "", "")
+	assert.Equal(t, 403, status, "Other merchants cannot read the history")
+
+	status, history, _ := auditRequest(app, "GET", "/products/"+productId+"/history", manager, "")
+	assert.Equal(t, 200, status, "Get the history of a product")
+	assert.Len(t, history.Entries, 2)
+
+	update := history.Entries[0]
+	assert.Equal(t, handlers.AuditProductUpdate, update.Action, "The newest entry comes first")
+	assert.Equal(t, "member", update.Actor.Type)
+	assert.Equal(t, "editor@example.com", update.Actor.Email)
+	assert.Equal(t, requestId, update.RequestId, "The entry keeps the request id")
+	assert.Equal(t, []models.FieldChange{{Field: "price", Before: float64(1200), After: float64(1500)}}, update.Changes)
+
+	assert.Equal(t, handlers.AuditProductCreate, history.Entries[1].Action)
+	assert.Equal(t, "merchant", history.Entries[1].Actor.Type)
+
+	auditRequest(app, "DELETE", "/products/"+productId, manager, "")
+
+	status, history, _ = auditRequest(app, "GET", "/products/"+productId+"/history", manager, "")
+	assert.Equal(t, 200, status, "Deleted products keep their history")
+	assert.Equal(t, handlers.AuditProductDelete, history.Entries[0].Action)
+
+	status, _, _ = auditRequest(app, "GET", "/products/dada/history", manager, "")
+	assert.Equal(t, 404, status, "History of a product that never existed")
+
+	_, audit, _ := auditRequest(app, "GET", "/audit?action=product.update", manager, "")
+	assert.Len(t, audit.Entries, 1, "Filter the audit log by action")
+
+	_, audit, _ = auditRequest(app, "GET", "/audit?actorId="+skuId, manager, "")
+	assert.Len(t, audit.Entries, 1, "Filter the audit log by actor")
+
+	_, audit, _ = auditRequest(app, "GET", "/audit?skuId="+otherSkuId, "", "")
+	assert.Len(t, audit.Entries, 0, "Merchants only see their own changes")
+
+	status, _, _ = auditRequest(app, "GET", "/audit?from=yesterday", manager, "")
+	assert.Equal(t, 400, status, "Filter with an invalid time")
+}
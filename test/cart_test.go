@@ -0,0 +1,118 @@
+package test
+
+import (
+	"bytes"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testCartProductId = "ca0ca0ca-0000-4000-8000-000000000001"
+var testCartProductId2 = "ca0ca0ca-0000-4000-8000-000000000002"
+
+func cartRequest(app *fiber.App, method string, route string, body interface{}) (int, types.CartResponse) {
+	req := httptest.NewRequest(method, route, nil)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, route, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0, types.CartResponse{}
+	}
+
+	cart := types.CartResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&cart)
+	return resp.StatusCode, cart
+}
+
+func Test_cart(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	carts := app.Group("/cart")
+	carts.Post("/", handlers.CreateCartEndpoint)
+	carts.Get("/:id", handlers.GetCartEndpoint)
+	carts.Delete("/:id", handlers.DeleteCartEndpoint)
+	carts.Post("/:id/items", handlers.AddCartItemEndpoint)
+	carts.Put("/:id/items/:itemId", handlers.UpdateCartItemEndpoint)
+	carts.Delete("/:id/items/:itemId", handlers.RemoveCartItemEndpoint)
+
+	models.SaveProduct(models.Product{
+		Id:        testCartProductId,
+		SkuId:     "someSkuId",
+		Name:      "Ankara fabric",
+		Price:     1500,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	models.SaveProduct(models.Product{
+		Id:        testCartProductId2,
+		SkuId:     "someSkuId2",
+		Name:      "Palm oil",
+		Price:     2000,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+
+	defer models.DeleteProduct(testCartProductId)
+	defer models.DeleteProduct(testCartProductId2)
+
+	status, created := cartRequest(app, "POST", "/cart", nil)
+	assert.Equal(t, 201, status, "Create a cart")
+	cartRoute := "/cart/" + created.Cart.Id
+
+	status, _ = cartRequest(app, "POST", cartRoute+"/items", map[string]interface{}{"productId": "dada", "quantity": 1})
+	assert.Equal(t, 404, status, "Add a product that does not exist")
+
+	status, _ = cartRequest(app, "POST", cartRoute+"/items", map[string]interface{}{"productId": testCartProductId, "quantity": 0})
+	assert.Equal(t, 400, status, "Add an item without a quantity")
+
+	cartRequest(app, "POST", cartRoute+"/items", map[string]interface{}{"productId": testCartProductId, "quantity": 2})
+	status, cart := cartRequest(app, "POST", cartRoute+"/items", map[string]interface{}{"productId": testCartProductId, "quantity": 1})
+	assert.Equal(t, 200, status, "Add the same product again")
+	assert.Len(t, cart.Cart.Items, 1, "Adding the same product merges the lines")
+	assert.Equal(t, 3, cart.Cart.Items[0].Quantity)
+
+	status, cart = cartRequest(app, "POST", cartRoute+"/items", map[string]interface{}{"productId": testCartProductId2, "quantity": 1})
+	assert.Equal(t, 200, status, "Add another product")
+	assert.Equal(t, float32(6500), cart.Cart.Subtotal)
+	assert.Equal(t, 4, cart.Cart.ItemCount)
+
+	fabricLine := cart.Cart.Items[0].Id
+	status, cart = cartRequest(app, "PUT", cartRoute+"/items/"+fabricLine, map[string]interface{}{"quantity": 1})
+	assert.Equal(t, 200, status, "Update the quantity of a line")
+	assert.Equal(t, float32(3500), cart.Cart.Subtotal)
+
+	models.UpdateProduct(testCartProductId, func(product *models.Product) {
+		product.Price = 1800
+	})
+	_, cart = cartRequest(app, "GET", cartRoute, nil)
+	assert.Equal(t, float32(1800), cart.Cart.Items[0].UnitPrice, "The cart is repriced on read")
+	assert.Equal(t, float32(3800), cart.Cart.Subtotal)
+
+	models.DeleteProduct(testCartProductId2)
+	_, cart = cartRequest(app, "GET", cartRoute, nil)
+	assert.Len(t, cart.Cart.Items, 1, "Deleted products are dropped from the cart")
+	assert.Len(t, cart.Cart.RemovedItems, 1, "Deleted products are reported")
+
+	_, cart = cartRequest(app, "GET", cartRoute, nil)
+	assert.Len(t, cart.Cart.RemovedItems, 0, "Deleted products are only reported once")
+
+	status, cart = cartRequest(app, "DELETE", cartRoute+"/items/"+fabricLine, nil)
+	assert.Equal(t, 200, status, "Remove a line")
+	assert.Len(t, cart.Cart.Items, 0)
+
+	expired := models.ExpireCarts(time.Now().Add(models.CartInactivityTimeout + time.Minute))
+	assert.GreaterOrEqual(t, expired, 1, "Inactive carts expire")
+
+	status, _ = cartRequest(app, "GET", cartRoute, nil)
+	assert.Equal(t, 404, status, "An expired cart cannot be fetched")
+}
@@ -0,0 +1,243 @@
+package test
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/util"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var categoryClothing = "0a1f2c3d-0000-4000-8000-000000000001"
+var categoryShirts = "0a1f2c3d-0000-4000-8000-000000000002"
+var categoryShoes = "0a1f2c3d-0000-4000-8000-000000000003"
+
+func seedCategories() {
+	models.CategoryData[categoryClothing] = models.Category{
+		Id:        categoryClothing,
+		SkuId:     "someSkuId",
+		Name:      "Clothing",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	models.CategoryData[categoryShirts] = models.Category{
+		Id:        categoryShirts,
+		SkuId:     "someSkuId",
+		Name:      "Shirts",
+		ParentId:  categoryClothing,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	models.CategoryData[categoryShoes] = models.Category{
+		Id:        categoryShoes,
+		SkuId:     "someSkuId",
+		Name:      "Shoes",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func Test_categories(t *testing.T) {
+	tests := []struct {
+		description  string
+		method       string
+		route        string
+		expectedCode int
+		contains     []string
+		excludes     []string
+		body         map[string]interface{}
+	}{
+		{
+			description:  "Create a category under a parent that does not exist",
+			method:       "POST",
+			route:        "/category?skuId=someSkuId",
+			expectedCode: 404,
+			contains: []string{
+				`"detail":"Parent category not found"`,
+			},
+			body: map[string]interface{}{
+				"name":     "Trousers",
+				"parentId": "dada",
+			},
+		},
+		{
+			description:  "Create a category under a parent",
+			method:       "POST",
+			route:        "/category?skuId=someSkuId",
+			expectedCode: 201,
+			contains: []string{
+				`"message":"Category created successfully"`,
+				`"parentId":"` + categoryClothing + `"`,
+			},
+			body: map[string]interface{}{
+				"name":     "Trousers",
+				"parentId": categoryClothing,
+			},
+		},
+		{
+			description:  "Move a category below its own child",
+			method:       "PUT",
+			route:        "/category/" + categoryClothing + "?skuId=someSkuId",
+			expectedCode: 409,
+			contains: []string{
+				`"detail":"A category cannot be moved below itself or one of its children"`,
+			},
+			body: map[string]interface{}{
+				"parentId": categoryShirts,
+			},
+		},
+		{
+			description:  "Rename a category to a blank name",
+			method:       "PUT",
+			route:        "/category/" + categoryShirts + "?skuId=someSkuId",
+			expectedCode: 400,
+			contains: []string{
+				`"code":"validation_failed"`,
+			},
+			body: map[string]interface{}{
+				"name": "   ",
+			},
+		},
+		{
+			description:  "Delete a category that has children",
+			method:       "DELETE",
+			route:        "/category/" + categoryClothing + "?skuId=someSkuId",
+			expectedCode: 409,
+			contains: []string{
+				`"detail":"Category has children, delete or move them first"`,
+			},
+		},
+		{
+			description:  "Get a category with its children",
+			method:       "GET",
+			route:        "/category/" + categoryClothing,
+			expectedCode: 200,
+			contains: []string{
+				`"name":"Shirts"`,
+				`"name":"Trousers"`,
+			},
+		},
+		{
+			description:  "Assign a product to a category that does not exist",
+			method:       "POST",
+			route:        "/products/" + testId1 + "/categories?skuId=someSkuId",
+			expectedCode: 404,
+			contains: []string{
+				`"detail":"Category not found: dada"`,
+			},
+			body: map[string]interface{}{
+				"categoryIds": "dada",
+			},
+		},
+		{
+			description:  "Assign a product to a child category",
+			method:       "POST",
+			route:        "/products/" + testId1 + "/categories?skuId=someSkuId",
+			expectedCode: 200,
+			contains: []string{
+				`"categoryIds":["` + categoryShirts + `"]`,
+			},
+			body: map[string]interface{}{
+				"categoryIds": categoryShirts,
+			},
+		},
+		{
+			description:  "Filter products by a parent category includes its descendants",
+			method:       "GET",
+			route:        "/products?category=" + categoryClothing,
+			expectedCode: 200,
+			contains: []string{
+				`"name":"Categorised shirt"`,
+			},
+			excludes: []string{
+				`"name":"Uncategorised car"`,
+			},
+		},
+		{
+			description:  "Filter products by an unrelated category",
+			method:       "GET",
+			route:        "/products?category=" + categoryShoes,
+			expectedCode: 200,
+			contains: []string{
+				`"products":null`,
+			},
+		},
+		{
+			description:  "Remove a product from a category",
+			method:       "DELETE",
+			route:        "/products/" + testId1 + "/categories/" + categoryShirts + "?skuId=someSkuId",
+			expectedCode: 200,
+			contains: []string{
+				`"categoryIds":[]`,
+			},
+		},
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	products := app.Group("/products")
+	products.Get("/", handlers.GetAllProductsEndpoint)
+	products.Post("/:id/categories", handlers.AssignProductCategoriesEndpoint)
+	products.Delete("/:id/categories/:categoryId", handlers.RemoveProductCategoryEndpoint)
+
+	categories := app.Group("/category")
+	categories.Get("/:id", handlers.FindACategoryEndpoint)
+	categories.Post("/", handlers.CreateCategoryEndpoint)
+	categories.Put("/:id", handlers.UpdateCategoryEndpoint)
+	categories.Delete("/:id", handlers.DeleteCategoryEndpoint)
+
+	seedCategories()
+
+	models.ProductData[testId1] = models.Product{
+		Id:          testId1,
+		SkuId:       "someSkuId",
+		Name:        "Categorised shirt",
+		Description: "A product description",
+		Price:       100.00,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	models.ProductData[testId2] = models.Product{
+		Id:          testId2,
+		SkuId:       "someSkuId",
+		Name:        "Uncategorised car",
+		Description: "A product description",
+		Price:       100.00,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.route, nil)
+
+		if test.body != nil {
+			req = httptest.NewRequest(test.method, test.route, strings.NewReader(util.EncodeMapToString(test.body)))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+
+		resp, err := app.Test(req, 1000)
+
+		if err != nil {
+			t.Errorf("error testing route %s: %v", test.route, err)
+			continue
+		}
+
+		read, _ := io.ReadAll(resp.Body)
+
+		for _, contain := range test.contains {
+			assert.Containsf(t, string(read), contain, test.description)
+		}
+
+		for _, exclude := range test.excludes {
+			assert.NotContainsf(t, string(read), exclude, test.description)
+		}
+
+		assert.Equalf(t, test.expectedCode, resp.StatusCode, test.description)
+	}
+}
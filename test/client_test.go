@@ -0,0 +1,195 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/client"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/openapi"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/internals/routes"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// appTransport sends the requests of a client to a fiber app without a server
+type appTransport struct {
+	app *fiber.App
+}
+
+func (transport appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return transport.app.Test(req, -1)
+}
+
+func newTestClient(app *fiber.App, options ...client.Option) *client.Client {
+	options = append([]client.Option{
+		client.WithHTTPClient(&http.Client{Transport: appTransport{app}}),
+		client.WithRetries(3, time.Millisecond),
+	}, options...)
+	return client.New("http://sal.test", options...)
+}
+
+func Test_client(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(middleware.APIKeyAuth)
+	app.Use(middleware.MemberAuth)
+	app.Use(middleware.Idempotency(time.Hour))
+	app.Use(openapi.Validation(openapi.ValidationConfig{Responses: true}))
+	routes.Register(app, routes.Config{})
+
+	ctx := context.Background()
+	sal := newTestClient(app, client.WithSkuId("clientSkuId"))
+
+	created, err := sal.CreateProduct(ctx, types.ProductCreatePayload{Name: "Client lamp", Description: "A lamp", Price: 1500})
+	assert.NoError(t, err, "Create a product")
+	assert.Equal(t, "Client lamp", created.Product.Name, "Create a product")
+	assert.Equal(t, "clientSkuId", created.Product.SkuId, "The merchant comes from WithSkuId")
+	defer models.DeleteProduct(created.Product.Id)
+
+	found, err := sal.GetProduct(ctx, created.Product.Id)
+	assert.NoError(t, err, "Get a product")
+	assert.Equal(t, created.Product.Id, found.Product.Id, "Get a product")
+
+	updated, err := sal.UpdateProduct(ctx, created.Product.Id, types.ProductUpdatePayload{Price: 1800})
+	assert.NoError(t, err, "Update a product")
+	assert.Equal(t, float32(1800), updated.Product.Price, "Update a product")
+	assert.Equal(t, "Client lamp", updated.Product.Name, "Empty fields are not changed")
+
+	stock, err := sal.IncrementStock(ctx, created.Product.Id, types.StockAdjustPayload{Quantity: 3})
+	assert.NoError(t, err, "Increment the stock")
+	assert.Equal(t, 3, stock.Stock.Stock, "Increment the stock")
+
+	_, err = sal.CreateProduct(ctx, types.ProductCreatePayload{Name: "Client lamp", Description: "A lamp"})
+	assert.True(t, client.HasCode(err, client.CodeValidationFailed), "Validation errors are typed", err)
+
+	_, err = sal.GetProduct(ctx, "missing")
+	assert.True(t, client.HasCode(err, client.CodeProductNotFound), "Not found errors are typed", err)
+	assert.True(t, client.IsNotFound(err), "Not found errors are typed")
+	assert.EqualError(t, err, "sal: product_not_found: Product not found", "Errors read like the problem")
+
+	_, err = newTestClient(app).CreateProduct(ctx, types.ProductCreatePayload{Name: "Client lamp", Description: "A lamp", Price: 1500})
+	assert.True(t, client.HasCode(err, client.CodeMerchantRequired), "A client without a merchant", err)
+
+	deleted, err := sal.DeleteProduct(ctx, created.Product.Id)
+	assert.NoError(t, err, "Delete a product")
+	assert.NotEmpty(t, deleted.Message, "Delete a product")
+}
+
+func Test_clientEachProduct(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	routes.Register(app, routes.Config{})
+
+	ctx := context.Background()
+	sal := newTestClient(app, client.WithSkuId("clientPagesSkuId"))
+
+	for i := 0; i < 5; i++ {
+		created, err := sal.CreateProduct(ctx, types.ProductCreatePayload{Name: fmt.Sprintf("Pagedclient %d", i), Description: "Paged", Price: 100})
+		assert.NoError(t, err, "Create a product")
+		defer models.DeleteProduct(created.Product.Id)
+	}
+
+	options := client.ListProductsOptions{Search: "Pagedclient", Limit: 2}
+
+	page, err := sal.ListProducts(ctx, options)
+	assert.NoError(t, err, "List a page")
+	assert.Len(t, page.Products, 2, "List a page")
+	assert.Equal(t, 3, page.Meta.TotalPages, "List a page")
+
+	names := []string{}
+	err = sal.EachProduct(ctx, options, func(product types.ProductView) error {
+		names = append(names, product.Name)
+		return nil
+	})
+	assert.NoError(t, err, "Iterate every page")
+	assert.Len(t, names, 5, "Iterate every page")
+
+	stop := fmt.Errorf("stop")
+	count := 0
+	err = sal.EachProduct(ctx, options, func(product types.ProductView) error {
+		count++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop, "The callback stops the iteration")
+	assert.Equal(t, 1, count, "The callback stops the iteration")
+}
+
+func Test_clientRetries(t *testing.T) {
+	tests := []struct {
+		description string
+		failures    int
+		status      int
+		retryAfter  string
+		// expectedAttempts is how many requests reach the app
+		expectedAttempts int
+		expectedError    bool
+	}{
+		{"Retry a 503", 2, 503, "", 3, false},
+		{"Retry a 429 after Retry-After", 1, 429, "0", 2, false},
+		{"Give up after the last retry", 10, 502, "", 4, true},
+		{"Do not retry a 400", 1, 400, "", 1, true},
+	}
+
+	for _, test := range tests {
+		attempts := 0
+		keys := map[string]bool{}
+
+		app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+		app.Post("/product", func(ctx *fiber.Ctx) error {
+			attempts++
+			keys[ctx.Get(middleware.IdempotencyKeyHeader)] = true
+
+			if attempts <= test.failures {
+				if test.retryAfter != "" {
+					ctx.Set("Retry-After", test.retryAfter)
+				}
+				return fiber.NewError(test.status, http.StatusText(test.status))
+			}
+			return ctx.Status(201).JSON(types.OneProductResponse{Message: "Product created successfully"})
+		})
+
+		_, err := newTestClient(app).CreateProduct(context.Background(), types.ProductCreatePayload{Name: "Retried", Description: "Retried", Price: 1})
+
+		assert.Equal(t, test.expectedAttempts, attempts, test.description)
+		assert.Equal(t, test.expectedError, err != nil, test.description, err)
+		assert.Len(t, keys, 1, test.description+": every attempt carries the same Idempotency-Key")
+	}
+}
+
+// codeConstants reads the Code constants declared in a file
+func codeConstants(t *testing.T, path string) map[string]string {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := map[string]string{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok || len(spec.Values) != 1 {
+			return true
+		}
+		if kind, ok := spec.Type.(*ast.Ident); ok && kind.Name == "Code" {
+			codes[spec.Names[0].Name] = spec.Values[0].(*ast.BasicLit).Value
+		}
+		return true
+	})
+	return codes
+}
+
+func Test_clientConstants(t *testing.T) {
+	assert.Equal(t, middleware.APIKeyHeader, client.APIKeyHeader, "The client sends the API key header the API reads")
+	assert.Equal(t, middleware.MemberTokenHeader, client.MemberTokenHeader, "The client sends the member token header the API reads")
+	assert.Equal(t, middleware.IdempotencyKeyHeader, client.IdempotencyKeyHeader, "The client sends the idempotency header the API reads")
+
+	codes := codeConstants(t, "../internals/problem/codes.go")
+	assert.NotEmpty(t, codes, "Read the codes of the API")
+	assert.Equal(t, codes, codeConstants(t, "../client/problem.go"), "The client has every code of the API")
+}
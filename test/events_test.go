@@ -0,0 +1,183 @@
+package test
+
+import (
+	"bufio"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/internals/routes"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type productEventMessage struct {
+	id    string
+	event string
+	data  string
+}
+
+// listenTestApp serves app on a local port, app.Test waits for the whole body so it cannot read a stream
+func listenTestApp(t *testing.T, app *fiber.App) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = app.Listener(listener) }()
+	t.Cleanup(func() { _ = app.ShutdownWithTimeout(time.Second) })
+
+	return "http://" + listener.Addr().String()
+}
+
+func openProductEvents(t *testing.T, url string, lastEventId string) (*http.Response, *bufio.Reader) {
+	req, _ := http.NewRequest("GET", url, nil)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// readProductEvent reads the next message of a stream, skipping comments
+func readProductEvent(reader *bufio.Reader) productEventMessage {
+	message := productEventMessage{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return message
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && message.data != "":
+			return message
+		case strings.HasPrefix(line, "id: "):
+			message.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			message.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			message.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func Test_productEvents(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler, DisableStartupMessage: true})
+	routes.Register(app, routes.Config{})
+	url := listenTestApp(t, app) + "/product/events?skuId=eventsSkuId"
+
+	resp, stream := openProductEvents(t, url, "")
+	assert.Equal(t, 200, resp.StatusCode, "Open the stream")
+	assert.Equal(t, "text/event-stream", resp.Header.Get(fiber.HeaderContentType), "Open the stream")
+
+	other := models.Product{Id: "6d6d6d6d-0000-4000-8000-000000000001", SkuId: "eventsOtherSkuId", Name: "Other", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	models.SaveProduct(other)
+	defer models.DeleteProduct(other.Id)
+
+	product := models.Product{Id: "6d6d6d6d-0000-4000-8000-000000000002", SkuId: "eventsSkuId", Name: "Streamed", Price: 250, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	models.SaveProduct(product)
+	_, err := models.AdjustStock(product.Id, "", 3)
+	assert.NoError(t, err, "Adjust the stock")
+	models.DeleteProduct(product.Id)
+
+	tests := []struct {
+		description string
+		event       string
+		stock       int
+	}{
+		{description: "A product is created", event: "product.created", stock: 0},
+		{description: "Its stock changes", event: "product.updated", stock: 3},
+		{description: "It is deleted", event: "product.deleted", stock: 3},
+	}
+
+	var ids []string
+	for _, test := range tests {
+		message := readProductEvent(stream)
+		assert.Equal(t, test.event, message.event, test.description)
+		ids = append(ids, message.id)
+
+		data := types.ProductEventData{}
+		assert.NoError(t, json.Unmarshal([]byte(message.data), &data), test.description)
+		assert.Equal(t, product.Id, data.Product.Id, "Only the products of the merchant")
+		assert.Equal(t, test.stock, data.Product.Stock, test.description)
+		assert.Equal(t, float32(250), data.Product.DiscountedPrice, "The product as GET /product/:id returns it")
+	}
+
+	// Other tests change products too, ids are checked against the first one this test received
+	epoch, first, found := strings.Cut(ids[0], "-")
+	assert.True(t, found, "Ids start with the epoch of the server")
+	assert.Equal(t, models.ProductEventEpoch, epoch, "Ids start with the epoch of the server")
+	firstId, _ := strconv.ParseUint(first, 10, 64)
+	for i, id := range ids {
+		assert.Equal(t, eventId(firstId+uint64(i)), id, "Ids increase by one with every change")
+	}
+
+	// Resuming sends the changes after Last-Event-ID, then the new ones
+	_, resumed := openProductEvents(t, url, ids[0])
+	assert.Equal(t, ids[1], readProductEvent(resumed).id, "Resume after the first change")
+	assert.Equal(t, ids[2], readProductEvent(resumed).id, "Resume after the first change")
+
+	models.SaveProduct(product)
+	defer models.DeleteProduct(product.Id)
+	message := readProductEvent(resumed)
+	assert.Equal(t, "product.created", message.event, "New changes follow the missed ones")
+	assert.Equal(t, eventId(firstId+3), message.id, "New changes follow the missed ones")
+	assert.Equal(t, message.id, readProductEvent(stream).id, "Every stream gets the same ids")
+	ids = append(ids, message.id)
+
+	resets := []struct {
+		description string
+		lastEventId string
+	}{
+		{description: "Resume after a change that is not kept", lastEventId: eventId(firstId + 1000000)},
+		{description: "Resume with an id of an earlier run of the server", lastEventId: "0-" + strings.TrimPrefix(ids[0], epoch+"-")},
+		{description: "Resume with an id from before epochs", lastEventId: strings.TrimPrefix(ids[0], epoch+"-")},
+	}
+
+	var reset *bufio.Reader
+	for _, test := range resets {
+		_, reset = openProductEvents(t, url, test.lastEventId)
+		assert.Equal(t, "reset", readProductEvent(reset).event, test.description)
+
+		// Every change kept is sent again, the ones of the merchant end with those of this test
+		var sent []string
+		for len(sent) == 0 || sent[len(sent)-1] != ids[len(ids)-1] {
+			message := readProductEvent(reset)
+			if message.id == "" {
+				break
+			}
+			sent = append(sent, message.id)
+		}
+		if assert.GreaterOrEqual(t, len(sent), len(ids), test.description) {
+			assert.Equal(t, ids, sent[len(sent)-len(ids):], test.description)
+		}
+	}
+
+	models.UpdateProduct(product.Id, func(product *models.Product) {
+		product.Name = "Restreamed"
+	})
+	message = readProductEvent(reset)
+	assert.Equal(t, "product.updated", message.event, "New changes follow a reset")
+	assert.Equal(t, eventId(firstId+4), message.id, "New changes follow a reset")
+	assert.Equal(t, message.id, readProductEvent(stream).id, "New changes follow a reset")
+
+	resp, _ = openProductEvents(t, url, "latest")
+	assert.Equal(t, 400, resp.StatusCode, "Resume with an invalid Last-Event-ID")
+}
+
+// eventId is the id of the change with the number id in the stream of this server
+func eventId(id uint64) string {
+	return models.ProductEventEpoch + "-" + strconv.FormatUint(id, 10)
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_exportProducts(t *testing.T) {
+	tests := []struct {
+		description  string
+		route        string
+		expectedCode int
+		contentType  string
+		contains     []string
+		excludes     []string
+	}{
+		{
+			description:  "Export products with an unknown format",
+			route:        "/products/export?format=xlsx",
+			expectedCode: 400,
+			contains: []string{
+				`"detail":"Invalid format please use one of csv, ndjson or json"`,
+			},
+		},
+		{
+			description:  "Export products as csv by default",
+			route:        "/products/export?search=exported",
+			expectedCode: 200,
+			contentType:  "text/csv; charset=utf-8",
+			contains: []string{
+				"id,skuId,name,description,price,createdAt,updatedAt\n",
+				testId1 + ",exportSkuId,Exported chair,\"A chair, with a comma\",25.5,",
+				testId2 + ",exportSkuId,Exported table,A table,80,",
+			},
+		},
+		{
+			description:  "Export products as ndjson honoring the search param",
+			route:        "/products/export?format=ndjson&search=chair",
+			expectedCode: 200,
+			contentType:  "application/x-ndjson",
+			contains: []string{
+				`"name":"Exported chair"`,
+			},
+			excludes: []string{
+				`"name":"Exported table"`,
+			},
+		},
+		{
+			description:  "Export products as json honoring the sort params",
+			route:        "/products/export?format=json&search=exported&sortKey=price&sortOrder=desc",
+			expectedCode: 200,
+			contentType:  "application/json; charset=utf-8",
+			contains: []string{
+				`[{"skuId":"exportSkuId","name":"Exported table"`,
+				`},{"skuId":"exportSkuId","name":"Exported chair"`,
+			},
+		},
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	products := app.Group("/products")
+	products.Get("/export", handlers.ExportProductsEndpoint)
+
+	models.ProductData[testId1] = models.Product{
+		Id:          testId1,
+		SkuId:       "exportSkuId",
+		Name:        "Exported chair",
+		Description: "A chair, with a comma",
+		Price:       25.5,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	models.ProductData[testId2] = models.Product{
+		Id:          testId2,
+		SkuId:       "exportSkuId",
+		Name:        "Exported table",
+		Description: "A table",
+		Price:       80,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	defer delete(models.ProductData, testId1)
+	defer delete(models.ProductData, testId2)
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.route, nil)
+
+		resp, err := app.Test(req, 1000)
+
+		if err != nil {
+			t.Errorf("error testing route %s: %v", test.route, err)
+			continue
+		}
+
+		read, _ := io.ReadAll(resp.Body)
+
+		for _, contain := range test.contains {
+			assert.Containsf(t, string(read), contain, test.description)
+		}
+
+		for _, exclude := range test.excludes {
+			assert.NotContainsf(t, string(read), exclude, test.description)
+		}
+
+		if test.contentType != "" {
+			assert.Equalf(t, test.contentType, resp.Header.Get("Content-Type"), test.description)
+			assert.Truef(t, strings.HasPrefix(resp.Header.Get("Content-Disposition"), "attachment; filename=\"products-"), test.description)
+		}
+
+		assert.Equalf(t, test.expectedCode, resp.StatusCode, test.description)
+	}
+}
@@ -0,0 +1,192 @@
+package test
+
+import (
+	"bytes"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/openapi"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/internals/routes"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testGraphQLCategoryId = "6a6a6a6a-0000-4000-8000-000000000001"
+
+func graphqlRequest(app *fiber.App, route string, query string, variables map[string]interface{}, headers ...string) (int, types.GraphQLResponse) {
+	data, _ := json.Marshal(types.GraphQLRequest{Query: query, Variables: variables})
+	req := httptest.NewRequest("POST", route, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0, types.GraphQLResponse{}
+	}
+
+	response := types.GraphQLResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&response)
+	return resp.StatusCode, response
+}
+
+// graphqlField walks the data of a response along keys and list indexes
+func graphqlField(data interface{}, path ...interface{}) interface{} {
+	for _, key := range path {
+		switch key := key.(type) {
+		case string:
+			object, _ := data.(map[string]interface{})
+			data = object[key]
+		case int:
+			list, _ := data.([]interface{})
+			if key >= len(list) {
+				return nil
+			}
+			data = list[key]
+		}
+	}
+	return data
+}
+
+// graphqlExtension reads the extensions of the first error of a response
+func graphqlExtension(response types.GraphQLResponse, path ...interface{}) interface{} {
+	if len(response.Errors) == 0 {
+		return nil
+	}
+	return graphqlField(response.Errors[0].Extensions, path...)
+}
+
+func Test_graphqlQueries(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(openapi.Validation(openapi.ValidationConfig{Responses: true}))
+	routes.Register(app, routes.Config{})
+
+	models.SaveCategory(models.Category{Id: testGraphQLCategoryId, SkuId: "graphqlSkuId", Name: "Lamps", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	defer models.DeleteCategory(testGraphQLCategoryId)
+
+	for i, name := range []string{"Graphlamp desk", "Graphlamp floor", "Graphlamp wall"} {
+		product := models.Product{
+			Id:          "6b6b6b6b-0000-4000-8000-00000000000" + string(rune('1'+i)),
+			SkuId:       "graphqlSkuId",
+			Name:        name,
+			Description: "A lamp",
+			Price:       float32(1000*(i+1)) + 0.99,
+			CreatedAt:   time.Now().Add(time.Duration(i) * time.Second),
+			UpdatedAt:   time.Now(),
+		}
+		if i == 0 {
+			product.CategoryIds = []string{testGraphQLCategoryId}
+		}
+		models.SaveProduct(product)
+		defer models.DeleteProduct(product.Id)
+	}
+
+	status, response := graphqlRequest(app, "/graphql", `{
+		products(search: "graphlamp", sortKey: price, sortOrder: asc, limit: 2) {
+			products { name price merchant { skuId } categories { name } }
+			meta { currentPage totalPages totalProducts }
+		}
+	}`, nil)
+	assert.Equal(t, 200, status, "Search, sort and page the products")
+	assert.Empty(t, response.Errors, "Search, sort and page the products")
+	assert.Equal(t, "Graphlamp desk", graphqlField(response.Data, "products", "products", 0, "name"), "Sorted by price")
+	assert.Equal(t, 1000.99, graphqlField(response.Data, "products", "products", 0, "price"), "Prices keep their decimals")
+	assert.Equal(t, "graphqlSkuId", graphqlField(response.Data, "products", "products", 0, "merchant", "skuId"), "The merchant of a product")
+	assert.Equal(t, "Lamps", graphqlField(response.Data, "products", "products", 0, "categories", 0, "name"), "The categories of a product")
+	assert.Len(t, graphqlField(response.Data, "products", "products"), 2, "Limited to 2")
+	assert.Equal(t, float64(2), graphqlField(response.Data, "products", "meta", "totalPages"), "Paged like GET /product")
+	assert.Equal(t, float64(3), graphqlField(response.Data, "products", "meta", "totalProducts"), "Paged like GET /product")
+	assert.Nil(t, graphqlField(response.Data, "products", "products", 0, "description"), "Only the fields asked for")
+
+	_, response = graphqlRequest(app, "/graphql", `query($skuId: String!) {
+		merchant(skuId: $skuId) {
+			products(page: 2, limit: 2) { products { name } }
+			categories { name products { meta { totalProducts } } }
+		}
+	}`, map[string]interface{}{"skuId": "graphqlSkuId"})
+	assert.Empty(t, response.Errors, "Query a merchant")
+	assert.Equal(t, "Graphlamp desk", graphqlField(response.Data, "merchant", "products", "products", 0, "name"), "Products default to the newest first")
+	assert.Equal(t, float64(1), graphqlField(response.Data, "merchant", "categories", 0, "products", "meta", "totalProducts"), "The products of a category")
+
+	_, response = graphqlRequest(app, "/graphql", `{ product(id: "6b6b6b6b-0000-4000-8000-000000000002") { name discountedPrice taxClass } missing: product(id: "missing") { name } }`, nil)
+	assert.Empty(t, response.Errors, "Get a product")
+	assert.Equal(t, "Graphlamp floor", graphqlField(response.Data, "product", "name"), "Get a product")
+	assert.Equal(t, 2000.99, graphqlField(response.Data, "product", "discountedPrice"), "Get a product")
+	assert.Contains(t, response.Data, "missing", "A missing product is null")
+	assert.Nil(t, response.Data["missing"], "A missing product is null")
+
+	_, response = graphqlRequest(app, "/graphql", `{ products(limit: 0) { meta { limit } } }`, nil, "Accept-Language", "fr")
+	assert.Len(t, response.Errors, 1, "Page arguments follow the rules of GET /product")
+	assert.Equal(t, "validation_failed", graphqlExtension(response, "code"), "Page arguments follow the rules of GET /product")
+	assert.Equal(t, "limit", graphqlExtension(response, "errors", 0, "field"), "Page arguments follow the rules of GET /product")
+
+	_, response = graphqlRequest(app, "/graphql", `{ products { unknown } }`, nil)
+	assert.Len(t, response.Errors, 1, "Query a field that does not exist")
+	assert.Nil(t, response.Data, "Query a field that does not exist")
+
+	status, _ = specRequest(app, "POST", "/graphql", "application/json", `{"variables":{}}`)
+	assert.Equal(t, 400, status, "A request without a query")
+}
+
+func Test_graphqlMutations(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(middleware.APIKeyAuth)
+	app.Use(middleware.MemberAuth)
+	routes.Register(app, routes.Config{})
+
+	merchant := "/graphql?skuId=graphqlMutationSkuId"
+
+	_, response := graphqlRequest(app, merchant, `mutation($input: ProductCreateInput!) {
+		createProduct(input: $input) { id name price taxClass merchant { skuId } }
+	}`, map[string]interface{}{"input": map[string]interface{}{"name": "Graph rug", "description": "A rug", "price": 4500}})
+	assert.Empty(t, response.Errors, "Create a product")
+	id, _ := graphqlField(response.Data, "createProduct", "id").(string)
+	defer models.DeleteProduct(id)
+	assert.Equal(t, "standard", graphqlField(response.Data, "createProduct", "taxClass"), "The tax class defaults to standard")
+	assert.Equal(t, "graphqlMutationSkuId", graphqlField(response.Data, "createProduct", "merchant", "skuId"), "The product belongs to the merchant")
+
+	_, response = graphqlRequest(app, merchant, `mutation { createProduct(input: {name: "Graph rug", description: "A rug", price: -1}) { id } }`, nil)
+	assert.Len(t, response.Errors, 1, "Create a product with an invalid price")
+	assert.Equal(t, "validation_failed", graphqlExtension(response, "code"), "Inputs follow the rules of the REST payloads")
+	assert.Equal(t, "price", graphqlExtension(response, "errors", 0, "field"), "Inputs follow the rules of the REST payloads")
+	assert.Equal(t, []interface{}{"createProduct"}, response.Errors[0].Path, "Inputs follow the rules of the REST payloads")
+
+	_, response = graphqlRequest(app, "/graphql", `mutation { createProduct(input: {name: "Graph rug", description: "A rug", price: 10}) { id } }`, nil)
+	assert.Equal(t, "merchant_required", graphqlExtension(response, "code"), "Create a product without a merchant")
+
+	_, response = graphqlRequest(app, merchant, `mutation($id: ID!) { updateProduct(id: $id, input: {price: 5000}) { name price } }`, map[string]interface{}{"id": id})
+	assert.Empty(t, response.Errors, "Update a product")
+	assert.Equal(t, "Graph rug", graphqlField(response.Data, "updateProduct", "name"), "Fields left out are not changed")
+	assert.Equal(t, float64(5000), graphqlField(response.Data, "updateProduct", "price"), "Update a product")
+	assert.Equal(t, float32(5000), models.ProductData[id].Price, "Update a product")
+
+	_, response = graphqlRequest(app, "/graphql?skuId=someoneElse", `mutation($id: ID!) { deleteProduct(id: $id) }`, map[string]interface{}{"id": id})
+	assert.Equal(t, "forbidden", graphqlExtension(response, "code"), "Delete the product of another merchant")
+	assert.Equal(t, float64(403), graphqlExtension(response, "status"), "Delete the product of another merchant")
+
+	manager := joinAsManager(t, "graphqlMutationSkuId")
+	status, readOnly := apiKeyRequest(app, "POST", "/api-key", "", manager, map[string]interface{}{"name": "Storefront", "scopes": []string{"products:read"}})
+	assert.Equal(t, 201, status, "Create a read only key")
+	defer models.RevokeAPIKey(readOnly.APIKey.Id)
+
+	_, response = graphqlRequest(app, "/graphql", `{ product(id: "`+id+`") { name } }`, nil, middleware.APIKeyHeader, readOnly.Secret)
+	assert.Empty(t, response.Errors, "Read only keys can query")
+
+	_, response = graphqlRequest(app, "/graphql", `mutation { deleteProduct(id: "`+id+`") }`, nil, middleware.APIKeyHeader, readOnly.Secret)
+	assert.Equal(t, "insufficient_scope", graphqlExtension(response, "code"), "Read only keys cannot change products")
+
+	_, response = graphqlRequest(app, merchant, `mutation($id: ID!) { deleteProduct(id: $id) }`, map[string]interface{}{"id": id})
+	assert.Equal(t, "merchant_required", graphqlExtension(response, "code"), "The skuId alone cannot delete once the merchant has members")
+
+	_, response = graphqlRequest(app, "/graphql", `mutation($id: ID!) { deleteProduct(id: $id) }`, map[string]interface{}{"id": id}, middleware.MemberTokenHeader, manager)
+	assert.Empty(t, response.Errors, "Delete a product")
+	assert.Equal(t, id, graphqlField(response.Data, "deleteProduct"), "Delete a product")
+	_, ok := models.FindProductById(id)
+	assert.False(t, ok, "Delete a product")
+}
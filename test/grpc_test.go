@@ -0,0 +1,178 @@
+package test
+
+import (
+	"context"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	salv1 "github.com/rnwonder/SAL/proto/sal/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestProductClient serves the ProductService in memory and returns a client of it
+func newTestProductClient(t *testing.T) salv1.ProductServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	salv1.RegisterProductServiceServer(server, handlers.NewProductServer())
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return salv1.NewProductServiceClient(conn)
+}
+
+func grpcContext(t *testing.T, pairs ...string) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+// grpcReason reads the problem code of an error from its ErrorInfo detail
+func grpcReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func Test_grpcProducts(t *testing.T) {
+	client := newTestProductClient(t)
+	merchant := grpcContext(t, "sku-id", "grpcSkuId")
+
+	product, err := client.Create(merchant, &salv1.CreateProductRequest{Name: "Grpc kettle", Description: "A kettle", Price: 2500.5})
+	assert.NoError(t, err, "Create a product")
+	defer models.DeleteProduct(product.GetId())
+	assert.Equal(t, "grpcSkuId", product.GetSkuId(), "The product belongs to the merchant")
+	assert.Equal(t, "standard", product.GetTaxClass(), "The tax class defaults to standard")
+	assert.Equal(t, float32(2500.5), product.GetDiscountedPrice(), "The prices of GET /product")
+
+	_, err = client.Create(merchant, &salv1.CreateProductRequest{Name: "Grpc kettle", Description: "A kettle", Price: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "Create a product with an invalid price")
+	assert.Equal(t, "validation_failed", grpcReason(err), "Create a product with an invalid price")
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = badRequest.GetFieldViolations()
+		}
+	}
+	if assert.Len(t, violations, 1, "Field errors are a BadRequest detail") {
+		assert.Equal(t, "price", violations[0].GetField(), "Field errors are a BadRequest detail")
+	}
+
+	_, err = client.Create(grpcContext(t), &salv1.CreateProductRequest{Name: "Grpc kettle", Description: "A kettle", Price: 10})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "Create a product without a merchant")
+	assert.Equal(t, "merchant_required", grpcReason(err), "Create a product without a merchant")
+
+	got, err := client.Get(grpcContext(t), &salv1.GetProductRequest{Id: product.GetId()})
+	assert.NoError(t, err, "Get a product")
+	assert.Equal(t, "Grpc kettle", got.GetName(), "Get a product")
+
+	_, err = client.Get(grpcContext(t), &salv1.GetProductRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err), "Get a missing product")
+	assert.Equal(t, "product_not_found", grpcReason(err), "Get a missing product")
+
+	list, err := client.List(grpcContext(t), &salv1.ListProductsRequest{Search: "grpc kettle", SkuId: "grpcSkuId"})
+	assert.NoError(t, err, "List the products of a merchant")
+	assert.Len(t, list.GetProducts(), 1, "List the products of a merchant")
+	assert.Equal(t, int32(1), list.GetMeta().GetCurrentPage(), "The page defaults to 1")
+	assert.Equal(t, int32(10), list.GetMeta().GetLimit(), "The limit defaults to 10")
+
+	_, err = client.List(grpcContext(t), &salv1.ListProductsRequest{SortKey: "stock"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "List with an unknown sort key")
+
+	updated, err := client.Update(merchant, &salv1.UpdateProductRequest{Id: product.GetId(), Price: 3000})
+	assert.NoError(t, err, "Update a product")
+	assert.Equal(t, "Grpc kettle", updated.GetName(), "Empty fields are not changed")
+	assert.Equal(t, float32(3000), models.ProductData[product.GetId()].Price, "Update a product")
+
+	_, err = client.Delete(grpcContext(t, "sku-id", "someoneElse"), &salv1.DeleteProductRequest{Id: product.GetId()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "Delete the product of another merchant")
+	assert.Equal(t, "forbidden", grpcReason(err), "Delete the product of another merchant")
+
+	key, secret, err := models.CreateAPIKey("grpcSkuId", "Storefront", []string{models.ScopeProductsRead})
+	assert.NoError(t, err, "Create a read only key")
+	defer models.RevokeAPIKey(key.Id)
+	readOnly := grpcContext(t, strings.ToLower(middleware.APIKeyHeader), secret)
+
+	_, err = client.Get(readOnly, &salv1.GetProductRequest{Id: product.GetId()})
+	assert.NoError(t, err, "Read only keys can get products")
+
+	_, err = client.Delete(readOnly, &salv1.DeleteProductRequest{Id: product.GetId()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "Read only keys cannot change products")
+	assert.Equal(t, "insufficient_scope", grpcReason(err), "Read only keys cannot change products")
+
+	_, err = client.Get(grpcContext(t, strings.ToLower(middleware.APIKeyHeader), "sal_revoked"), &salv1.GetProductRequest{Id: product.GetId()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "Call with an invalid API key")
+
+	_, err = client.Delete(merchant, &salv1.DeleteProductRequest{Id: product.GetId()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "The skuId alone cannot delete once the merchant has a key")
+
+	manager := grpcContext(t, strings.ToLower(middleware.MemberTokenHeader), joinAsManager(t, "grpcSkuId"))
+	deleted, err := client.Delete(manager, &salv1.DeleteProductRequest{Id: product.GetId()})
+	assert.NoError(t, err, "Delete a product")
+	assert.Equal(t, product.GetId(), deleted.GetId(), "Delete a product")
+	_, ok := models.FindProductById(product.GetId())
+	assert.False(t, ok, "Delete a product")
+}
+
+func Test_grpcWatch(t *testing.T) {
+	client := newTestProductClient(t)
+
+	stream, err := client.Watch(grpcContext(t), &salv1.WatchProductsRequest{SkuId: "grpcWatchSkuId"})
+	assert.NoError(t, err, "Watch the products of a merchant")
+	// The header is sent once the subscription is in place
+	_, err = stream.Header()
+	assert.NoError(t, err, "Watch the products of a merchant")
+
+	other := models.Product{Id: "6c6c6c6c-0000-4000-8000-000000000001", SkuId: "grpcOtherSkuId", Name: "Other", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	models.SaveProduct(other)
+	defer models.DeleteProduct(other.Id)
+
+	// Changes made outside of gRPC are streamed too
+	product := models.Product{Id: "6c6c6c6c-0000-4000-8000-000000000002", SkuId: "grpcWatchSkuId", Name: "Watched", Price: 100, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	models.SaveProduct(product)
+	_, err = models.AdjustStock(product.Id, "", 5)
+	assert.NoError(t, err, "Adjust the stock")
+	models.DeleteProduct(product.Id)
+
+	tests := []struct {
+		description string
+		eventType   salv1.ProductEvent_Type
+		stock       int32
+	}{
+		{description: "A product is created", eventType: salv1.ProductEvent_TYPE_CREATED, stock: 0},
+		{description: "Its stock changes", eventType: salv1.ProductEvent_TYPE_UPDATED, stock: 5},
+		{description: "It is deleted", eventType: salv1.ProductEvent_TYPE_DELETED, stock: 5},
+	}
+
+	for _, test := range tests {
+		event, err := stream.Recv()
+		if !assert.NoError(t, err, test.description) {
+			return
+		}
+		assert.Equal(t, test.eventType, event.GetType(), test.description)
+		assert.Equal(t, product.Id, event.GetProduct().GetId(), "Only the products of the merchant")
+		assert.Equal(t, test.stock, event.GetProduct().GetStock(), test.description)
+	}
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_idempotency(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(middleware.APIKeyAuth)
+	app.Use(middleware.Idempotency(time.Hour))
+	app.Post("/products", handlers.CreateProductEndpoint)
+
+	skuId := testSkuId("idempotentSkuId")
+	// Stored responses outlive a run of the test, every run sends keys of its own
+	bagKey := "create-bag-" + uuid.NewString()
+
+	send := func(key string, body string, headers ...string) (int, string, string) {
+		req := httptest.NewRequest("POST", "/products?skuId="+skuId, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+
+		resp, err := app.Test(req, 1000)
+		if err != nil {
+			return 0, "", ""
+		}
+		read, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(read), resp.Header.Get(middleware.IdempotencyReplayedHeader)
+	}
+
+	countProducts := func() int {
+		count := 0
+		_ = models.EachProduct(models.ProductFilter{}, func(product models.Product) error {
+			if product.SkuId == skuId {
+				count++
+			}
+			return nil
+		})
+		return count
+	}
+
+	body := `{"name":"Retried bag","description":"A bag","price":100}`
+
+	status, first, replayed := send(bagKey, body)
+	assert.Equal(t, 201, status, "First request with a key")
+	assert.Equal(t, "", replayed)
+
+	status, second, replayed := send(bagKey, body)
+	assert.Equal(t, 201, status, "Retry with the same key")
+	assert.Equal(t, "true", replayed, "The retry is marked as replayed")
+	assert.Equal(t, first, second, "The retry gets the first response")
+	assert.Equal(t, 1, countProducts(), "The retry does not create a duplicate")
+
+	status, _, _ = send(bagKey, `{"name":"Other bag","description":"A bag","price":100}`)
+	assert.Equal(t, 422, status, "Reuse the key with a different body")
+
+	status, _, _ = send(strings.Repeat("k", 256), body)
+	assert.Equal(t, 400, status, "Key that is too long")
+
+	send("", body)
+	assert.Equal(t, 2, countProducts(), "Requests without a key are not deduplicated")
+
+	expired := models.ExpireIdempotencyKeys(time.Now().Add(2 * time.Hour))
+	assert.GreaterOrEqual(t, expired, 1, "Keys expire after their ttl")
+
+	status, _, replayed = send(bagKey, body)
+	assert.Equal(t, 201, status, "An expired key can be used again")
+	assert.Equal(t, "", replayed)
+
+	apiKey, secret, err := models.CreateAPIKey(skuId, "Retries", []string{models.ScopeProductsWrite})
+	assert.NoError(t, err, "Create an API key")
+	defer models.RevokeAPIKey(apiKey.Id)
+
+	status, _, replayed = send(bagKey, body, middleware.APIKeyHeader, secret)
+	assert.Equal(t, 201, status, "Another client sends the same key")
+	assert.Equal(t, "", replayed, "Keys of another client are not replayed")
+
+	status, _, replayed = send(bagKey, body, middleware.APIKeyHeader, secret)
+	assert.Equal(t, "true", replayed, "Keys are replayed for the API key that sent them")
+
+	_ = models.EachProduct(models.ProductFilter{}, func(product models.Product) error {
+		if product.SkuId == skuId {
+			models.DeleteProduct(product.Id)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,178 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/internals/storage"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testImageProductId = "1a1a1a1a-0000-4000-8000-000000000001"
+
+func testPng(width int, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buffer bytes.Buffer
+	_ = png.Encode(&buffer, img)
+	return buffer.Bytes()
+}
+
+// testPngClaiming is a small png whose header claims width by height pixels
+func testPngClaiming(width int, height int) []byte {
+	data := testPng(1, 1)
+	// The IHDR chunk starts at byte 8, its data at byte 16 and its crc covers the type and the data
+	binary.BigEndian.PutUint32(data[16:], uint32(width))
+	binary.BigEndian.PutUint32(data[20:], uint32(height))
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func multipartRequest(route string, files map[string][]byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, data := range files {
+		part, _ := writer.CreateFormFile("image", name)
+		_, _ = part.Write(data)
+	}
+	_ = writer.Close()
+
+	req := httptest.NewRequest("POST", route, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func Test_productImages(t *testing.T) {
+	uploadDir := t.TempDir()
+	store, err := storage.NewLocalStore(uploadDir, "/uploads")
+	assert.NoError(t, err)
+	handlers.ImageStore = store
+
+	app := fiber.New(fiber.Config{BodyLimit: 4 * handlers.MaxImageSize, ErrorHandler: problem.Handler})
+	products := app.Group("/products")
+	products.Get("/:id", handlers.FindAProductEndpoint)
+	products.Delete("/:id", handlers.DeleteProductEndpoint)
+	products.Post("/:id/images", handlers.UploadProductImagesEndpoint)
+	products.Put("/:id/images/order", handlers.ReorderProductImagesEndpoint)
+	products.Delete("/:id/images/:imageId", handlers.DeleteProductImageEndpoint)
+
+	models.SaveProduct(models.Product{
+		Id:          testImageProductId,
+		SkuId:       "someSkuId",
+		Name:        "Framed print",
+		Description: "A framed print",
+		Price:       100.00,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+
+	defer models.DeleteProduct(testImageProductId)
+
+	route := "/products/" + testImageProductId + "/images?skuId=someSkuId"
+
+	resp, _ := app.Test(multipartRequest(route, map[string][]byte{"notes.png": []byte("just some text pretending to be a png")}), 1000)
+	assert.Equal(t, 415, resp.StatusCode, "Upload a file that is not an image")
+
+	resp, _ = app.Test(multipartRequest(route, map[string][]byte{"huge.png": make([]byte, handlers.MaxImageSize+1)}), 5000)
+	assert.Equal(t, 413, resp.StatusCode, "Upload an image over the size limit")
+
+	resp, _ = app.Test(multipartRequest(route, map[string][]byte{"bomb.png": testPngClaiming(20000, 20000)}), 1000)
+	assert.Equal(t, 413, resp.StatusCode, "Upload an image that decodes to too many pixels")
+
+	// The valid image comes first so it is stored before the upload fails
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{{"first.png", testPng(10, 10)}, {"notes.png", []byte("just some text pretending to be a png")}} {
+		part, _ := writer.CreateFormFile("image", file.name)
+		_, _ = part.Write(file.data)
+	}
+	_ = writer.Close()
+	req := httptest.NewRequest("POST", route, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, _ = app.Test(req, 1000)
+	assert.Equal(t, 415, resp.StatusCode, "Upload a valid image and a file that is not an image")
+	assert.Empty(t, models.GetProductImages(testImageProductId), "A failed upload keeps none of its images")
+	stored, _ := filepath.Glob(filepath.Join(uploadDir, "products", testImageProductId, "*"))
+	assert.Empty(t, stored, "A failed upload keeps none of its files")
+
+	resp, _ = app.Test(multipartRequest("/products/"+testImageProductId+"/images?skuId=someSkuId2", map[string][]byte{"a.png": testPng(10, 10)}), 1000)
+	assert.Equal(t, 403, resp.StatusCode, "Upload an image to a product owned by another merchant")
+
+	resp, _ = app.Test(multipartRequest(route, map[string][]byte{"wide.png": testPng(1024, 512)}), 5000)
+	assert.Equal(t, 201, resp.StatusCode, "Upload a large image")
+	first := new(types.GetImagesResponse)
+	_ = json.NewDecoder(resp.Body).Decode(first)
+
+	resp, _ = app.Test(multipartRequest(route, map[string][]byte{"small.png": testPng(10, 10)}), 1000)
+	assert.Equal(t, 201, resp.StatusCode, "Upload a small image")
+	second := new(types.GetImagesResponse)
+	_ = json.NewDecoder(resp.Body).Decode(second)
+
+	assert.Len(t, first.Images, 1)
+	assert.Len(t, second.Images, 1)
+	wide, small := first.Images[0], second.Images[0]
+
+	assert.Equal(t, "image/png", wide.ContentType)
+	assert.Equal(t, 1024, wide.Width)
+	assert.Equal(t, 0, wide.Position)
+	assert.Equal(t, 1, small.Position)
+	assert.True(t, strings.HasPrefix(wide.Url, "/uploads/products/"+testImageProductId+"/"))
+
+	thumbnailFile, err := os.Open(filepath.Join(uploadDir, strings.TrimPrefix(wide.ThumbnailUrl, "/uploads/")))
+	assert.NoError(t, err, "The thumbnail is stored")
+	thumbnail, _, err := image.DecodeConfig(thumbnailFile)
+	thumbnailFile.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, handlers.ThumbnailSize, thumbnail.Width, "The thumbnail is scaled to the thumbnail size")
+	assert.Equal(t, handlers.ThumbnailSize/2, thumbnail.Height, "The thumbnail keeps the aspect ratio")
+
+	order, _ := json.Marshal(map[string]interface{}{"imageIds": []string{small.Id}})
+	req = httptest.NewRequest("PUT", "/products/"+testImageProductId+"/images/order?skuId=someSkuId", bytes.NewReader(order))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req, 1000)
+	assert.Equal(t, 400, resp.StatusCode, "Reorder without listing every image")
+
+	order, _ = json.Marshal(map[string]interface{}{"imageIds": []string{small.Id, wide.Id}})
+	req = httptest.NewRequest("PUT", "/products/"+testImageProductId+"/images/order?skuId=someSkuId", bytes.NewReader(order))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req, 1000)
+	assert.Equal(t, 200, resp.StatusCode, "Reorder the images")
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/products/"+testImageProductId, nil), 1000)
+	read, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(read), `"images":[{"id":"`+small.Id+`"`, "The product response lists the images in order")
+	assert.Contains(t, string(read), `"url":"`+wide.Url+`"`, "The product response includes the image urls")
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/products/"+testImageProductId+"/images/"+wide.Id+"?skuId=someSkuId", nil), 1000)
+	assert.Equal(t, 200, resp.StatusCode, "Delete an image")
+
+	_, err = os.Stat(filepath.Join(uploadDir, strings.TrimPrefix(wide.Url, "/uploads/")))
+	assert.True(t, os.IsNotExist(err), "Deleting an image removes the file")
+
+	remaining := models.GetProductImages(testImageProductId)
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, 0, remaining[0].Position, "Deleting an image closes the gap in the order")
+}
@@ -0,0 +1,206 @@
+package test
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testStockId = "5c0c0a00-0000-4000-8000-000000000001"
+
+func Test_inventory(t *testing.T) {
+	tests := []struct {
+		description  string
+		method       string
+		route        string
+		expectedCode int
+		contains     []string
+		body         map[string]interface{}
+	}{
+		{
+			description:  "Increment stock without auth",
+			method:       "POST",
+			route:        "/products/" + testStockId + "/stock/increment",
+			expectedCode: 401,
+			body: map[string]interface{}{
+				"quantity": 5,
+			},
+		},
+		{
+			description:  "Increment stock",
+			method:       "POST",
+			route:        "/products/" + testStockId + "/stock/increment?skuId=someSkuId",
+			expectedCode: 200,
+			contains: []string{
+				`"stock":5`,
+				`"available":5`,
+			},
+			body: map[string]interface{}{
+				"quantity": 5,
+			},
+		},
+		{
+			description:  "Reserve more than is available",
+			method:       "POST",
+			route:        "/products/" + testStockId + "/reservations",
+			expectedCode: 409,
+			contains: []string{
+				`"detail":"Not enough stock available"`,
+			},
+			body: map[string]interface{}{
+				"quantity": 6,
+			},
+		},
+		{
+			description:  "Reserve stock",
+			method:       "POST",
+			route:        "/products/" + testStockId + "/reservations",
+			expectedCode: 201,
+			contains: []string{
+				`"message":"Stock reserved successfully"`,
+				`"quantity":3`,
+			},
+			body: map[string]interface{}{
+				"quantity": 3,
+			},
+		},
+		{
+			description:  "Decrement below the reserved units",
+			method:       "POST",
+			route:        "/products/" + testStockId + "/stock/decrement?skuId=someSkuId",
+			expectedCode: 409,
+			body: map[string]interface{}{
+				"quantity": 3,
+			},
+		},
+		{
+			description:  "Set the low stock threshold",
+			method:       "PUT",
+			route:        "/products/" + testStockId + "/stock?skuId=someSkuId",
+			expectedCode: 200,
+			contains: []string{
+				`"stock":5`,
+				`"reserved":3`,
+				`"available":2`,
+				`"lowStock":true`,
+			},
+			body: map[string]interface{}{
+				"lowStockThreshold": 2,
+			},
+		},
+		{
+			description:  "List low stock products of the merchant",
+			method:       "GET",
+			route:        "/products/stock/low?skuId=someSkuId",
+			expectedCode: 200,
+			contains: []string{
+				`"productId":"` + testStockId + `"`,
+			},
+		},
+		{
+			description:  "Filter products that are in stock",
+			method:       "GET",
+			route:        "/products?inStock=true&search=stocked",
+			expectedCode: 200,
+			contains: []string{
+				`"name":"Stocked lamp"`,
+			},
+		},
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	products := app.Group("/products")
+	products.Get("/", handlers.GetAllProductsEndpoint)
+	products.Get("/stock/low", handlers.GetLowStockEndpoint)
+	products.Put("/:id/stock", handlers.UpdateStockSettingsEndpoint)
+	products.Post("/:id/stock/increment", handlers.IncrementStockEndpoint)
+	products.Post("/:id/stock/decrement", handlers.DecrementStockEndpoint)
+	products.Post("/:id/reservations", handlers.CreateReservationEndpoint)
+
+	models.SaveProduct(models.Product{
+		Id:          testStockId,
+		SkuId:       "someSkuId",
+		Name:        "Stocked lamp",
+		Description: "A lamp",
+		Price:       100.00,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+
+	defer models.DeleteProduct(testStockId)
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.route, nil)
+
+		if test.body != nil {
+			body, _ := json.Marshal(test.body)
+			req = httptest.NewRequest(test.method, test.route, strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := app.Test(req, 1000)
+
+		if err != nil {
+			t.Errorf("error testing route %s: %v", test.route, err)
+			continue
+		}
+
+		read, _ := io.ReadAll(resp.Body)
+
+		for _, contain := range test.contains {
+			assert.Containsf(t, string(read), contain, test.description)
+		}
+
+		assert.Equalf(t, test.expectedCode, resp.StatusCode, test.description)
+	}
+}
+
+func Test_concurrentReservations(t *testing.T) {
+	models.SaveProduct(models.Product{
+		Id:        testStockId,
+		SkuId:     "someSkuId",
+		Name:      "Limited sneaker",
+		Stock:     10,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+
+	defer models.DeleteProduct(testStockId)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := models.ReserveStock(testStockId, "", 1, time.Minute); err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, reserved, "only the units in stock can be reserved")
+
+	_, err := models.AdjustStock(testStockId, "", -1)
+	assert.ErrorIs(t, err, models.ErrInsufficientStock, "reserved units cannot be decremented")
+
+	expired := models.ExpireReservations(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, 10, expired, "reservations expire after their ttl")
+
+	level, _, _ := models.ProductStockLevels(testStockId)
+	assert.Equal(t, 10, level.Available, "expired reservations give the stock back")
+}
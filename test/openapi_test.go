@@ -0,0 +1,274 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/openapi"
+	"github.com/rnwonder/SAL/internals/payment"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/internals/routes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_openapiRoutes(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	routes.Register(app, routes.Config{UploadDir: t.TempDir(), FakePayments: true})
+
+	document := openapi.Build()
+	registered := map[string]bool{}
+
+	for _, route := range app.GetRoutes(true) {
+		// Static files and the swagger UI are not part of the API
+		if route.Method == fiber.MethodHead || strings.Contains(route.Path, "*") {
+			continue
+		}
+
+		path := route.Path
+		if path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		path = openapi.OpenAPIPath(path)
+		registered[route.Method+" "+path] = true
+
+		item, ok := document.Paths[path]
+		if !assert.True(t, ok, "%s %s is missing from the OpenAPI document", route.Method, route.Path) {
+			continue
+		}
+		assert.NotNil(t, item.Operation(route.Method), "%s %s is missing from the OpenAPI document", route.Method, route.Path)
+	}
+
+	for path, item := range document.Paths {
+		for _, method := range []string{"GET", "PUT", "POST", "DELETE", "PATCH"} {
+			if item.Operation(method) != nil {
+				assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", method, path)
+			}
+		}
+	}
+}
+
+func Test_openapiDocument(t *testing.T) {
+	data := openapi.JSON()
+
+	committed, err := os.ReadFile("../docs/openapi.json")
+	assert.Nil(t, err)
+	assert.Equal(t, string(data)+"\n", string(committed), "docs/openapi.json is out of date, run go run ./cmd/openapi")
+
+	document := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(data, &document))
+	assert.Equal(t, "3.0.3", document["openapi"])
+
+	// Every reference points to a component
+	components := document["components"].(map[string]interface{})
+	for _, match := range regexp.MustCompile(`"\$ref": "#/components/(\w+)/(\w+)"`).FindAllStringSubmatch(string(data), -1) {
+		_, ok := components[match[1]].(map[string]interface{})[match[2]]
+		assert.True(t, ok, "Unknown reference to %s %s", match[1], match[2])
+	}
+
+	schemas := components["schemas"].(map[string]interface{})
+	product := schemas["ProductCreatePayload"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"name", "description", "price"}, product["required"], "Required fields come from the validate tags")
+
+	price := product["properties"].(map[string]interface{})["price"].(map[string]interface{})
+	assert.Equal(t, float64(0), price["minimum"])
+	assert.Equal(t, true, price["exclusiveMinimum"])
+	assert.Equal(t, float64(100000000), price["maximum"])
+
+	view := schemas["ProductView"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Contains(t, view, "skuId", "Embedded structs are flattened")
+	assert.Contains(t, view, "effectivePrice")
+
+	assert.Contains(t, schemas, "Problem", "Errors are documented")
+
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	routes.Register(app, routes.Config{})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/openapi.json", nil), 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	served, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, string(data), string(served))
+}
+
+func specRequest(app *fiber.App, method string, route string, contentType string, body string, headers ...string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(method, route, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0, nil
+	}
+
+	decoded := map[string]interface{}{}
+	_ = json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp.StatusCode, decoded
+}
+
+func problemFields(decoded map[string]interface{}) map[string]string {
+	fields := map[string]string{}
+	errors, _ := decoded["errors"].([]interface{})
+	for _, item := range errors {
+		fieldError := item.(map[string]interface{})
+		fields[fieldError["field"].(string)] = fieldError["rule"].(string)
+	}
+	return fields
+}
+
+func Test_openapiRequestValidation(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(openapi.Validation(openapi.ValidationConfig{}))
+	routes.Register(app, routes.Config{})
+
+	status, decoded := specRequest(app, "POST", "/product?skuId=specSkuId", "application/json", `{"name":"Bag","price":"cheap","taxClass":"luxury"}`)
+	assert.Equal(t, 400, status, "Create a product with a body that does not match the document")
+	assert.Equal(t, "validation_failed", decoded["code"])
+	assert.Equal(t, map[string]string{"description": "required", "price": "type", "taxClass": "enum"}, problemFields(decoded))
+
+	status, decoded = specRequest(app, "POST", "/product?skuId=specSkuId", "application/json", `{"name":"Bag","description":"A bag","price":0}`, "Accept-Language", "fr")
+	assert.Equal(t, 400, status, "Create a product with a price of 0")
+	assert.Equal(t, map[string]string{"price": "exclusiveMinimum"}, problemFields(decoded))
+	assert.Equal(t, "La requête ne correspond pas à la spécification de l'API", decoded["detail"], "Problems follow Accept-Language")
+	assert.Equal(t, "price doit être supérieur à 0", decoded["errors"].([]interface{})[0].(map[string]interface{})["message"])
+
+	status, decoded = specRequest(app, "POST", "/product/some-id/variants?skuId=specSkuId", "application/json", `{"skuId":"-bad","options":{"color":" "},"price":10,"stock":1.5}`)
+	assert.Equal(t, 400, status, "Create a variant that breaks nested rules")
+	assert.Equal(t, map[string]string{"skuId": "pattern", "options.color": "pattern", "stock": "type"}, problemFields(decoded))
+
+	status, decoded = specRequest(app, "POST", "/product?skuId=specSkuId", "text/plain", `name=Bag`)
+	assert.Equal(t, 415, status, "Create a product with a body that is not JSON")
+	assert.Equal(t, "unsupported_media_type", decoded["code"])
+
+	status, decoded = specRequest(app, "POST", "/product?skuId=specSkuId", "application/json", `{"name":`)
+	assert.Equal(t, 400, status, "Create a product with malformed JSON")
+	assert.Equal(t, "invalid_payload", decoded["code"])
+
+	status, decoded = specRequest(app, "PUT", "/tax/settings?skuId=specSkuId", "", "")
+	assert.Equal(t, 400, status, "Update the tax settings without a body")
+	assert.Equal(t, map[string]string{"body": "required"}, problemFields(decoded))
+
+	status, decoded = specRequest(app, "GET", "/product?page=first&sortOrder=up&inStock=maybe", "", "")
+	assert.Equal(t, 400, status, "Get products with invalid query parameters")
+	assert.Equal(t, map[string]string{"page": "type", "sortOrder": "enum", "inStock": "type"}, problemFields(decoded))
+
+	status, decoded = specRequest(app, "GET", "/product/export?format=xml", "", "")
+	assert.Equal(t, 400, status, "Literal paths are matched before parameters")
+	assert.Equal(t, map[string]string{"format": "enum"}, problemFields(decoded))
+
+	status, decoded = specRequest(app, "POST", "/payment/webhook", "application/json", `{}`)
+	assert.Equal(t, 400, status, "Send a webhook without its signature header")
+	assert.Equal(t, map[string]string{"X-Payment-Signature": "required"}, problemFields(decoded))
+
+	status, _ = specRequest(app, "GET", "/product?page=1&limit=5&inStock=true", "", "")
+	assert.Equal(t, 200, status, "Get products with valid query parameters")
+
+	status, decoded = specRequest(app, "GET", "/not-documented", "", "")
+	assert.Equal(t, 404, status, "Routes the document does not have are left to the router")
+	assert.Equal(t, "not_found", decoded["code"])
+}
+
+func Test_openapiResponseValidation(t *testing.T) {
+	usePaymentProvider(t, payment.NewFakeProvider("test-secret", "/payment/fake"))
+
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(middleware.APIKeyAuth)
+	app.Use(middleware.MemberAuth)
+	app.Use(openapi.Validation(openapi.ValidationConfig{Responses: true}))
+	routes.Register(app, routes.Config{FakePayments: true})
+
+	// The merchant is claimed by a manager at the end, every run uses a merchant, variant and coupon of its own
+	skuId := testSkuId("specResponseSkuId")
+	merchant := "?skuId=" + skuId
+	variantSkuId := "spec-bag-red-" + uuid.NewString()[:8]
+	coupon := "SPEC" + strings.ToUpper(uuid.NewString()[:8])
+
+	// Every response is checked against the document, a mismatch is turned into an invalid_response problem
+	send := func(method string, route string, body string, expected int, name string, headers ...string) map[string]interface{} {
+		contentType := ""
+		if body != "" {
+			contentType = "application/json"
+		}
+
+		status, decoded := specRequest(app, method, route, contentType, body, headers...)
+		assert.Equal(t, expected, status, name, decoded)
+		assert.NotEqual(t, "invalid_response", decoded["code"], name, decoded["errors"])
+		return decoded
+	}
+
+	created := send("POST", "/product"+merchant, `{"name":"Spec bag","description":"A bag","price":2500}`, 201, "Create a product")
+	productId := created["product"].(map[string]interface{})["id"].(string)
+	defer models.DeleteProduct(productId)
+	productRoute := "/product/" + productId
+
+	send("GET", "/product"+merchant, "", 200, "Get all products")
+	send("GET", productRoute, "", 200, "Get a product")
+	send("PUT", productRoute+merchant, `{"price":3000}`, 200, "Update a product")
+	send("POST", productRoute+"/variants"+merchant, `{"skuId":"`+variantSkuId+`","options":{"color":"red"},"price":3200,"stock":4}`, 201, "Create a variant")
+	send("GET", productRoute+"/variants", "", 200, "Get the variants of a product")
+	send("PUT", productRoute+"/stock"+merchant, `{"lowStockThreshold":10}`, 200, "Update the stock settings")
+	send("POST", productRoute+"/stock/increment"+merchant, `{"quantity":5}`, 200, "Increment the stock")
+	send("GET", "/product/stock/low"+merchant, "", 200, "Get low stock products")
+	send("GET", productRoute+"/prices/history", "", 200, "Get the price history")
+	send("GET", productRoute+"/history"+merchant, "", 200, "Get the history of a product")
+
+	category := send("POST", "/category"+merchant, `{"name":"Spec bags"}`, 201, "Create a category")
+	categoryId := category["category"].(map[string]interface{})["id"].(string)
+	send("POST", productRoute+"/categories"+merchant, `{"categoryIds":["`+categoryId+`"]}`, 200, "Assign a category")
+	send("GET", "/category/"+categoryId, "", 200, "Get a category")
+	send("DELETE", productRoute+"/categories/"+categoryId+merchant, "", 200, "Remove a category from a product")
+	send("DELETE", "/category/"+categoryId+merchant, "", 200, "Delete a category")
+
+	send("POST", "/promotion"+merchant, `{"name":"Spec sale","type":"percentage","value":10,"code":"`+coupon+`"}`, 201, "Create a coupon")
+
+	cart := send("POST", "/cart", "", 201, "Create a cart")
+	cartRoute := "/cart/" + cart["cart"].(map[string]interface{})["id"].(string)
+	send("POST", cartRoute+"/items", `{"productId":"`+productId+`","quantity":2}`, 200, "Add an item to a cart")
+	send("POST", cartRoute+"/coupons", `{"code":"`+coupon+`"}`, 200, "Add a coupon to a cart")
+	send("GET", cartRoute, "", 200, "Get a cart")
+
+	order := send("POST", "/order", `{"cartId":"`+cart["cart"].(map[string]interface{})["id"].(string)+`"}`, 201, "Place an order")
+	orderRoute := "/order/" + order["order"].(map[string]interface{})["id"].(string)
+	send("GET", orderRoute, "", 200, "Get an order")
+	send("POST", orderRoute+"/payment", `{"email":"ada@example.com"}`, 201, "Start a payment")
+	send("GET", "/order"+merchant, "", 200, "Get the orders of a merchant")
+
+	send("GET", "/tax/rules", "", 200, "Get the tax rules")
+	send("GET", "/tax/settings"+merchant, "", 200, "Get the tax settings")
+	send("GET", "/api-key"+merchant, "", 200, "Get the API keys")
+	send("GET", "/team/members"+merchant, "", 200, "Get the team")
+	send("GET", "/audit"+merchant, "", 200, "Get the audit log")
+	send("GET", "/", "", 200, "Welcome")
+
+	send("GET", "/product/missing", "", 404, "Problems are checked too")
+	send("DELETE", productRoute+merchant, "", 200, "Delete a product")
+	// Once the merchant has a member its skuId alone only reads
+	manager := joinAsManager(t, skuId)
+	key := send("POST", "/api-key", `{"name":"Spec key","scopes":["products:read"]}`, 201, "Create an API key", middleware.MemberTokenHeader, manager)
+	if key, ok := key["apiKey"].(map[string]interface{}); ok {
+		defer models.RevokeAPIKey(key["id"].(string))
+	}
+
+	broken := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	broken.Use(openapi.Validation(openapi.ValidationConfig{Responses: true}))
+	broken.Get("/tax/rules", func(ctx *fiber.Ctx) error {
+		return ctx.Status(200).JSON(fiber.Map{"rules": "none"})
+	})
+
+	status, decoded := specRequest(broken, "GET", "/tax/rules", "", "")
+	assert.Equal(t, 500, status, "A response that drifted from the document")
+	assert.Equal(t, "invalid_response", decoded["code"])
+	assert.Equal(t, map[string]string{"message": "required", "rules": "type"}, problemFields(decoded))
+}
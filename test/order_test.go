@@ -0,0 +1,247 @@
+package test
+
+import (
+	"bytes"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testOrderProductId = "0d0d0d0d-0000-4000-8000-000000000001"
+var testOrderStockProductId = "0d0d0d0d-0000-4000-8000-000000000002"
+
+func orderRequest(app *fiber.App, method string, route string, body interface{}) (int, types.OrderResponse) {
+	req := httptest.NewRequest(method, route, nil)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, route, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0, types.OrderResponse{}
+	}
+
+	order := types.OrderResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&order)
+	return resp.StatusCode, order
+}
+
+// testSkuId names a merchant of its own for one run of a test, the stores are shared by every test in the package
+func testSkuId(name string) string {
+	return name + "-" + uuid.NewString()[:8]
+}
+
+// forgetOrders removes the orders of a test once it is done
+func forgetOrders(t *testing.T, ids ...string) {
+	t.Cleanup(func() {
+		for _, id := range ids {
+			delete(models.OrderData, id)
+		}
+	})
+}
+
+func Test_order(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	carts := app.Group("/cart")
+	carts.Get("/:id", handlers.GetCartEndpoint)
+	orders := app.Group("/order")
+	orders.Get("/", handlers.GetMerchantOrdersEndpoint)
+	orders.Post("/", handlers.PlaceOrderEndpoint)
+	orders.Get("/:id", handlers.GetOrderEndpoint)
+	orders.Post("/:id/transitions", handlers.TransitionOrderEndpoint)
+
+	skuId, otherSkuId := testSkuId("orderSkuId"), testSkuId("orderOtherSkuId")
+
+	models.SaveProduct(models.Product{
+		Id:          testOrderProductId,
+		SkuId:       skuId,
+		Name:        "Shea butter",
+		Description: "Raw shea butter",
+		Price:       800,
+		Stock:       6,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+
+	defer models.DeleteProduct(testOrderProductId)
+
+	empty := models.CreateCart()
+	defer models.DeleteCart(empty.Id)
+	status, _ := orderRequest(app, "POST", "/order", map[string]interface{}{"cartId": empty.Id})
+	assert.Equal(t, 400, status, "Place an order from an empty cart")
+
+	cart := models.CreateCart()
+	_, _ = models.UpdateCart(cart.Id, func(cart *models.Cart) error {
+		cart.AddItem(testOrderProductId, "", 3)
+		return nil
+	})
+
+	status, placed := orderRequest(app, "POST", "/order", map[string]interface{}{"cartId": cart.Id})
+	assert.Equal(t, 201, status, "Place an order")
+	assert.Equal(t, models.OrderPending, placed.Order.Status)
+	assert.Equal(t, float32(2400), placed.Order.Total)
+	assert.Equal(t, "Raw shea butter", placed.Order.Items[0].Description, "The product is snapshotted on the order")
+	forgetOrders(t, placed.Order.Id)
+	orderRoute := "/order/" + placed.Order.Id
+
+	status, _ = orderRequest(app, "GET", "/cart/"+cart.Id, nil)
+	assert.Equal(t, 404, status, "The cart is removed once the order is placed")
+
+	level, _, _ := models.ProductStockLevels(testOrderProductId)
+	assert.Equal(t, 3, level.Stock, "Placing an order takes the stock")
+
+	models.UpdateProduct(testOrderProductId, func(product *models.Product) {
+		product.Price = 1000
+	})
+	_, fetched := orderRequest(app, "GET", orderRoute, nil)
+	assert.Equal(t, float32(800), fetched.Order.Items[0].UnitPrice, "Later price changes do not affect the order")
+
+	tests := []struct {
+		description  string
+		route        string
+		expectedCode int
+		status       string
+		// paid has the order paid the way the payment webhook does before the request
+		paid bool
+	}{
+		{
+			description:  "Change the status without auth",
+			route:        orderRoute + "/transitions",
+			expectedCode: 401,
+			status:       "cancelled",
+		},
+		{
+			description:  "Change the status of another merchant's order",
+			route:        orderRoute + "/transitions?skuId=" + otherSkuId,
+			expectedCode: 403,
+			status:       "cancelled",
+		},
+		{
+			description:  "Change to an unknown status",
+			route:        orderRoute + "/transitions?skuId=" + skuId,
+			expectedCode: 400,
+			status:       "shipped",
+		},
+		{
+			description:  "Skip the payment",
+			route:        orderRoute + "/transitions?skuId=" + skuId,
+			expectedCode: 409,
+			status:       "fulfilled",
+		},
+		{
+			description:  "Mark the order as paid without the payment provider",
+			route:        orderRoute + "/transitions?skuId=" + skuId,
+			expectedCode: 400,
+			status:       "paid",
+		},
+		{
+			description:  "Cancel a paid order",
+			route:        orderRoute + "/transitions?skuId=" + skuId,
+			expectedCode: 409,
+			status:       "cancelled",
+			paid:         true,
+		},
+		{
+			description:  "Fulfil the order",
+			route:        orderRoute + "/transitions?skuId=" + skuId,
+			expectedCode: 200,
+			status:       "fulfilled",
+		},
+	}
+
+	for _, test := range tests {
+		if test.paid {
+			_, err := models.TransitionOrder(placed.Order.Id, models.OrderPaid, "Paid")
+			assert.NoErrorf(t, err, test.description)
+		}
+
+		status, _ := orderRequest(app, "POST", test.route, map[string]interface{}{"status": test.status})
+		assert.Equalf(t, test.expectedCode, status, test.description)
+	}
+
+	_, fetched = orderRequest(app, "GET", orderRoute, nil)
+	assert.Equal(t, models.OrderFulfilled, fetched.Order.Status)
+	assert.Len(t, fetched.Order.History, 2, "Every status change is recorded")
+
+	req := httptest.NewRequest("GET", "/order?skuId="+skuId+"&status=fulfilled", nil)
+	resp, _ := app.Test(req, 1000)
+	listed := types.GetOrdersResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&listed)
+	assert.Equal(t, 200, resp.StatusCode, "List the orders of a merchant")
+	assert.Len(t, listed.Orders, 1)
+
+	req = httptest.NewRequest("GET", "/order?skuId="+otherSkuId, nil)
+	resp, _ = app.Test(req, 1000)
+	listed = types.GetOrdersResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&listed)
+	assert.Len(t, listed.Orders, 0, "Merchants only see their own orders")
+}
+
+func Test_orderStock(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Get("/cart/:id", handlers.GetCartEndpoint)
+	orders := app.Group("/order")
+	orders.Post("/", handlers.PlaceOrderEndpoint)
+	orders.Post("/:id/transitions", handlers.TransitionOrderEndpoint)
+
+	skuId := testSkuId("orderStockSkuId")
+
+	models.SaveProduct(models.Product{
+		Id:        testOrderStockProductId,
+		SkuId:     skuId,
+		Name:      "Black soap",
+		Price:     500,
+		Stock:     4,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+
+	defer models.DeleteProduct(testOrderStockProductId)
+
+	reservation, err := models.ReserveStock(testOrderStockProductId, "", 1, time.Minute)
+	assert.NoError(t, err, "Reserve a unit")
+	defer models.ReleaseReservation(reservation.Id)
+
+	stock := func() int {
+		level, _, _ := models.ProductStockLevels(testOrderStockProductId)
+		return level.Stock
+	}
+
+	tooMany := models.CreateCart()
+	defer models.DeleteCart(tooMany.Id)
+	_, _ = models.UpdateCart(tooMany.Id, func(cart *models.Cart) error {
+		cart.AddItem(testOrderStockProductId, "", 4)
+		return nil
+	})
+
+	status, _ := orderRequest(app, "POST", "/order", map[string]interface{}{"cartId": tooMany.Id})
+	assert.Equal(t, 409, status, "Order more than is available once the reservations are taken out")
+	assert.Equal(t, 4, stock(), "A refused order takes nothing")
+	status, _ = orderRequest(app, "GET", "/cart/"+tooMany.Id, nil)
+	assert.Equal(t, 200, status, "A refused order keeps the cart")
+
+	cart := models.CreateCart()
+	_, _ = models.UpdateCart(cart.Id, func(cart *models.Cart) error {
+		cart.AddItem(testOrderStockProductId, "", 3)
+		return nil
+	})
+
+	status, placed := orderRequest(app, "POST", "/order", map[string]interface{}{"cartId": cart.Id})
+	assert.Equal(t, 201, status, "Order what is available")
+	forgetOrders(t, placed.Order.Id)
+	assert.Equal(t, 1, stock(), "Placing an order takes the stock")
+
+	status, _ = orderRequest(app, "POST", "/order/"+placed.Order.Id+"/transitions?skuId="+skuId, map[string]interface{}{"status": "cancelled"})
+	assert.Equal(t, 200, status, "Cancel the order")
+	assert.Equal(t, 4, stock(), "Cancelling the order puts the stock back")
+}
@@ -0,0 +1,201 @@
+package test
+
+import (
+	"bytes"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/payment"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testPaymentProductId = "9a9a9a9a-0000-4000-8000-000000000001"
+
+func webhookRequest(app *fiber.App, payload []byte, signature string) int {
+	req := httptest.NewRequest("POST", "/payment/webhook", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(handlers.PaymentSignatureHeader, signature)
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// usePaymentProvider swaps in the provider for one test and puts the previous one back once it is done
+func usePaymentProvider(t *testing.T, provider payment.PaymentProvider) {
+	previous := handlers.PaymentProvider
+	handlers.PaymentProvider = provider
+	t.Cleanup(func() { handlers.PaymentProvider = previous })
+}
+
+func Test_payment(t *testing.T) {
+	provider := payment.NewFakeProvider("test-secret", "/payment/fake")
+	usePaymentProvider(t, provider)
+	skuId, otherSkuId := testSkuId("paymentSkuId"), testSkuId("paymentOtherSkuId")
+
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	orders := app.Group("/order")
+	orders.Get("/:id", handlers.GetOrderEndpoint)
+	orders.Post("/:id/transitions", handlers.TransitionOrderEndpoint)
+	orders.Post("/:id/payment", handlers.CheckoutOrderEndpoint)
+	app.Post("/payment/webhook", handlers.PaymentWebhookEndpoint)
+
+	models.SaveProduct(models.Product{
+		Id:        testPaymentProductId,
+		SkuId:     skuId,
+		Name:      "Adire shirt",
+		Price:     5000,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+
+	defer models.DeleteProduct(testPaymentProductId)
+
+	order := models.CreateOrder("", []models.OrderItem{{
+		Id:        "item",
+		ProductId: testPaymentProductId,
+		SkuId:     skuId,
+		Name:      "Adire shirt",
+		UnitPrice: 5000,
+		Quantity:  2,
+		LineTotal: 10000,
+	}, {
+		Id:        "otherItem",
+		ProductId: "otherProduct",
+		SkuId:     otherSkuId,
+		Name:      "Kente scarf",
+		UnitPrice: 3000,
+		Quantity:  1,
+		LineTotal: 3000,
+		Tax:       models.TaxBreakdown{Tax: 300},
+	}})
+	forgetOrders(t, order.Id)
+	orderRoute := "/order/" + order.Id
+
+	status, _ := orderRequest(app, "POST", orderRoute+"/payment", map[string]interface{}{"email": "not an email"})
+	assert.Equal(t, 400, status, "Start a payment without a valid email")
+
+	req := httptest.NewRequest("POST", orderRoute+"/payment", bytes.NewReader([]byte(`{"email":"ada@example.com"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req, 1000)
+	started := types.PaymentResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&started)
+	assert.Equal(t, 201, resp.StatusCode, "Start a payment")
+	assert.Equal(t, float32(13300), started.Payment.Amount)
+	assert.Equal(t, payment.TransactionPending, started.Payment.Status)
+
+	payload, signature, err := provider.Pay(started.Payment.Reference)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 401, webhookRequest(app, payload, payment.Sign("wrong-secret", payload)), "Webhook with a bad signature")
+	assert.Equal(t, 401, webhookRequest(app, payload, ""), "Webhook without a signature")
+
+	_, fetched := orderRequest(app, "GET", orderRoute, nil)
+	assert.Equal(t, models.OrderPending, fetched.Order.Status, "Unsigned webhooks do not change the order")
+
+	assert.Equal(t, 200, webhookRequest(app, payload, signature), "Webhook for a completed payment")
+	assert.Equal(t, 200, webhookRequest(app, payload, signature), "Webhooks can be delivered more than once")
+
+	_, fetched = orderRequest(app, "GET", orderRoute, nil)
+	assert.Equal(t, models.OrderPaid, fetched.Order.Status, "The webhook marks the order as paid")
+	assert.Len(t, fetched.Order.History, 1)
+
+	status, _ = orderRequest(app, "POST", orderRoute+"/payment", map[string]interface{}{"email": "ada@example.com"})
+	assert.Equal(t, 409, status, "Pay for an order twice")
+
+	status, _ = orderRequest(app, "POST", orderRoute+"/transitions?skuId="+skuId, map[string]interface{}{"status": "paid"})
+	assert.Equal(t, 400, status, "Orders are only paid through the payment provider")
+
+	status, fetched = orderRequest(app, "POST", orderRoute+"/transitions?skuId="+skuId, map[string]interface{}{"status": "refunded"})
+	assert.Equal(t, 200, status, "Refund the items of a merchant")
+	assert.Equal(t, models.OrderPaid, fetched.Order.Status, "The items of the other merchant are still paid")
+	assert.Equal(t, models.OrderRefunded, fetched.Order.Items[0].Status)
+	assert.Equal(t, models.OrderPaid, fetched.Order.Items[1].Status)
+
+	status, _ = orderRequest(app, "POST", orderRoute+"/transitions?skuId="+skuId, map[string]interface{}{"status": "refunded"})
+	assert.Equal(t, 409, status, "Refund the same items twice")
+
+	transaction, _ := provider.Verify(started.Payment.Reference)
+	assert.Equal(t, payment.TransactionSuccess, transaction.Status, "Only the items of the merchant are refunded")
+
+	status, fetched = orderRequest(app, "POST", orderRoute+"/transitions?skuId="+otherSkuId, map[string]interface{}{"status": "refunded"})
+	assert.Equal(t, 200, status, "Refund the items of the other merchant")
+	assert.Equal(t, models.OrderRefunded, fetched.Order.Status)
+
+	transaction, _ = provider.Verify(started.Payment.Reference)
+	assert.Equal(t, payment.TransactionRefunded, transaction.Status, "Refunding every item refunds the whole payment")
+
+	unpaid := models.CreateOrder("", []models.OrderItem{{Id: "item", SkuId: skuId, LineTotal: 100}})
+	forgetOrders(t, unpaid.Id)
+	unpaidRoute := "/order/" + unpaid.Id
+	_, _ = orderRequest(app, "POST", unpaidRoute+"/payment", map[string]interface{}{"email": "ada@example.com"})
+	_, err = models.TransitionOrder(unpaid.Id, models.OrderPaid, "Paid")
+	assert.NoError(t, err)
+
+	status, fetched = orderRequest(app, "POST", unpaidRoute+"/transitions?skuId="+skuId, map[string]interface{}{"status": "refunded"})
+	assert.Equal(t, 502, status, "Refund a payment the provider never received")
+	_, fetched = orderRequest(app, "GET", unpaidRoute, nil)
+	assert.Equal(t, models.OrderPaid, fetched.Order.Status, "A failed refund leaves the order as it was")
+	assert.Len(t, fetched.Order.History, 1, "A failed refund leaves the order as it was")
+}
+
+func Test_paymentRetry(t *testing.T) {
+	provider := payment.NewFakeProvider("test-secret", "/payment/fake")
+	usePaymentProvider(t, provider)
+	skuId := testSkuId("paymentRetrySkuId")
+
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	orders := app.Group("/order")
+	orders.Post("/:id/transitions", handlers.TransitionOrderEndpoint)
+	orders.Post("/:id/payment", handlers.CheckoutOrderEndpoint)
+	app.Post("/payment/webhook", handlers.PaymentWebhookEndpoint)
+
+	order := models.CreateOrder("", []models.OrderItem{{Id: "item", SkuId: skuId, LineTotal: 2000}})
+	forgetOrders(t, order.Id)
+	orderRoute := "/order/" + order.Id
+
+	var references []string
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", orderRoute+"/payment", bytes.NewReader([]byte(`{"email":"ada@example.com"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req, 1000)
+		started := types.PaymentResponse{}
+		_ = json.NewDecoder(resp.Body).Decode(&started)
+		assert.Equal(t, 201, resp.StatusCode, "Start a payment")
+		references = append(references, started.Payment.Reference)
+	}
+
+	payload, signature, err := provider.Pay(references[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 200, webhookRequest(app, payload, signature), "Webhook for the payment started first")
+
+	paid, _ := models.FindOrderById(order.Id)
+	assert.Equal(t, models.OrderPaid, paid.Status, "Paying an earlier transaction pays the order")
+	assert.Equal(t, references[0], paid.PaymentReference, "The order keeps the transaction that paid it")
+	assert.Equal(t, references, paid.PaymentReferences)
+
+	status, _ := orderRequest(app, "POST", orderRoute+"/transitions?skuId="+skuId, map[string]interface{}{"status": "refunded"})
+	assert.Equal(t, 200, status, "Refund the order")
+
+	transaction, _ := provider.Verify(references[0])
+	assert.Equal(t, payment.TransactionRefunded, transaction.Status, "The transaction that paid is refunded")
+}
+
+func Test_paymentSignature(t *testing.T) {
+	payload := []byte(`{"event":"charge.success","reference":"ref"}`)
+	signature := payment.Sign("secret", payload)
+
+	assert.True(t, payment.VerifySignature("secret", payload, signature))
+	assert.False(t, payment.VerifySignature("other", payload, signature), "A different secret")
+	assert.False(t, payment.VerifySignature("secret", append(payload, ' '), signature), "A changed payload")
+	assert.False(t, payment.VerifySignature("secret", payload, "zz"), "A signature that is not hex")
+	assert.False(t, payment.VerifySignature("", payload, payment.Sign("", payload)), "An empty secret")
+}
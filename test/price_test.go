@@ -0,0 +1,124 @@
+package test
+
+import (
+	"bytes"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func priceScheduleRequest(app *fiber.App, method string, route string, body interface{}) (int, types.PriceScheduleResponse) {
+	req := httptest.NewRequest(method, route, nil)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, route, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0, types.PriceScheduleResponse{}
+	}
+
+	schedule := types.PriceScheduleResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&schedule)
+	return resp.StatusCode, schedule
+}
+
+func Test_priceSchedules(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	products := app.Group("/products")
+	products.Get("/:id", handlers.FindAProductEndpoint)
+	products.Put("/:id", handlers.UpdateProductEndpoint)
+	products.Get("/:id/prices/history", handlers.GetPriceHistoryEndpoint)
+	products.Post("/:id/prices/schedules", handlers.CreatePriceScheduleEndpoint)
+	products.Delete("/:id/prices/schedules/:scheduleId", handlers.CancelPriceScheduleEndpoint)
+
+	// Schedules and price history outlive the product, every run prices a product of its own
+	productId := uuid.NewString()
+	skuId := testSkuId("priceSkuId")
+
+	models.SaveProduct(models.Product{
+		Id:          productId,
+		SkuId:       skuId,
+		Name:        "Aso oke",
+		Description: "Woven cloth",
+		Price:       10000,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+
+	defer models.DeleteProduct(productId)
+
+	schedulesRoute := "/products/" + productId + "/prices/schedules?skuId=" + skuId
+	now := time.Now()
+
+	status, _ := priceScheduleRequest(app, "POST", schedulesRoute, map[string]interface{}{"price": 8000, "startsAt": now.Add(2 * time.Hour), "endsAt": now.Add(time.Hour)})
+	assert.Equal(t, 400, status, "Schedule ending before it starts")
+
+	status, _ = priceScheduleRequest(app, "POST", schedulesRoute, map[string]interface{}{"price": 200000000, "startsAt": now.Add(time.Hour)})
+	assert.Equal(t, 400, status, "Schedule a price over the highest product price")
+
+	status, weekend := priceScheduleRequest(app, "POST", schedulesRoute, map[string]interface{}{"price": 8000, "startsAt": now.Add(48 * time.Hour), "endsAt": now.Add(96 * time.Hour)})
+	assert.Equal(t, 201, status, "Schedule a weekend sale")
+	assert.Equal(t, models.ScheduleScheduled, weekend.Schedule.Status)
+
+	status, _ = priceScheduleRequest(app, "POST", schedulesRoute, map[string]interface{}{"price": 7000, "startsAt": now.Add(72 * time.Hour)})
+	assert.Equal(t, 409, status, "Schedule overlapping another schedule")
+
+	status, _ = priceScheduleRequest(app, "POST", "/products/"+productId+"/prices/schedules?skuId="+testSkuId("priceOtherSkuId"), map[string]interface{}{"price": 1, "startsAt": now})
+	assert.Equal(t, 403, status, "Schedule the price of another merchant's product")
+
+	status, flash := priceScheduleRequest(app, "POST", schedulesRoute, map[string]interface{}{"price": 9000, "startsAt": now.Add(-time.Minute), "endsAt": now.Add(time.Hour)})
+	assert.Equal(t, 201, status, "Schedule a sale starting now")
+	assert.Equal(t, models.ScheduleActive, flash.Schedule.Status, "A schedule that already started is active")
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/products/"+productId, nil), 1000)
+	product := types.OneProductResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&product)
+	assert.Equal(t, float32(10000), product.Product.Price, "The regular price is kept")
+	assert.Equal(t, float32(9000), product.Product.EffectivePrice, "The scheduled price is the effective price")
+	assert.Equal(t, float32(9000), product.Product.PriceRange.Min)
+
+	unitPrice, _, _ := models.UnitPrice(productId, "")
+	assert.Equal(t, float32(9000), unitPrice, "Carts use the effective price")
+
+	started, ended := models.ApplyPriceSchedules(now.Add(100 * time.Hour))
+	assert.Equal(t, 1, started, "The scheduler starts the weekend sale")
+	assert.Equal(t, 2, ended, "The scheduler ends both sales")
+	assert.Equal(t, float32(10000), models.EffectivePrice(productId, "", 10000, now.Add(100*time.Hour)), "The price goes back after the sale")
+	assert.Equal(t, float32(8000), models.EffectivePrice(productId, "", 10000, now.Add(50*time.Hour)), "The price is computed for the time it is read")
+
+	status, _ = priceScheduleRequest(app, "DELETE", "/products/"+productId+"/prices/schedules/"+weekend.Schedule.Id+"?skuId="+skuId, nil)
+	assert.Equal(t, 409, status, "Cancel a schedule that already ended")
+
+	req := httptest.NewRequest("PUT", "/products/"+productId+"?skuId="+skuId, bytes.NewReader([]byte(`{"price":11000}`)))
+	req.Header.Set("Content-Type", "application/json")
+	_, _ = app.Test(req, 1000)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/products/"+productId+"/prices/history", nil), 1000)
+	history := types.PriceHistoryResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&history)
+	assert.Len(t, history.History, 5, "Every price change is in the history")
+
+	sources := map[string]int{}
+	for i, change := range history.History {
+		sources[change.Source]++
+		if i > 0 {
+			assert.False(t, change.At.After(history.History[i-1].At), "The newest change comes first")
+		}
+		if change.Source == models.PriceSourceManual {
+			assert.Equal(t, float32(10000), change.PreviousPrice)
+			assert.Equal(t, float32(11000), change.Price)
+		}
+	}
+	assert.Equal(t, map[string]int{models.PriceSourceManual: 1, models.PriceSourceScheduleStart: 2, models.PriceSourceScheduleEnd: 2}, sources)
+}
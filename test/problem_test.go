@@ -0,0 +1,115 @@
+package test
+
+import (
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_problems(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(middleware.Idempotency(time.Hour))
+	app.Get("/products/:id", handlers.FindAProductEndpoint)
+	app.Post("/products", handlers.CreateProductEndpoint)
+	app.Get("/fiber", func(ctx *fiber.Ctx) error {
+		return fiber.ErrMethodNotAllowed
+	})
+	app.Get("/panic", func(ctx *fiber.Ctx) error {
+		return errors.New("database is on fire")
+	})
+
+	tests := []struct {
+		description  string
+		method       string
+		route        string
+		body         string
+		expectedCode int
+		code         problem.Code
+		detail       string
+		fields       []string
+	}{
+		{
+			description:  "A handler returns a typed problem",
+			method:       "GET",
+			route:        "/products/dada",
+			expectedCode: 404,
+			code:         problem.CodeProductNotFound,
+			detail:       "Product not found",
+		},
+		{
+			description:  "Validation problems list every field",
+			method:       "POST",
+			route:        "/products?skuId=problemSkuId",
+			body:         `{"name":"Kente"}`,
+			expectedCode: 400,
+			code:         problem.CodeValidationFailed,
+			fields:       []string{"description", "price"},
+		},
+		{
+			description:  "Fiber errors keep their status",
+			method:       "GET",
+			route:        "/fiber",
+			expectedCode: 405,
+			code:         problem.CodeMethodNotAllowed,
+			detail:       "Method Not Allowed",
+		},
+		{
+			description:  "Unexpected errors do not leak their message",
+			method:       "GET",
+			route:        "/panic",
+			expectedCode: 500,
+			code:         problem.CodeInternal,
+			detail:       "Something went wrong",
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.route, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req, 1000)
+
+		body := problem.Problem{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+
+		assert.Equalf(t, test.expectedCode, resp.StatusCode, test.description)
+		assert.Equalf(t, problem.ContentType, resp.Header.Get("Content-Type"), test.description)
+		assert.Equalf(t, test.expectedCode, body.Status, test.description)
+		assert.Equalf(t, test.code, body.Code, test.description)
+		assert.Equalf(t, "/problems/"+string(test.code), body.Type, test.description)
+		assert.Equalf(t, test.route, body.Instance, test.description)
+
+		if test.detail != "" {
+			assert.Equalf(t, test.detail, body.Detail, test.description)
+		}
+
+		fields := make([]string, 0)
+		for _, fieldError := range body.Errors {
+			fields = append(fields, fieldError.Field)
+		}
+		if test.fields != nil {
+			assert.Equalf(t, test.fields, fields, test.description)
+		}
+	}
+
+	send := func() (int, string) {
+		req := httptest.NewRequest("POST", "/products?skuId=problemSkuId", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.IdempotencyKeyHeader, "problem-key")
+		resp, _ := app.Test(req, 1000)
+		return resp.StatusCode, resp.Header.Get(middleware.IdempotencyReplayedHeader)
+	}
+
+	status, _ := send()
+	assert.Equal(t, 400, status)
+	status, replayed := send()
+	assert.Equal(t, 400, status, "Problems are replayed like any other response")
+	assert.Equal(t, "true", replayed)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/rnwonder/SAL/internals/handlers"
 	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
 	"github.com/rnwonder/SAL/util"
 	"github.com/stretchr/testify/assert"
 	"io"
@@ -74,10 +75,15 @@ func Test_getAllProducts(t *testing.T) {
 		},
 	}
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
 	products := app.Group("/products")
 	products.Get("/", handlers.GetAllProductsEndpoint)
 
+	// The store starts with sample products, the rows without seed data need it empty
+	stored := models.ProductData
+	models.ProductData = map[string]models.Product{}
+	defer func() { models.ProductData = stored }()
+
 	hasSeed := false
 
 	for _, test := range tests {
@@ -120,7 +126,7 @@ func Test_findAProduct(t *testing.T) {
 			route:        "/products/dada",
 			expectedCode: 404,
 			contains: []string{
-				`"message":"Product not found"`,
+				`"detail":"Product not found"`,
 			},
 		},
 		{
@@ -136,7 +142,7 @@ func Test_findAProduct(t *testing.T) {
 		},
 	}
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
 	products := app.Group("/products")
 	products.Get("/:id", handlers.FindAProductEndpoint)
 
@@ -187,7 +193,7 @@ func Test_createAProduct(t *testing.T) {
 			route:        "/products?skuId=shaggsas",
 			expectedCode: 400,
 			contains: []string{
-				`"message":"Invalid request payload"`,
+				`"detail":"Invalid request payload"`,
 			},
 			noBody: true,
 		},
@@ -196,10 +202,10 @@ func Test_createAProduct(t *testing.T) {
 			route:        "/products?skuId=shaggsas",
 			expectedCode: 400,
 			contains: []string{
-				`Name`,
-				`Description`,
-				`Price`,
-				`Needs to implement 'required'`,
+				`"field":"name"`,
+				`"field":"description"`,
+				`"field":"price"`,
+				`"rule":"required"`,
 			},
 			body: map[string]interface{}{
 				"sss": "A product2",
@@ -226,7 +232,7 @@ func Test_createAProduct(t *testing.T) {
 			route:        "/products",
 			expectedCode: 401,
 			contains: []string{
-				`"message":"Invalid request please provide skuId query parameter"`,
+				`"detail":"Invalid request please provide skuId query parameter"`,
 			},
 			body: map[string]interface{}{
 				"Name":        "A product2",
@@ -236,7 +242,7 @@ func Test_createAProduct(t *testing.T) {
 		},
 	}
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
 	products := app.Group("/products")
 	products.Post("/", handlers.CreateProductEndpoint)
 
@@ -304,7 +310,7 @@ func Test_deleteAProduct(t *testing.T) {
 			route:        "/products/" + testId1,
 			expectedCode: 401,
 			contains: []string{
-				`"message":"Invalid request please provide skuId query parameter"`,
+				`"detail":"Invalid request please provide skuId query parameter"`,
 			},
 		},
 		{
@@ -312,7 +318,7 @@ func Test_deleteAProduct(t *testing.T) {
 			route:        "/products/dada?skuId=someSkuId",
 			expectedCode: 404,
 			contains: []string{
-				`"message":"Product not found"`,
+				`"detail":"Product not found"`,
 			},
 		},
 		{
@@ -328,12 +334,12 @@ func Test_deleteAProduct(t *testing.T) {
 			route:        "/products/" + testId2 + "?skuId=someSkuId",
 			expectedCode: 403,
 			contains: []string{
-				`"message":"You do not have permission to update this product"`,
+				`"detail":"You do not have permission to update this product"`,
 			},
 		},
 	}
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
 	products := app.Group("/products")
 	products.Delete("/:id", handlers.DeleteProductEndpoint)
 
@@ -399,7 +405,7 @@ func Test_updateAProduct(t *testing.T) {
 			route:        "/products/" + testId1 + "?skuId=someSkuId",
 			expectedCode: 400,
 			contains: []string{
-				`"message":"Invalid request payload"`,
+				`"detail":"Invalid request payload"`,
 			},
 			noBody: true,
 		},
@@ -435,9 +441,21 @@ func Test_updateAProduct(t *testing.T) {
 				"Name": "Car",
 			},
 		},
+		{
+			description:  "Update a product that exists but is not owned by the merchant",
+			route:        "/products/" + testId2 + "?skuId=someSkuId",
+			expectedCode: 409,
+			contains: []string{
+				`"code":"forbidden"`,
+				`"detail":"You do not have permission to update this product"`,
+			},
+			body: map[string]interface{}{
+				"Name": "Bike",
+			},
+		},
 	}
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
 	products := app.Group("/products")
 	products.Put("/:id", handlers.UpdateProductEndpoint)
 
@@ -0,0 +1,198 @@
+package test
+
+import (
+	"bytes"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testPromotionProductId = "c2c2c2c2-0000-4000-8000-000000000001"
+var testPromotionCategoryProductId = "c2c2c2c2-0000-4000-8000-000000000002"
+
+func promotionRequest(app *fiber.App, method string, route string, body interface{}) (int, types.PromotionResponse) {
+	req := httptest.NewRequest(method, route, nil)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, route, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0, types.PromotionResponse{}
+	}
+
+	promotion := types.PromotionResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&promotion)
+	return resp.StatusCode, promotion
+}
+
+func Test_promotions(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Get("/product/:id", handlers.FindAProductEndpoint)
+	promotions := app.Group("/promotion")
+	promotions.Get("/:id", handlers.FindAPromotionEndpoint)
+	promotions.Post("/", handlers.CreatePromotionEndpoint)
+	promotions.Delete("/:id", handlers.DeletePromotionEndpoint)
+	carts := app.Group("/cart")
+	carts.Get("/:id", handlers.GetCartEndpoint)
+	carts.Post("/:id/coupons", handlers.AddCartCouponEndpoint)
+	carts.Delete("/:id/coupons/:code", handlers.RemoveCartCouponEndpoint)
+	app.Post("/order", handlers.PlaceOrderEndpoint)
+
+	models.SaveCategory(models.Category{Id: "promoFabrics", SkuId: "promoSkuId", Name: "Fabrics"})
+	defer models.DeleteCategory("promoFabrics")
+
+	models.SaveProduct(models.Product{
+		Id:        testPromotionProductId,
+		SkuId:     "promoSkuId",
+		Name:      "Ankara print",
+		Price:     2000,
+		Stock:     10,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	models.SaveProduct(models.Product{
+		Id:          testPromotionCategoryProductId,
+		SkuId:       "promoSkuId",
+		Name:        "Lace",
+		Price:       5000,
+		Stock:       10,
+		CategoryIds: []string{"promoFabrics"},
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+
+	defer models.DeleteProduct(testPromotionProductId)
+	defer models.DeleteProduct(testPromotionCategoryProductId)
+
+	route := "/promotion?skuId=promoSkuId"
+	tests := []struct {
+		description  string
+		body         map[string]interface{}
+		expectedCode int
+	}{
+		{
+			description:  "Create a promotion with an unknown discount type",
+			body:         map[string]interface{}{"name": "Sale", "type": "bogus", "value": 10},
+			expectedCode: 400,
+		},
+		{
+			description:  "Create a percentage promotion over 100",
+			body:         map[string]interface{}{"name": "Sale", "type": "percentage", "value": 120},
+			expectedCode: 400,
+		},
+		{
+			description:  "Create a fixed promotion over the highest price",
+			body:         map[string]interface{}{"name": "Sale", "type": "fixed", "value": 200000000},
+			expectedCode: 400,
+		},
+		{
+			description:  "Create a promotion on another merchant's product",
+			body:         map[string]interface{}{"name": "Sale", "type": "fixed", "value": 10, "productIds": []string{"someMissingProduct"}},
+			expectedCode: 404,
+		},
+	}
+
+	for _, test := range tests {
+		status, _ := promotionRequest(app, "POST", route, test.body)
+		assert.Equalf(t, test.expectedCode, status, test.description)
+	}
+
+	status, sale := promotionRequest(app, "POST", route, map[string]interface{}{"name": "Ankara week", "type": "percentage", "value": 10, "productIds": []string{testPromotionProductId}})
+	assert.Equal(t, 201, status, "Create an automatic percentage promotion")
+	defer models.DeletePromotion(sale.Promotion.Id)
+
+	status, fabrics := promotionRequest(app, "POST", route, map[string]interface{}{"name": "Fabric fair", "type": "fixed", "value": 500, "categoryIds": []string{"promoFabrics"}})
+	assert.Equal(t, 201, status, "Create a category promotion")
+	defer models.DeletePromotion(fabrics.Promotion.Id)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/product/"+testPromotionProductId, nil), 1000)
+	product := types.OneProductResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&product)
+	assert.Equal(t, float32(1800), product.Product.DiscountedPrice, "Automatic promotions show in the product")
+	assert.Equal(t, sale.Promotion.Id, product.Product.Promotion.PromotionId)
+
+	status, coupon := promotionRequest(app, "POST", route, map[string]interface{}{"name": "Launch", "type": "percentage", "value": 30, "code": "launch30", "usageLimit": 1})
+	assert.Equal(t, 201, status, "Create a coupon")
+	assert.Equal(t, "LAUNCH30", coupon.Promotion.Code, "Coupon codes are case insensitive")
+	defer models.DeletePromotion(coupon.Promotion.Id)
+
+	status, _ = promotionRequest(app, "POST", route, map[string]interface{}{"name": "Copy", "type": "fixed", "value": 1, "code": "LAUNCH30"})
+	assert.Equal(t, 409, status, "Create a coupon with a code already in use")
+
+	status, copied := promotionRequest(app, "POST", "/promotion?skuId=promoOtherSkuId", map[string]interface{}{"name": "Copy", "type": "fixed", "value": 1, "code": "LAUNCH30"})
+	assert.Equal(t, 201, status, "Another merchant can use the same code")
+	defer models.DeletePromotion(copied.Promotion.Id)
+
+	status, _ = promotionRequest(app, "GET", "/promotion/"+coupon.Promotion.Id+"?skuId=someSkuId", nil)
+	assert.Equal(t, 403, status, "Get the promotion of another merchant")
+
+	expired, _ := models.CreatePromotion(models.Promotion{
+		SkuId:     "promoSkuId",
+		Name:      "Last year",
+		Type:      models.DiscountFixed,
+		Value:     100,
+		Code:      "OLD100",
+		ExpiresAt: func() *time.Time { at := time.Now().Add(-time.Hour); return &at }(),
+	})
+	defer models.DeletePromotion(expired.Id)
+
+	cart := models.CreateCart()
+	_, _ = models.UpdateCart(cart.Id, func(cart *models.Cart) error {
+		cart.AddItem(testPromotionProductId, "", 2)
+		cart.AddItem(testPromotionCategoryProductId, "", 1)
+		return nil
+	})
+	couponsRoute := "/cart/" + cart.Id + "/coupons"
+
+	status, priced := cartRequest(app, "GET", "/cart/"+cart.Id, nil)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, float32(9000), priced.Cart.Subtotal)
+	assert.Equal(t, float32(900), priced.Cart.Discount, "10% off the ankara and 500 off the lace")
+	assert.Equal(t, float32(8100), priced.Cart.Total)
+
+	status, _ = cartRequest(app, "POST", couponsRoute, map[string]interface{}{"code": "OLD100"})
+	assert.Equal(t, 409, status, "Apply an expired coupon")
+
+	status, _ = cartRequest(app, "POST", couponsRoute, map[string]interface{}{"code": "NOPE"})
+	assert.Equal(t, 404, status, "Apply an unknown coupon")
+
+	status, priced = cartRequest(app, "POST", couponsRoute, map[string]interface{}{"code": "launch30"})
+	assert.Equal(t, 200, status, "Apply a coupon")
+	assert.Equal(t, []string{"LAUNCH30"}, priced.Cart.Coupons)
+	assert.Equal(t, float32(2700), priced.Cart.Discount, "The best promotion applies to every line and promotions do not stack")
+	assert.Equal(t, float32(6300), priced.Cart.Total)
+
+	status, placed := orderRequest(app, "POST", "/order", map[string]interface{}{"cartId": cart.Id})
+	assert.Equal(t, 201, status, "Place an order with a coupon")
+	assert.Equal(t, float32(6300), placed.Order.Total)
+	assert.Equal(t, coupon.Promotion.Id, placed.Order.Items[0].PromotionId)
+	assert.Equal(t, float32(1200), placed.Order.Items[0].Discount)
+
+	status, fetched := promotionRequest(app, "GET", "/promotion/"+coupon.Promotion.Id+"?skuId=promoSkuId", nil)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, fetched.Promotion.UsageCount, "A coupon is used once per order")
+
+	another := models.CreateCart()
+	defer models.DeleteCart(another.Id)
+	status, _ = cartRequest(app, "POST", "/cart/"+another.Id+"/coupons", map[string]interface{}{"code": "LAUNCH30"})
+	assert.Equal(t, 404, status, "An empty cart needs the merchant of the coupon")
+
+	status, _ = cartRequest(app, "POST", "/cart/"+another.Id+"/coupons", map[string]interface{}{"code": "LAUNCH30", "skuId": "promoSkuId"})
+	assert.Equal(t, 409, status, "Apply a coupon that reached its usage limit")
+
+	status, _ = promotionRequest(app, "DELETE", "/promotion/"+sale.Promotion.Id+"?skuId=promoSkuId", nil)
+	assert.Equal(t, 200, status, "Delete a promotion")
+
+	status, _ = promotionRequest(app, "GET", "/promotion/"+sale.Promotion.Id+"?skuId=promoSkuId", nil)
+	assert.Equal(t, 404, status, "Get a deleted promotion")
+}
@@ -0,0 +1,237 @@
+package test
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_rateLimit(t *testing.T) {
+	// app.Test sends every request from the same address, the clients are told apart by X-Forwarded-For instead
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler, ProxyHeader: fiber.HeaderXForwardedFor})
+	app.Use(middleware.APIKeyAuth)
+	app.Use(middleware.MemberAuth)
+	app.Use(middleware.RateLimit(middleware.RateLimitConfig{
+		Default: middleware.RateLimitRule{Requests: 3, Per: time.Minute},
+		Routes: map[string]middleware.RateLimitRule{
+			"POST /product": {Requests: 1, Per: time.Hour},
+		},
+	}))
+	app.Get("/product", func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(200)
+	})
+	app.Post("/product", func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(201)
+	})
+
+	key, secret, err := models.CreateAPIKey("limitedSkuId", "Limited", []string{models.ScopeProductsRead})
+	assert.NoError(t, err, "Create an API key")
+	defer models.RevokeAPIKey(key.Id)
+
+	invited, invite, err := models.InviteMember("limitedSkuId", "limited@example.com", models.RoleViewer, string(models.RoleOwner))
+	assert.NoError(t, err, "Invite a member")
+	defer models.RemoveMember(invited.Id)
+	_, token, err := models.AcceptInvite(invite)
+	assert.NoError(t, err, "Accept the invite")
+
+	tests := []struct {
+		description  string
+		method       string
+		route        string
+		header       string
+		value        string
+		ip           string
+		expectedCode int
+		remaining    string
+	}{
+		{
+			description:  "First request of a client",
+			method:       "GET",
+			route:        "/product?skuId=limitedSkuId",
+			expectedCode: 200,
+			remaining:    "2",
+		},
+		{
+			description:  "Another skuId still counts against the IP",
+			method:       "GET",
+			route:        "/product?skuId=otherSkuId",
+			expectedCode: 200,
+			remaining:    "1",
+		},
+		{
+			description:  "Route with its own limit",
+			method:       "POST",
+			route:        "/product?skuId=limitedSkuId",
+			expectedCode: 201,
+			remaining:    "0",
+		},
+		{
+			description:  "Route limit used up",
+			method:       "POST",
+			route:        "/product?skuId=limitedSkuId",
+			expectedCode: 429,
+			remaining:    "0",
+		},
+		{
+			description:  "Last request of a client",
+			method:       "GET",
+			route:        "/product",
+			expectedCode: 200,
+			remaining:    "0",
+		},
+		{
+			description:  "A made up skuId does not get a client a fresh bucket",
+			method:       "GET",
+			route:        "/product?skuId=freshSkuId",
+			expectedCode: 429,
+			remaining:    "0",
+		},
+		{
+			description:  "API keys have their own bucket",
+			method:       "GET",
+			route:        "/product",
+			header:       middleware.APIKeyHeader,
+			value:        secret,
+			expectedCode: 200,
+			remaining:    "2",
+		},
+		{
+			description:  "Members have their own bucket",
+			method:       "GET",
+			route:        "/product",
+			header:       middleware.MemberTokenHeader,
+			value:        token,
+			expectedCode: 200,
+			remaining:    "2",
+		},
+		{
+			description:  "The merchant in skuId has a bucket shared by every client",
+			method:       "GET",
+			route:        "/product?skuId=limitedSkuId",
+			ip:           "198.51.100.7",
+			expectedCode: 200,
+			remaining:    "1",
+		},
+		{
+			description:  "The merchant in skuId has a bucket shared by every client",
+			method:       "GET",
+			route:        "/product?skuId=limitedSkuId",
+			ip:           "198.51.100.8",
+			expectedCode: 200,
+			remaining:    "0",
+		},
+		{
+			description:  "Merchant limit used up",
+			method:       "GET",
+			route:        "/product?skuId=limitedSkuId",
+			ip:           "198.51.100.9",
+			expectedCode: 429,
+			remaining:    "0",
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.route, nil)
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+		if test.ip == "" {
+			test.ip = "192.0.2.1"
+		}
+		req.Header.Set(fiber.HeaderXForwardedFor, test.ip)
+
+		resp, err := app.Test(req, 1000)
+
+		if err != nil {
+			t.Errorf("error testing route %s: %v", test.route, err)
+			continue
+		}
+
+		assert.Equalf(t, test.expectedCode, resp.StatusCode, test.description)
+		assert.Equalf(t, test.remaining, resp.Header.Get("RateLimit-Remaining"), test.description)
+		assert.NotEmptyf(t, resp.Header.Get("RateLimit-Limit"), test.description)
+
+		if test.expectedCode == 429 {
+			assert.NotEmptyf(t, resp.Header.Get("Retry-After"), test.description)
+		}
+	}
+
+	// Made up keys are rejected before they are counted, they never get a bucket of their own
+	req := httptest.NewRequest("GET", "/product", nil)
+	req.Header.Set(middleware.APIKeyHeader, "sal_made_up")
+	resp, _ := app.Test(req, 1000)
+	assert.Equal(t, 401, resp.StatusCode, "Send a made up API key")
+	assert.Empty(t, resp.Header.Get("RateLimit-Remaining"), "Send a made up API key")
+}
+
+func Test_authFailureLimit(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(middleware.AuthFailureLimit(middleware.RateLimitRule{Requests: 2, Per: time.Hour}))
+	app.Use(middleware.APIKeyAuth)
+	app.Use(middleware.MemberAuth)
+	app.Get("/product", func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(200)
+	})
+
+	key, secret, err := models.CreateAPIKey("authLimitedSkuId", "Limited", []string{models.ScopeProductsRead})
+	assert.NoError(t, err, "Create an API key")
+	defer models.RevokeAPIKey(key.Id)
+
+	tests := []struct {
+		description  string
+		header       string
+		value        string
+		expectedCode int
+	}{
+		{description: "Valid keys are not counted", header: middleware.APIKeyHeader, value: secret, expectedCode: 200},
+		{description: "Valid keys are not counted", header: middleware.APIKeyHeader, value: secret, expectedCode: 200},
+		{description: "Valid keys are not counted", header: middleware.APIKeyHeader, value: secret, expectedCode: 200},
+		{description: "Requests without credentials are not counted", expectedCode: 200},
+		{description: "A made up API key", header: middleware.APIKeyHeader, value: "sal_made_up", expectedCode: 401},
+		{description: "A made up member token", header: middleware.MemberTokenHeader, value: "mem_made_up", expectedCode: 401},
+		{description: "The IP has run out of failures", header: middleware.APIKeyHeader, value: "sal_made_up", expectedCode: 429},
+		{description: "Valid keys from the IP wait too", header: middleware.APIKeyHeader, value: secret, expectedCode: 429},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/product", nil)
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+
+		resp, err := app.Test(req, 1000)
+		if err != nil {
+			t.Errorf("error testing %s: %v", test.description, err)
+			continue
+		}
+
+		assert.Equalf(t, test.expectedCode, resp.StatusCode, test.description)
+		if test.expectedCode == 429 {
+			assert.NotEmptyf(t, resp.Header.Get("Retry-After"), test.description)
+		}
+	}
+}
+
+func Test_parseRateLimits(t *testing.T) {
+	rule, err := middleware.ParseRateLimitRule("30/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, middleware.RateLimitRule{Requests: 30, Per: time.Minute}, rule)
+
+	for _, invalid := range []string{"", "30", "0/1m", "abc/1m", "30/soon", "30/-1s"} {
+		_, err := middleware.ParseRateLimitRule(invalid)
+		assert.Errorf(t, err, "rate limit %q", invalid)
+	}
+
+	routes, err := middleware.ParseRateLimitRoutes("post /product=30/1m; GET /product/export=5/1m;")
+	assert.NoError(t, err)
+	assert.Equal(t, 30, routes["POST /product"].Requests)
+	assert.Equal(t, 5, routes["GET /product/export"].Requests)
+
+	_, err = middleware.ParseRateLimitRoutes("/product=30/1m")
+	assert.Error(t, err, "route without a method")
+}
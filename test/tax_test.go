@@ -0,0 +1,154 @@
+package test
+
+import (
+	"bytes"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testTaxProductId = "d3d3d3d3-0000-4000-8000-000000000001"
+
+func taxSettingsRequest(app *fiber.App, body interface{}) int {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("PUT", "/tax/settings?skuId=taxSkuId", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+func Test_calculateTax(t *testing.T) {
+	tests := []struct {
+		description string
+		amount      float32
+		rate        float32
+		inclusive   bool
+		net         float32
+		tax         float32
+		gross       float32
+	}{
+		{description: "Take VAT out of an inclusive price", amount: 1075, rate: 7.5, inclusive: true, net: 1000, tax: 75, gross: 1075},
+		{description: "Add VAT to an exclusive price", amount: 1000, rate: 7.5, inclusive: false, net: 1000, tax: 75, gross: 1075},
+		{description: "Round the tax to two decimals", amount: 99.99, rate: 7.5, inclusive: false, net: 99.99, tax: 7.5, gross: 107.49},
+		{description: "Zero rated", amount: 500, rate: 0, inclusive: true, net: 500, tax: 0, gross: 500},
+	}
+
+	for _, test := range tests {
+		net, tax, gross := models.CalculateTax(test.amount, test.rate, test.inclusive)
+		assert.InDeltaf(t, test.net, net, 0.001, test.description)
+		assert.InDeltaf(t, test.tax, tax, 0.001, test.description)
+		assert.InDeltaf(t, test.gross, gross, 0.001, test.description)
+	}
+}
+
+func Test_loadTaxRules(t *testing.T) {
+	rules, err := models.LoadTaxRules("../config/taxRules.json")
+	assert.Nil(t, err, "Load the tax rules shipped with the API")
+	assert.Contains(t, rules, models.TaxRule{Jurisdiction: "NG", Class: models.TaxStandard, Rate: 7.5})
+
+	invalid := filepath.Join(t.TempDir(), "taxRules.json")
+	_ = os.WriteFile(invalid, []byte(`[{"jurisdiction":"NG","class":"luxury","rate":10}]`), 0o600)
+	_, err = models.LoadTaxRules(invalid)
+	assert.NotNil(t, err, "Load a rule with an unknown tax class")
+}
+
+func Test_tax(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Get("/product/:id", handlers.FindAProductEndpoint)
+	app.Put("/product/:id", handlers.UpdateProductEndpoint)
+	app.Get("/cart/:id", handlers.GetCartEndpoint)
+	app.Get("/tax/settings", handlers.GetTaxSettingsEndpoint)
+	app.Put("/tax/settings", handlers.UpdateTaxSettingsEndpoint)
+
+	models.SaveProduct(models.Product{
+		Id:        testTaxProductId,
+		SkuId:     "taxSkuId",
+		Name:      "Palm oil",
+		Price:     1075,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+
+	defer models.DeleteProduct(testTaxProductId)
+	defer delete(models.TaxSettingsData, "taxSkuId")
+
+	fetchProduct := func() types.ProductView {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/product/"+testTaxProductId, nil), 1000)
+		product := types.OneProductResponse{}
+		_ = json.NewDecoder(resp.Body).Decode(&product)
+		return product.Product
+	}
+
+	product := fetchProduct()
+	assert.Equal(t, "NG", product.Tax.Jurisdiction, "Merchants start in the default jurisdiction")
+	assert.True(t, product.Tax.Inclusive, "Prices include tax by default")
+	assert.Equal(t, float32(1000), product.Tax.Net)
+	assert.Equal(t, float32(75), product.Tax.Tax)
+	assert.Equal(t, float32(1075), product.Tax.Gross)
+
+	tests := []struct {
+		description  string
+		body         map[string]interface{}
+		expectedCode int
+	}{
+		{
+			description:  "Update the tax settings without pricesIncludeTax",
+			body:         map[string]interface{}{"jurisdiction": "NG"},
+			expectedCode: 400,
+		},
+		{
+			description:  "Update the tax settings to a jurisdiction without rules",
+			body:         map[string]interface{}{"jurisdiction": "XX", "pricesIncludeTax": false},
+			expectedCode: 400,
+		},
+		{
+			description:  "Switch to tax exclusive prices",
+			body:         map[string]interface{}{"jurisdiction": "ng", "pricesIncludeTax": false},
+			expectedCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equalf(t, test.expectedCode, taxSettingsRequest(app, test.body), test.description)
+	}
+
+	product = fetchProduct()
+	assert.False(t, product.Tax.Inclusive)
+	assert.Equal(t, float32(1075), product.Tax.Net)
+	assert.InDelta(t, 80.63, product.Tax.Tax, 0.001, "VAT is added on top of exclusive prices")
+
+	cart := models.CreateCart()
+	_, _ = models.UpdateCart(cart.Id, func(cart *models.Cart) error {
+		cart.AddItem(testTaxProductId, "", 2)
+		return nil
+	})
+
+	_, priced := cartRequest(app, "GET", "/cart/"+cart.Id, nil)
+	assert.Equal(t, float32(2150), priced.Cart.Subtotal)
+	assert.InDelta(t, 161.25, priced.Cart.Tax, 0.001)
+	assert.InDelta(t, 2311.25, priced.Cart.Total, 0.001, "Exclusive tax is added to the cart total")
+
+	data, _ := json.Marshal(map[string]interface{}{"taxClass": "exempt"})
+	req := httptest.NewRequest("PUT", "/product/"+testTaxProductId+"?skuId=taxSkuId", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req, 1000)
+	assert.Equal(t, 200, resp.StatusCode, "Make a product tax exempt")
+
+	product = fetchProduct()
+	assert.Equal(t, models.TaxExempt, product.Tax.Class)
+	assert.Equal(t, float32(0), product.Tax.Tax)
+	assert.Equal(t, float32(1075), product.Tax.Gross)
+}
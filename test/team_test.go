@@ -0,0 +1,320 @@
+package test
+
+import (
+	"bytes"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/middleware"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/internals/routes"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTeamProductId = "7e7e7e7e-0000-4000-8000-000000000001"
+
+func memberRequest(app *fiber.App, method string, route string, token string, body interface{}) (int, types.MemberResponse) {
+	req := httptest.NewRequest(method, route, nil)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, route, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set(middleware.MemberTokenHeader, token)
+	}
+
+	resp, err := app.Test(req, 1000)
+	if err != nil {
+		return 0, types.MemberResponse{}
+	}
+
+	member := types.MemberResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&member)
+	return resp.StatusCode, member
+}
+
+// joinTeam invites a member and accepts the invite, it returns the access token. The invite is sent with the member
+// token of inviter, or with skuId alone when it is empty, which only works until someone joins the team.
+func joinTeam(t *testing.T, app *fiber.App, skuId string, inviter string, email string, role models.Role) (string, string) {
+	status, invited := memberRequest(app, "POST", "/team/members?skuId="+skuId, inviter, map[string]interface{}{"email": email, "role": role})
+	assert.Equal(t, 201, status, "Invite a "+string(role))
+
+	status, joined := memberRequest(app, "POST", "/team/invites/accept", "", map[string]interface{}{"token": invited.Token})
+	assert.Equal(t, 200, status, "Accept the invite of a "+string(role))
+	assert.Equal(t, models.MemberActive, joined.Member.Status)
+	return joined.Member.Id, joined.Token
+}
+
+// joinAsManager adds a manager to the team of the merchant and returns their access token, the manager is removed
+// when the test ends
+func joinAsManager(t *testing.T, skuId string) string {
+	member, invite, err := models.InviteMember(skuId, "manager@"+strings.ToLower(skuId)+".example.com", models.RoleManager, string(models.RoleOwner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { models.RemoveMember(member.Id) })
+
+	_, token, err := models.AcceptInvite(invite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func Test_team(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(middleware.MemberAuth)
+	products := app.Group("/products")
+	products.Post("/", handlers.CreateProductEndpoint)
+	products.Put("/:id", handlers.UpdateProductEndpoint)
+	products.Delete("/:id", handlers.DeleteProductEndpoint)
+	team := app.Group("/team")
+	team.Get("/members", handlers.GetTeamMembersEndpoint)
+	team.Post("/members", handlers.InviteMemberEndpoint)
+	team.Put("/members/:id", handlers.UpdateMemberEndpoint)
+	team.Delete("/members/:id", handlers.RemoveMemberEndpoint)
+	team.Post("/invites/accept", handlers.AcceptInviteEndpoint)
+	apiKeys := app.Group("/api-key")
+	apiKeys.Get("/", handlers.GetAPIKeysEndpoint)
+	apiKeys.Post("/", handlers.CreateAPIKeyEndpoint)
+
+	models.SaveProduct(models.Product{
+		Id:          testTeamProductId,
+		SkuId:       "teamSkuId",
+		Name:        "Woven basket",
+		Description: "A basket",
+		Price:       3000,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+
+	defer models.DeleteProduct(testTeamProductId)
+
+	managerId, manager := joinTeam(t, app, "teamSkuId", "", "manager@example.com", models.RoleManager)
+	editorId, editor := joinTeam(t, app, "teamSkuId", manager, "editor@example.com", models.RoleEditor)
+	_, viewer := joinTeam(t, app, "teamSkuId", manager, "viewer@example.com", models.RoleViewer)
+
+	defer models.RemoveMember(managerId)
+	defer models.RemoveMember(editorId)
+	defer func() {
+		for _, key := range models.MerchantAPIKeys("teamSkuId") {
+			_, _ = models.RevokeAPIKey(key.Id)
+		}
+	}()
+
+	status, _ := memberRequest(app, "POST", "/team/members", manager, map[string]interface{}{"email": "EDITOR@example.com", "role": "viewer"})
+	assert.Equal(t, 409, status, "Invite an email already in the team")
+
+	status, _ = memberRequest(app, "POST", "/team/members?skuId=teamSkuId", "", map[string]interface{}{"email": "someone@example.com", "role": "manager"})
+	assert.Equal(t, 403, status, "The skuId alone cannot invite once the team has members")
+
+	productRoute := "/products/" + testTeamProductId
+	update := map[string]interface{}{"name": "Woven bag"}
+	created := map[string]interface{}{"name": "Raffia hat", "description": "A hat", "price": 2000}
+
+	tests := []struct {
+		description  string
+		method       string
+		route        string
+		token        string
+		expectedCode int
+		body         map[string]interface{}
+	}{
+		{
+			description:  "Viewers cannot create products",
+			method:       "POST",
+			route:        "/products",
+			token:        viewer,
+			expectedCode: 403,
+			body:         created,
+		},
+		{
+			description:  "Viewers cannot update products",
+			method:       "PUT",
+			route:        productRoute,
+			token:        viewer,
+			expectedCode: 403,
+			body:         update,
+		},
+		{
+			description:  "The skuId alone cannot update products once the team has members",
+			method:       "PUT",
+			route:        productRoute + "?skuId=teamSkuId",
+			expectedCode: 403,
+			body:         update,
+		},
+		{
+			description:  "The skuId alone cannot delete products once the team has members",
+			method:       "DELETE",
+			route:        productRoute + "?skuId=teamSkuId",
+			expectedCode: 403,
+		},
+		{
+			description:  "Editors update products of their merchant",
+			method:       "PUT",
+			route:        productRoute,
+			token:        editor,
+			expectedCode: 200,
+			body:         update,
+		},
+		{
+			description:  "Editors cannot delete products",
+			method:       "DELETE",
+			route:        productRoute,
+			token:        editor,
+			expectedCode: 403,
+		},
+		{
+			description:  "Editors cannot invite members",
+			method:       "POST",
+			route:        "/team/members",
+			token:        editor,
+			expectedCode: 403,
+			body:         map[string]interface{}{"email": "new@example.com", "role": "viewer"},
+		},
+		{
+			description:  "Managers cannot invite managers",
+			method:       "POST",
+			route:        "/team/members",
+			token:        manager,
+			expectedCode: 403,
+			body:         map[string]interface{}{"email": "new@example.com", "role": "manager"},
+		},
+		{
+			description:  "Managers cannot change the role of managers",
+			method:       "PUT",
+			route:        "/team/members/" + managerId,
+			token:        manager,
+			expectedCode: 403,
+			body:         map[string]interface{}{"role": "viewer"},
+		},
+		{
+			description:  "Managers change the role of editors",
+			method:       "PUT",
+			route:        "/team/members/" + editorId,
+			token:        manager,
+			expectedCode: 200,
+			body:         map[string]interface{}{"role": "viewer"},
+		},
+		{
+			description:  "Viewers cannot list the team",
+			method:       "GET",
+			route:        "/team/members",
+			token:        viewer,
+			expectedCode: 403,
+		},
+		{
+			description:  "Viewers cannot create API keys",
+			method:       "POST",
+			route:        "/api-key",
+			token:        viewer,
+			expectedCode: 403,
+			body:         map[string]interface{}{"name": "Escalate", "scopes": []string{"products:write"}},
+		},
+		{
+			description:  "Editors cannot list API keys",
+			method:       "GET",
+			route:        "/api-key",
+			token:        editor,
+			expectedCode: 403,
+		},
+		{
+			description:  "Managers create API keys",
+			method:       "POST",
+			route:        "/api-key",
+			token:        manager,
+			expectedCode: 201,
+			body:         map[string]interface{}{"name": "Storefront", "scopes": []string{"products:read"}},
+		},
+		{
+			description:  "Invalid member tokens are rejected",
+			method:       "DELETE",
+			route:        productRoute,
+			token:        "mem_not_a_token",
+			expectedCode: 401,
+		},
+		{
+			description:  "Managers delete products",
+			method:       "DELETE",
+			route:        productRoute,
+			token:        manager,
+			expectedCode: 200,
+		},
+	}
+
+	for _, test := range tests {
+		status, _ := memberRequest(app, test.method, test.route, test.token, test.body)
+		assert.Equalf(t, test.expectedCode, status, test.description)
+	}
+
+	status, _ = memberRequest(app, "PUT", productRoute, editor, update)
+	assert.Equal(t, 403, status, "A role change applies to the next request")
+
+	status, _ = memberRequest(app, "POST", "/team/invites/accept", "", map[string]interface{}{"token": "inv_used"})
+	assert.Equal(t, 404, status, "Accept an invite that does not exist")
+
+	req := httptest.NewRequest("GET", "/team/members", nil)
+	req.Header.Set(middleware.MemberTokenHeader, manager)
+	resp, _ := app.Test(req, 1000)
+	listed := types.GetMembersResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&listed)
+	assert.Len(t, listed.Members, 3, "List the team")
+
+	for _, member := range listed.Members {
+		if member.Id != managerId {
+			status, _ = memberRequest(app, "DELETE", "/team/members/"+member.Id, manager, nil)
+			assert.Equal(t, 200, status, "Managers remove editors and viewers")
+		}
+	}
+	models.RemoveMember(managerId)
+
+	status, _ = memberRequest(app, "POST", "/products", manager, created)
+	assert.Equal(t, 401, status, "Removed members cannot use their token")
+}
+
+func Test_teamViewer(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Use(middleware.APIKeyAuth)
+	app.Use(middleware.MemberAuth)
+	routes.Register(app, routes.Config{})
+
+	viewerId, viewer := joinTeam(t, app, "teamViewerSkuId", "", "viewer@example.com", models.RoleViewer)
+	defer models.RemoveMember(viewerId)
+
+	// The role is checked before anything is looked up, so the ids do not need to exist
+	tests := []struct {
+		description string
+		method      string
+		route       string
+		body        map[string]interface{}
+	}{
+		{description: "Viewers cannot create variants", method: "POST", route: "/product/missing/variants", body: map[string]interface{}{"sku": "V-1", "price": 10}},
+		{description: "Viewers cannot update variants", method: "PUT", route: "/product/missing/variants/missing", body: map[string]interface{}{"price": 10}},
+		{description: "Viewers cannot delete variants", method: "DELETE", route: "/product/missing/variants/missing"},
+		{description: "Viewers cannot assign categories", method: "POST", route: "/product/missing/categories", body: map[string]interface{}{"categoryIds": []string{"missing"}}},
+		{description: "Viewers cannot remove categories", method: "DELETE", route: "/product/missing/categories/missing"},
+		{description: "Viewers cannot create categories", method: "POST", route: "/category", body: map[string]interface{}{"name": "Lamps"}},
+		{description: "Viewers cannot update categories", method: "PUT", route: "/category/missing", body: map[string]interface{}{"name": "Lamps"}},
+		{description: "Viewers cannot delete categories", method: "DELETE", route: "/category/missing"},
+		{description: "Viewers cannot change orders", method: "POST", route: "/order/missing/transitions", body: map[string]interface{}{"status": "cancelled"}},
+		{description: "Viewers cannot list promotions", method: "GET", route: "/promotion"},
+		{description: "Viewers cannot get promotions", method: "GET", route: "/promotion/missing"},
+		{description: "Viewers cannot delete promotions", method: "DELETE", route: "/promotion/missing"},
+		{description: "Viewers cannot read the tax settings", method: "GET", route: "/tax/settings"},
+		{description: "Viewers cannot change the tax settings", method: "PUT", route: "/tax/settings", body: map[string]interface{}{"jurisdiction": "NG", "pricesIncludeTax": true}},
+		{description: "Viewers cannot list API keys", method: "GET", route: "/api-key"},
+		{description: "Viewers cannot list the team", method: "GET", route: "/team/members"},
+	}
+
+	for _, test := range tests {
+		status, _ := memberRequest(app, test.method, test.route, viewer, test.body)
+		assert.Equalf(t, 403, status, test.description)
+	}
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/rnwonder/SAL/types"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_localizedValidation(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Post("/products", handlers.CreateProductEndpoint)
+
+	tests := []struct {
+		description    string
+		acceptLanguage string
+		detail         string
+		message        string
+	}{
+		{
+			description:    "Without Accept-Language messages are in English",
+			acceptLanguage: "",
+			detail:         "The request body failed validation",
+			message:        "name is a required field",
+		},
+		{
+			description:    "French with a region",
+			acceptLanguage: "fr-FR,fr;q=0.9,en;q=0.8",
+			detail:         "Le corps de la requête n'est pas valide",
+			message:        "name est un champ obligatoire",
+		},
+		{
+			description:    "The preferred supported locale wins",
+			acceptLanguage: "de;q=0.9, en;q=0.5, fr;q=0.7",
+			detail:         "Le corps de la requête n'est pas valide",
+			message:        "name est un champ obligatoire",
+		},
+		{
+			description:    "Unsupported locales fall back to English",
+			acceptLanguage: "de-DE",
+			detail:         "The request body failed validation",
+			message:        "name is a required field",
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/products?skuId=localizedSkuId", strings.NewReader(`{"description":"Indigo dyed","price":100}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", test.acceptLanguage)
+		resp, _ := app.Test(req, 1000)
+
+		body := problem.Problem{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+
+		assert.Equalf(t, 400, resp.StatusCode, test.description)
+		assert.Equalf(t, test.detail, body.Detail, test.description)
+		if assert.Lenf(t, body.Errors, 1, test.description) {
+			assert.Equalf(t, "name", body.Errors[0].Field, "Fields are named after their JSON tag")
+			assert.Equalf(t, test.message, body.Errors[0].Message, test.description)
+		}
+	}
+}
+
+func Test_productValidation(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	app.Post("/products", handlers.CreateProductEndpoint)
+	app.Put("/products/:id", handlers.UpdateProductEndpoint)
+	app.Post("/products/:id/variants", handlers.CreateVariantEndpoint)
+
+	tests := []struct {
+		description    string
+		method         string
+		route          string
+		body           map[string]interface{}
+		acceptLanguage string
+		expectedCode   int
+		field          string
+		rule           string
+		message        string
+	}{
+		{
+			description:  "Create a product with a negative price",
+			method:       "POST",
+			route:        "/products?skuId=rulesSkuId",
+			body:         map[string]interface{}{"name": "Adire", "description": "Indigo dyed cloth", "price": -5},
+			expectedCode: 400,
+			field:        "price",
+			rule:         "gt",
+		},
+		{
+			description:  "Create a product with a whitespace only name",
+			method:       "POST",
+			route:        "/products?skuId=rulesSkuId",
+			body:         map[string]interface{}{"name": "   ", "description": "Indigo dyed cloth", "price": 5},
+			expectedCode: 400,
+			field:        "name",
+			rule:         "required",
+		},
+		{
+			description:  "Create a product with a name that is only markup",
+			method:       "POST",
+			route:        "/products?skuId=rulesSkuId",
+			body:         map[string]interface{}{"name": "<b></b>", "description": "Indigo dyed cloth", "price": 5},
+			expectedCode: 400,
+			field:        "name",
+			rule:         "required",
+		},
+		{
+			description:  "Create a product with a description that is too long",
+			method:       "POST",
+			route:        "/products?skuId=rulesSkuId",
+			body:         map[string]interface{}{"name": "Adire", "description": strings.Repeat("a", 5001), "price": 5},
+			expectedCode: 400,
+			field:        "description",
+			rule:         "max",
+		},
+		{
+			description:  "Update a product with a negative price",
+			method:       "PUT",
+			route:        "/products/1?skuId=someSkuId",
+			body:         map[string]interface{}{"price": -1},
+			expectedCode: 400,
+			field:        "price",
+			rule:         "gt",
+		},
+		{
+			description:  "Update a product with a one letter name",
+			method:       "PUT",
+			route:        "/products/1?skuId=someSkuId",
+			body:         map[string]interface{}{"name": "A"},
+			expectedCode: 400,
+			field:        "name",
+			rule:         "min",
+		},
+		{
+			description:  "Create a variant with an invalid sku",
+			method:       "POST",
+			route:        "/products/1/variants?skuId=someSkuId",
+			body:         map[string]interface{}{"skuId": "red shirt!", "options": map[string]string{"color": "red"}, "price": 10},
+			expectedCode: 400,
+			field:        "skuId",
+			rule:         "sku",
+			message:      "skuId must be 2 to 64 letters, digits, dots, dashes or underscores starting with a letter or digit",
+		},
+		{
+			description:    "Custom rules are translated",
+			method:         "POST",
+			route:          "/products/1/variants?skuId=someSkuId",
+			body:           map[string]interface{}{"skuId": "RED-M", "options": map[string]string{" ": "red"}, "price": 10},
+			acceptLanguage: "fr",
+			expectedCode:   400,
+			field:          "options[ ]",
+			rule:           "notblank",
+			message:        "options[ ] ne peut pas être vide",
+		},
+	}
+
+	for _, test := range tests {
+		data, _ := json.Marshal(test.body)
+		req := httptest.NewRequest(test.method, test.route, strings.NewReader(string(data)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", test.acceptLanguage)
+		resp, _ := app.Test(req, 1000)
+
+		body := problem.Problem{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+
+		assert.Equalf(t, test.expectedCode, resp.StatusCode, test.description)
+		if assert.NotEmptyf(t, body.Errors, test.description) {
+			assert.Equalf(t, test.field, body.Errors[0].Field, test.description)
+			assert.Equalf(t, test.rule, body.Errors[0].Rule, test.description)
+			if test.message != "" {
+				assert.Equalf(t, test.message, body.Errors[0].Message, test.description)
+			}
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/products?skuId=rulesSkuId", strings.NewReader(`{"name":"  <b>Adire</b> <script>alert(1)</script>","description":"<p>Indigo dyed cloth</p>","price":5}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req, 1000)
+
+	created := types.OneProductResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&created)
+	defer models.DeleteProduct(created.Product.Id)
+
+	assert.Equal(t, 201, resp.StatusCode, "Create a product with markup")
+	assert.Equal(t, "Adire", created.Product.Name, "Markup is stripped")
+	assert.Equal(t, "Indigo dyed cloth", created.Product.Description)
+}
@@ -0,0 +1,271 @@
+package test
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rnwonder/SAL/internals/handlers"
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/problem"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testVariantId = "7a1b2c3d-0000-4000-8000-000000000001"
+
+func Test_variants(t *testing.T) {
+	tests := []struct {
+		description  string
+		method       string
+		route        string
+		expectedCode int
+		contains     []string
+		body         map[string]interface{}
+	}{
+		{
+			description:  "Create a variant without options",
+			method:       "POST",
+			route:        "/products/" + testId3 + "/variants?skuId=someSkuId",
+			expectedCode: 400,
+			contains: []string{
+				`"field":"options"`,
+			},
+			body: map[string]interface{}{
+				"skuId": "TSHIRT-RED-M",
+				"price": 4500,
+			},
+		},
+		{
+			description:  "Create a variant of a product owned by another merchant",
+			method:       "POST",
+			route:        "/products/" + testId3 + "/variants?skuId=someSkuId2",
+			expectedCode: 403,
+			body: map[string]interface{}{
+				"skuId":   "TSHIRT-RED-M",
+				"options": map[string]string{"size": "M", "colour": "red"},
+				"price":   4500,
+			},
+		},
+		{
+			description:  "Create a variant",
+			method:       "POST",
+			route:        "/products/" + testId3 + "/variants?skuId=someSkuId",
+			expectedCode: 201,
+			contains: []string{
+				`"message":"Variant created successfully"`,
+				`"skuId":"TSHIRT-RED-M"`,
+				`"stock":3`,
+			},
+			body: map[string]interface{}{
+				"skuId":   "TSHIRT-RED-M",
+				"options": map[string]string{"size": "M", "colour": "red"},
+				"price":   4500,
+				"stock":   3,
+			},
+		},
+		{
+			description:  "Create a variant with a duplicate sku",
+			method:       "POST",
+			route:        "/products/" + testId3 + "/variants?skuId=someSkuId",
+			expectedCode: 409,
+			contains: []string{
+				`"detail":"A variant with this skuId already exists"`,
+			},
+			body: map[string]interface{}{
+				"skuId":   "TSHIRT-RED-M",
+				"options": map[string]string{"size": "L", "colour": "red"},
+				"price":   4500,
+			},
+		},
+		{
+			description:  "Create a variant with duplicate options",
+			method:       "POST",
+			route:        "/products/" + testId3 + "/variants?skuId=someSkuId",
+			expectedCode: 409,
+			contains: []string{
+				`"detail":"A variant with these options already exists"`,
+			},
+			body: map[string]interface{}{
+				"skuId":   "TSHIRT-BLUE-S2",
+				"options": map[string]string{"size": "S", "colour": "blue"},
+				"price":   4000,
+			},
+		},
+		{
+			description:  "Update the stock of a variant to zero",
+			method:       "PUT",
+			route:        "/products/" + testId3 + "/variants/" + testVariantId + "?skuId=someSkuId",
+			expectedCode: 200,
+			contains: []string{
+				`"stock":0`,
+				`"price":3500`,
+			},
+			body: map[string]interface{}{
+				"stock": 0,
+			},
+		},
+		{
+			description:  "List the variants with the price range",
+			method:       "GET",
+			route:        "/products/" + testId3 + "/variants",
+			expectedCode: 200,
+			contains: []string{
+				`"skuId":"TSHIRT-BLUE-S"`,
+				`"skuId":"TSHIRT-RED-M"`,
+				`"priceRange":{"min":3500,"max":4500}`,
+			},
+		},
+		{
+			description:  "Get a product exposes the price range across its variants",
+			method:       "GET",
+			route:        "/products/" + testId3,
+			expectedCode: 200,
+			contains: []string{
+				`"priceRange":{"min":3500,"max":4500}`,
+				`"variantCount":2`,
+			},
+		},
+		{
+			description:  "Get a variant of another product",
+			method:       "GET",
+			route:        "/products/" + testId1 + "/variants/" + testVariantId,
+			expectedCode: 404,
+			contains: []string{
+				`"detail":"Variant not found"`,
+			},
+		},
+		{
+			description:  "Delete a variant",
+			method:       "DELETE",
+			route:        "/products/" + testId3 + "/variants/" + testVariantId + "?skuId=someSkuId",
+			expectedCode: 200,
+			contains: []string{
+				`"message":"Variant deleted successfully"`,
+			},
+		},
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	products := app.Group("/products")
+	products.Get("/:id", handlers.FindAProductEndpoint)
+	products.Get("/:id/variants", handlers.GetProductVariantsEndpoint)
+	products.Get("/:id/variants/:variantId", handlers.FindAVariantEndpoint)
+	products.Post("/:id/variants", handlers.CreateVariantEndpoint)
+	products.Put("/:id/variants/:variantId", handlers.UpdateVariantEndpoint)
+	products.Delete("/:id/variants/:variantId", handlers.DeleteVariantEndpoint)
+
+	models.ProductData[testId3] = models.Product{
+		Id:          testId3,
+		SkuId:       "someSkuId",
+		Name:        "T-shirt",
+		Description: "A cotton t-shirt",
+		Price:       4000,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	models.VariantData[testVariantId] = models.Variant{
+		Id:        testVariantId,
+		ProductId: testId3,
+		SkuId:     "TSHIRT-BLUE-S",
+		Options:   map[string]string{"size": "S", "colour": "blue"},
+		Price:     3500,
+		Stock:     10,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	defer models.DeleteProduct(testId3)
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.route, nil)
+
+		if test.body != nil {
+			body, _ := json.Marshal(test.body)
+			req = httptest.NewRequest(test.method, test.route, strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := app.Test(req, 1000)
+
+		if err != nil {
+			t.Errorf("error testing route %s: %v", test.route, err)
+			continue
+		}
+
+		read, _ := io.ReadAll(resp.Body)
+
+		for _, contain := range test.contains {
+			assert.Containsf(t, string(read), contain, test.description)
+		}
+
+		assert.Equalf(t, test.expectedCode, resp.StatusCode, test.description)
+	}
+}
+
+func Test_variantConflicts(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: problem.Handler})
+	products := app.Group("/products")
+	products.Post("/:id/variants", handlers.CreateVariantEndpoint)
+	products.Put("/:id/variants/:variantId", handlers.UpdateVariantEndpoint)
+
+	models.ProductData[testId3] = models.Product{Id: testId3, SkuId: "someSkuId", Name: "T-shirt", Price: 4000, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	models.VariantData[testVariantId] = models.Variant{
+		Id:        testVariantId,
+		ProductId: testId3,
+		SkuId:     "TSHIRT-GREEN-S",
+		Options:   map[string]string{"size": "S", "colour": "green"},
+		Price:     3500,
+		Stock:     10,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	defer models.DeleteProduct(testId3)
+	defer models.DeleteVariant(testVariantId)
+
+	_, err := models.ReserveStock(testId3, testVariantId, 4, time.Minute)
+	assert.NoError(t, err, "Reserve stock of the variant")
+
+	variantRequest := func(method string, route string, body map[string]interface{}) int {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, route, strings.NewReader(string(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 1000)
+		if err != nil {
+			return 0
+		}
+		return resp.StatusCode
+	}
+
+	route := "/products/" + testId3 + "/variants/" + testVariantId + "?skuId=someSkuId"
+	assert.Equal(t, 409, variantRequest("PUT", route, map[string]interface{}{"stock": 3}), "Lower the stock below the reserved units")
+	assert.Equal(t, 200, variantRequest("PUT", route, map[string]interface{}{"stock": 4}), "Lower the stock to the reserved units")
+
+	// Requests racing for the same skuId are checked and stored under one lock, only one of them wins
+	codes := make(chan int, 10)
+	for i := 0; i < cap(codes); i++ {
+		go func(i int) {
+			codes <- variantRequest("POST", "/products/"+testId3+"/variants?skuId=someSkuId", map[string]interface{}{
+				"skuId":   "TSHIRT-RACE",
+				"options": map[string]string{"size": strconv.Itoa(i)},
+				"price":   4000,
+			})
+		}(i)
+	}
+
+	created := 0
+	for i := 0; i < cap(codes); i++ {
+		if <-codes == 201 {
+			created++
+		}
+	}
+	assert.Equal(t, 1, created, "Create variants with the same skuId at once")
+
+	for _, variant := range models.GetProductVariants(testId3) {
+		models.DeleteVariant(variant.Id)
+	}
+}
@@ -0,0 +1,20 @@
+package types
+
+import "github.com/rnwonder/SAL/internals/models"
+
+type GetAPIKeysResponse struct {
+	APIKeys []models.APIKey `json:"apiKeys"`
+	Message string          `json:"message"`
+}
+
+type APIKeyResponse struct {
+	APIKey models.APIKey `json:"apiKey"`
+	// Secret is only sent when the key is created or rotated
+	Secret  string `json:"secret,omitempty"`
+	Message string `json:"message"`
+}
+
+type APIKeyCreatePayload struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=products:read products:write orders:read orders:write"`
+}
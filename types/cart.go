@@ -0,0 +1,58 @@
+package types
+
+import (
+	"github.com/rnwonder/SAL/internals/models"
+	"time"
+)
+
+type CartLine struct {
+	Id        string  `json:"id"`
+	ProductId string  `json:"productId"`
+	VariantId string  `json:"variantId"`
+	SkuId     string  `json:"skuId"`
+	Name      string  `json:"name"`
+	UnitPrice float32 `json:"unitPrice"`
+	Quantity  int     `json:"quantity"`
+	// Discount is the discount on the whole line, LineTotal already has it taken off
+	Discount  float32                  `json:"discount"`
+	Promotion *models.AppliedPromotion `json:"promotion"`
+	LineTotal float32                  `json:"lineTotal"`
+	// Tax is the tax on LineTotal, it is only added to the cart total when the merchant's prices exclude tax
+	Tax models.TaxBreakdown `json:"tax"`
+}
+
+type CartView struct {
+	Id    string     `json:"id"`
+	Items []CartLine `json:"items"`
+	// RemovedItems lists lines dropped because their product or variant was deleted
+	RemovedItems []models.CartItem `json:"removedItems"`
+	ItemCount    int               `json:"itemCount"`
+	Coupons      []string          `json:"coupons"`
+	Subtotal     float32           `json:"subtotal"`
+	Discount     float32           `json:"discount"`
+	Tax          float32           `json:"tax"`
+	Total        float32           `json:"total"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	ExpiresAt    time.Time         `json:"expiresAt"`
+}
+
+type CartResponse struct {
+	Cart    CartView `json:"cart"`
+	Message string   `json:"message"`
+}
+
+type CartItemPayload struct {
+	ProductId string `json:"productId" validate:"required"`
+	VariantId string `json:"variantId"`
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+}
+
+type CartItemUpdatePayload struct {
+	Quantity int `json:"quantity" validate:"required,min=1"`
+}
+
+type CartCouponPayload struct {
+	Code string `json:"code" validate:"required"`
+	// SkuId names the merchant of the coupon, the merchants of the items in the cart are used when it is empty
+	SkuId string `json:"skuId"`
+}
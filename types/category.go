@@ -0,0 +1,28 @@
+package types
+
+import "github.com/rnwonder/SAL/internals/models"
+
+type GetCategoriesResponse struct {
+	Categories []models.Category `json:"categories"`
+	Message    string            `json:"message"`
+}
+
+type OneCategoryResponse struct {
+	Category models.Category   `json:"category"`
+	Children []models.Category `json:"children"`
+	Message  string            `json:"message"`
+}
+
+type CategoryCreatePayload struct {
+	Name        string `json:"name" validate:"required,notblank,max=120"`
+	Description string `json:"description" validate:"omitempty,max=5000"`
+	ParentId    string `json:"parentId"`
+}
+
+// Fields left out or sent empty are not changed like on ProductUpdatePayload, except ParentId
+type CategoryUpdatePayload struct {
+	Name        string `json:"name" validate:"omitempty,notblank,max=120"`
+	Description string `json:"description" validate:"omitempty,max=5000"`
+	// ParentId moves the category, an empty string moves it to the top level
+	ParentId *string `json:"parentId"`
+}
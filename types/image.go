@@ -0,0 +1,12 @@
+package types
+
+import "github.com/rnwonder/SAL/internals/models"
+
+type GetImagesResponse struct {
+	Images  []models.Image `json:"images"`
+	Message string         `json:"message"`
+}
+
+type ImageOrderPayload struct {
+	ImageIds []string `json:"imageIds" validate:"required,min=1"`
+}
@@ -0,0 +1,35 @@
+package types
+
+import "github.com/rnwonder/SAL/internals/models"
+
+type StockResponse struct {
+	Stock    models.StockLevel   `json:"stock"`
+	Variants []models.StockLevel `json:"variants"`
+	Message  string              `json:"message"`
+}
+
+type StockLevelsResponse struct {
+	Levels  []models.StockLevel `json:"levels"`
+	Message string              `json:"message"`
+}
+
+type ReservationResponse struct {
+	Reservation models.Reservation `json:"reservation"`
+	Message     string             `json:"message"`
+}
+
+type StockAdjustPayload struct {
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+	VariantId string `json:"variantId"`
+}
+
+type StockSettingsPayload struct {
+	LowStockThreshold *int `json:"lowStockThreshold" validate:"required,min=0"`
+}
+
+type ReservationCreatePayload struct {
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+	VariantId string `json:"variantId"`
+	// TtlSeconds defaults to 15 minutes and is capped at one hour
+	TtlSeconds int `json:"ttlSeconds" validate:"omitempty,min=1,max=3600"`
+}
@@ -0,0 +1,35 @@
+package types
+
+import (
+	"github.com/rnwonder/SAL/internals/models"
+	"github.com/rnwonder/SAL/internals/payment"
+)
+
+type OrderResponse struct {
+	Order   models.Order `json:"order"`
+	Message string       `json:"message"`
+}
+
+type GetOrdersResponse struct {
+	Orders  []models.Order `json:"orders"`
+	Message string         `json:"message"`
+}
+
+type OrderCreatePayload struct {
+	CartId string `json:"cartId" validate:"required"`
+}
+
+type OrderTransitionPayload struct {
+	Status models.OrderStatus `json:"status" validate:"required,oneof=fulfilled delivered cancelled refunded"`
+	Note   string             `json:"note"`
+}
+
+type OrderPaymentPayload struct {
+	Email       string `json:"email" validate:"required,email"`
+	CallbackUrl string `json:"callbackUrl" validate:"omitempty,url"`
+}
+
+type PaymentResponse struct {
+	Payment payment.Transaction `json:"payment"`
+	Message string              `json:"message"`
+}
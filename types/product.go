@@ -1,6 +1,9 @@
 package types
 
-import "github.com/rnwonder/SAL/internals/models"
+import (
+	"github.com/rnwonder/SAL/internals/models"
+	"time"
+)
 
 type Meta struct {
 	CurrentPage   int    `json:"currentPage"`
@@ -12,28 +15,99 @@ type Meta struct {
 }
 
 type GetProductResponse struct {
-	Products []models.Product `json:"products"`
-	Meta     Meta             `json:"meta"`
-	Message  string           `json:"message"`
+	Products []ProductView `json:"products"`
+	Meta     Meta          `json:"meta"`
+	Message  string        `json:"message"`
 }
 
 type OneProductResponse struct {
-	Product models.Product `json:"product"`
-	Message string         `json:"message"`
+	Product ProductView `json:"product"`
+	Message string      `json:"message"`
 }
 
 type MessageResponse struct {
 	Message string `json:"message"`
 }
 
+type WelcomeResponse struct {
+	Message string `json:"message"`
+	Live    bool   `json:"live"`
+}
+
+// Names and descriptions are plain text, markup is stripped before validation
 type ProductCreatePayload struct {
-	Name        string  `json:"name" validate:"required"`
-	Description string  `json:"description" validate:"required"`
-	Price       float32 `json:"price" validate:"required"`
+	Name        string          `json:"name" validate:"required,notblank,min=2,max=120" sanitize:"striphtml,trim"`
+	Description string          `json:"description" validate:"required,notblank,max=5000" sanitize:"striphtml,trim"`
+	Price       float32         `json:"price" validate:"required,gt=0,lte=100000000"`
+	TaxClass    models.TaxClass `json:"taxClass" validate:"omitempty,oneof=standard reduced zero exempt"`
 }
 
+// Fields left out or sent empty are not changed, the ones sent follow the same rules as when creating a product.
+// A field cannot be cleared, whitespace alone fails notblank instead of being taken as no change.
 type ProductUpdatePayload struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float32 `json:"price"`
+	Name        string          `json:"name" validate:"omitempty,notblank,min=2,max=120" sanitize:"striphtml,trim"`
+	Description string          `json:"description" validate:"omitempty,notblank,max=5000" sanitize:"striphtml,trim"`
+	Price       float32         `json:"price" validate:"omitempty,gt=0,lte=100000000"`
+	TaxClass    models.TaxClass `json:"taxClass" validate:"omitempty,oneof=standard reduced zero exempt"`
+}
+
+type ProductCategoriesPayload struct {
+	CategoryIds []string `json:"categoryIds" validate:"required,min=1"`
+}
+
+type ProductView struct {
+	models.Product
+	// EffectivePrice is the price including any active price schedule
+	EffectivePrice float32 `json:"effectivePrice"`
+	// DiscountedPrice is the effective price after the best automatic promotion
+	DiscountedPrice float32                  `json:"discountedPrice"`
+	Promotion       *models.AppliedPromotion `json:"promotion"`
+	// Tax splits the discounted price into net, tax and gross for the merchant's jurisdiction
+	Tax          models.TaxBreakdown `json:"tax"`
+	PriceRange   models.PriceRange   `json:"priceRange"`
+	VariantCount int                 `json:"variantCount"`
+	Images       []models.Image      `json:"images"`
+}
+
+// ProductEventData is the data of a message of GET /product/events, its id is the id of the message
+type ProductEventData struct {
+	Type    models.ProductEventType `json:"type"`
+	Product ProductView             `json:"product"`
+	At      time.Time               `json:"at"`
+}
+
+type AuditMeta struct {
+	CurrentPage int `json:"currentPage"`
+	Limit       int `json:"limit"`
+	TotalPages  int `json:"totalPages"`
+	Total       int `json:"total"`
+}
+
+type GetAuditResponse struct {
+	Entries []models.AuditEntry `json:"entries"`
+	Meta    AuditMeta           `json:"meta"`
+	Message string              `json:"message"`
+}
+
+type PriceHistoryResponse struct {
+	History []models.PriceChange `json:"history"`
+	Message string               `json:"message"`
+}
+
+type PriceSchedulesResponse struct {
+	Schedules []models.PriceSchedule `json:"schedules"`
+	Message   string                 `json:"message"`
+}
+
+type PriceScheduleResponse struct {
+	Schedule models.PriceSchedule `json:"schedule"`
+	Message  string               `json:"message"`
+}
+
+type PriceSchedulePayload struct {
+	// VariantId schedules the price of a variant instead of the product
+	VariantId string     `json:"variantId"`
+	Price     float32    `json:"price" validate:"required,gt=0,lte=100000000"`
+	StartsAt  time.Time  `json:"startsAt" validate:"required"`
+	EndsAt    *time.Time `json:"endsAt"`
 }
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"github.com/rnwonder/SAL/internals/models"
+	"time"
+)
+
+type GetPromotionsResponse struct {
+	Promotions []models.Promotion `json:"promotions"`
+	Message    string             `json:"message"`
+}
+
+type PromotionResponse struct {
+	Promotion models.Promotion `json:"promotion"`
+	Message   string           `json:"message"`
+}
+
+type PromotionCreatePayload struct {
+	Name  string              `json:"name" validate:"required"`
+	Type  models.DiscountType `json:"type" validate:"required,oneof=percentage fixed"`
+	Value float32             `json:"value" validate:"required,gt=0,lte=100000000"`
+	// Code turns the promotion into a coupon that only applies once added to a cart
+	Code        string     `json:"code" validate:"omitempty,alphanum,max=32"`
+	ProductIds  []string   `json:"productIds"`
+	CategoryIds []string   `json:"categoryIds"`
+	UsageLimit  int        `json:"usageLimit" validate:"min=0"`
+	StartsAt    *time.Time `json:"startsAt"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
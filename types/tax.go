@@ -0,0 +1,18 @@
+package types
+
+import "github.com/rnwonder/SAL/internals/models"
+
+type TaxRulesResponse struct {
+	Rules   []models.TaxRule `json:"rules"`
+	Message string           `json:"message"`
+}
+
+type TaxSettingsResponse struct {
+	Settings models.TaxSettings `json:"settings"`
+	Message  string             `json:"message"`
+}
+
+type TaxSettingsPayload struct {
+	Jurisdiction     string `json:"jurisdiction" validate:"required"`
+	PricesIncludeTax *bool  `json:"pricesIncludeTax" validate:"required"`
+}
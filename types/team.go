@@ -0,0 +1,28 @@
+package types
+
+import "github.com/rnwonder/SAL/internals/models"
+
+type GetMembersResponse struct {
+	Members []models.Member `json:"members"`
+	Message string          `json:"message"`
+}
+
+type MemberResponse struct {
+	Member models.Member `json:"member"`
+	// Token is the invite token when inviting and the access token when accepting an invite
+	Token   string `json:"token,omitempty"`
+	Message string `json:"message"`
+}
+
+type MemberInvitePayload struct {
+	Email string      `json:"email" validate:"required,email"`
+	Role  models.Role `json:"role" validate:"required,oneof=manager editor viewer"`
+}
+
+type MemberUpdatePayload struct {
+	Role models.Role `json:"role" validate:"required,oneof=manager editor viewer"`
+}
+
+type InviteAcceptPayload struct {
+	Token string `json:"token" validate:"required"`
+}
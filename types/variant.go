@@ -0,0 +1,29 @@
+package types
+
+import "github.com/rnwonder/SAL/internals/models"
+
+type GetVariantsResponse struct {
+	Variants   []models.Variant  `json:"variants"`
+	PriceRange models.PriceRange `json:"priceRange"`
+	Message    string            `json:"message"`
+}
+
+type OneVariantResponse struct {
+	Variant models.Variant `json:"variant"`
+	Message string         `json:"message"`
+}
+
+type VariantCreatePayload struct {
+	SkuId   string            `json:"skuId" validate:"required,sku" sanitize:"trim"`
+	Options map[string]string `json:"options" validate:"required,min=1,max=10,dive,keys,notblank,max=50,endkeys,notblank,max=100"`
+	Price   float32           `json:"price" validate:"required,gt=0,lte=100000000"`
+	Stock   int               `json:"stock" validate:"min=0"`
+}
+
+// Fields left out or sent empty are not changed, like on ProductUpdatePayload
+type VariantUpdatePayload struct {
+	SkuId   string            `json:"skuId" validate:"omitempty,sku" sanitize:"trim"`
+	Options map[string]string `json:"options" validate:"omitempty,max=10,dive,keys,notblank,max=50,endkeys,notblank,max=100"`
+	Price   float32           `json:"price" validate:"omitempty,gt=0,lte=100000000"`
+	Stock   *int              `json:"stock" validate:"omitempty,min=0"`
+}
@@ -0,0 +1,24 @@
+package util
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rnwonder/SAL/internals/models"
+	"time"
+)
+
+// SeedData fills the product store with sample products for local testing
+func SeedData() {
+	for i := 1; i <= 20; i++ {
+		id := uuid.Must(uuid.NewRandom()).String()
+		models.ProductData[id] = models.Product{
+			Id:          id,
+			SkuId:       "seedSkuId",
+			Name:        fmt.Sprintf("Seed product %d", i),
+			Description: "A seeded product description",
+			Price:       float32(i * 10),
+			CreatedAt:   time.Now().Add(time.Duration(i) * time.Minute),
+			UpdatedAt:   time.Now().Add(time.Duration(i) * time.Minute),
+		}
+	}
+}
@@ -1,9 +1,16 @@
 package validators
 
 import (
-	"fmt"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
-	"github.com/gofiber/fiber/v2"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	frTranslations "github.com/go-playground/validator/v10/translations/fr"
+	"github.com/rnwonder/SAL/internals/problem"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -11,32 +18,115 @@ type ErrorResponse struct {
 	Error       bool
 	FailedField string
 	Tag         string
+	Param       string
 	Value       interface{}
+	Message     string
 }
 
 type XValidator struct {
 	Validator *validator.Validate
 }
 
-type GlobalErrorHandlerResp struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+var Validate = validator.New()
+
+// Translators holds the messages of every supported locale, English is the fallback
+var Translators = ut.New(en.New(), en.New(), fr.New())
+
+// validationFailedKey is the translation key of the detail of validation problems
+const validationFailedKey = "validation_failed"
+
+func init() {
+	// Report fields by the name clients send them with
+	Validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	registrations := map[string]struct {
+		register func(v *validator.Validate, trans ut.Translator) error
+		detail   string
+	}{
+		"en": {enTranslations.RegisterDefaultTranslations, "The request body failed validation"},
+		"fr": {frTranslations.RegisterDefaultTranslations, "Le corps de la requête n'est pas valide"},
+	}
+
+	for locale, registration := range registrations {
+		trans, _ := Translators.GetTranslator(locale)
+
+		if err := registration.register(Validate, trans); err != nil {
+			panic(err)
+		}
+
+		if err := trans.Add(validationFailedKey, registration.detail, false); err != nil {
+			panic(err)
+		}
+	}
+
+	registerCustomRules()
 }
 
-var Validate = validator.New()
+// Translator picks the best supported locale of an Accept-Language header, English when none match
+func Translator(acceptLanguage string) ut.Translator {
+	trans, _ := Translators.FindTranslator(parseAcceptLanguage(acceptLanguage)...)
+	return trans
+}
 
-func (v XValidator) Validate(data interface{}) []ErrorResponse {
+// parseAcceptLanguage returns the locales of the header by preference, each region followed by its language
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	tags := make([]weighted, 0)
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			q = parsed
+		}
+		tags = append(tags, weighted{strings.ReplaceAll(strings.ToLower(tag), "-", "_"), q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	locales := make([]string, 0, len(tags)*2)
+	for _, tag := range tags {
+		locales = append(locales, tag.locale)
+		if language, _, ok := strings.Cut(tag.locale, "_"); ok {
+			locales = append(locales, language)
+		}
+	}
+	return locales
+}
+
+func (v XValidator) Validate(data interface{}, trans ut.Translator) []ErrorResponse {
 	validationErrors := []ErrorResponse{}
 
-	errs := Validate.Struct(data)
+	errs := v.Validator.Struct(data)
 	if errs != nil {
 		for _, err := range errs.(validator.ValidationErrors) {
 			// In this case data object is actually holding the User struct
 			var elem ErrorResponse
 
-			elem.FailedField = err.Field() // Export struct field name
-			elem.Tag = err.Tag()           // Export struct tag
-			elem.Value = err.Value()       // Export field value
+			elem.FailedField = err.Field()      // Export the JSON name of the field
+			elem.Tag = err.Tag()                // Export struct tag
+			elem.Param = err.Param()            // Export struct tag parameter
+			elem.Value = err.Value()            // Export field value
+			elem.Message = err.Translate(trans) // Export the message in the locale of the client
 			elem.Error = true
 
 			validationErrors = append(validationErrors, elem)
@@ -46,27 +136,31 @@ func (v XValidator) Validate(data interface{}) []ErrorResponse {
 	return validationErrors
 }
 
-func Validator(body interface{}) fiber.Map {
+// Validator sanitizes body and returns a validation problem listing every field that breaks its rules, or nil.
+// Messages are in the best locale of acceptLanguage, the Accept-Language header of the request.
+func Validator(body interface{}, acceptLanguage ...string) error {
+	Sanitize(body)
+
 	myValidator := &XValidator{
 		Validator: Validate,
 	}
 
-	if errs := myValidator.Validate(body); len(errs) > 0 && errs[0].Error {
-		errMsgs := make([]string, 0)
+	trans := Translator(strings.Join(acceptLanguage, ","))
+
+	if errs := myValidator.Validate(body, trans); len(errs) > 0 && errs[0].Error {
+		fieldErrors := make([]problem.FieldError, 0, len(errs))
 
 		for _, err := range errs {
-			errMsgs = append(errMsgs, fmt.Sprintf(
-				"[%s]: '%v' | Needs to implement '%s'",
-				err.FailedField,
-				err.Value,
-				err.Tag,
-			))
+			fieldErrors = append(fieldErrors, problem.FieldError{
+				Field:   err.FailedField,
+				Rule:    err.Tag,
+				Param:   err.Param,
+				Message: err.Message,
+			})
 		}
 
-		return fiber.Map{
-			"code":    fiber.ErrBadRequest.Code,
-			"message": strings.Join(errMsgs, " and "),
-		}
+		detail, _ := trans.T(validationFailedKey)
+		return problem.Validation(detail, fieldErrors)
 	}
 	return nil
 }
@@ -0,0 +1,106 @@
+package validators
+
+import (
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// SkuPattern is the format of skuIds checked by the "sku" rule
+const SkuPattern = `^[A-Za-z0-9][A-Za-z0-9._-]{1,63}$`
+
+var (
+	skuPattern = regexp.MustCompile(SkuPattern)
+	// Scripts and styles are dropped with their content, other tags keep their text
+	scriptPattern = regexp.MustCompile(`(?is)<(script|style)\b[^>]*>.*?</(script|style)\s*>`)
+	tagPattern    = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// customRule is a validation tag registered on Validate with its message in every locale
+type customRule struct {
+	validate     validator.Func
+	translations map[string]string
+}
+
+var customRules = map[string]customRule{
+	"notblank": {
+		validate: func(fl validator.FieldLevel) bool {
+			return strings.TrimSpace(fl.Field().String()) != ""
+		},
+		translations: map[string]string{
+			"en": "{0} cannot be blank",
+			"fr": "{0} ne peut pas être vide",
+		},
+	},
+	"sku": {
+		validate: func(fl validator.FieldLevel) bool {
+			return skuPattern.MatchString(fl.Field().String())
+		},
+		translations: map[string]string{
+			"en": "{0} must be 2 to 64 letters, digits, dots, dashes or underscores starting with a letter or digit",
+			"fr": "{0} doit contenir de 2 à 64 lettres, chiffres, points, tirets ou tirets bas et commencer par une lettre ou un chiffre",
+		},
+	},
+}
+
+// registerCustomRules adds the rules of the repo to Validate, the translators must already be set up
+func registerCustomRules() {
+	for tag, rule := range customRules {
+		if err := Validate.RegisterValidation(tag, rule.validate); err != nil {
+			panic(err)
+		}
+
+		for locale, text := range rule.translations {
+			trans, _ := Translators.GetTranslator(locale)
+			register := func(trans ut.Translator) error {
+				return trans.Add(tag, text, true)
+			}
+			translate := func(trans ut.Translator, fe validator.FieldError) string {
+				message, _ := trans.T(fe.Tag(), fe.Field())
+				return message
+			}
+
+			if err := Validate.RegisterTranslation(tag, trans, register, translate); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// StripHTML removes markup from user supplied text
+func StripHTML(value string) string {
+	value = scriptPattern.ReplaceAllString(value, "")
+	return tagPattern.ReplaceAllString(value, "")
+}
+
+// Sanitize rewrites the string fields of a struct pointer as their sanitize tag asks,
+// "striphtml" removes markup and "trim" removes surrounding whitespace
+func Sanitize(body interface{}) {
+	value := reflect.ValueOf(body)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	value = value.Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		tag := value.Type().Field(i).Tag.Get("sanitize")
+
+		if tag == "" || field.Kind() != reflect.String || !field.CanSet() {
+			continue
+		}
+
+		text := field.String()
+		for _, rule := range strings.Split(tag, ",") {
+			switch rule {
+			case "striphtml":
+				text = StripHTML(text)
+			case "trim":
+				text = strings.TrimSpace(text)
+			}
+		}
+		field.SetString(text)
+	}
+}